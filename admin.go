@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// adminAuth authenticates the callers of admin API by the bearer tokens.
+// The token file has a line of "<caller>:<token>" for each caller, so that
+// every call can be attributed. A line without caller is of "admin".
+type adminAuth struct {
+	getTokens func() []byte
+}
+
+// caller returns the caller whose token is carried by the request. It is
+// empty if the request is not authenticated.
+func (a *adminAuth) caller(req *http.Request) string {
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		return ""
+	}
+
+	for _, line := range strings.Split(string(a.getTokens()), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		caller, v := "admin", line
+		if i := strings.Index(line, ":"); i > 0 {
+			caller, v = line[:i], line[i+1:]
+		}
+
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(v)), []byte(token)) == 1 {
+			return caller
+		}
+	}
+
+	return ""
+}
+
+// adminError is the failure of admin API with the status code to reply.
+type adminError struct {
+	code   int
+	reason string
+}
+
+func (e *adminError) Error() string {
+	return e.reason
+}
+
+func newAdminError(code int, format string, args ...interface{}) error {
+	return &adminError{code: code, reason: fmt.Sprintf(format, args...)}
+}
+
+type recheckRequest struct {
+	Org    string `json:"org"`
+	Repo   string `json:"repo"`
+	Number int32  `json:"number"`
+}
+
+type recheckResult struct {
+	Org    string `json:"org"`
+	Repo   string `json:"repo"`
+	Number int32  `json:"number"`
+
+	// Verdict is signed, unsigned, exempt or error. It is empty if the PR
+	// is not checked, such as it is closed when the check runs.
+	Verdict string `json:"verdict"`
+
+	// UnsignedEmails are the masked emails which have not signed.
+	UnsignedEmails []string `json:"unsigned_emails,omitempty"`
+
+	LabelsAdded   []string `json:"labels_added,omitempty"`
+	LabelsRemoved []string `json:"labels_removed,omitempty"`
+
+	// Error is the failure of check, which may happen after some actions.
+	Error string `json:"error,omitempty"`
+}
+
+// checkReport collects the result of check for the callers other than the
+// webhook, such as the admin API. It is carried by the context of check.
+type checkReport struct {
+	state    string
+	waived   bool
+	unsigned []string
+}
+
+type checkReportKey struct{}
+
+func withCheckReport(ctx context.Context, r *checkReport) context.Context {
+	return context.WithValue(ctx, checkReportKey{}, r)
+}
+
+func checkReportOf(ctx context.Context) *checkReport {
+	r, _ := ctx.Value(checkReportKey{}).(*checkReport)
+
+	return r
+}
+
+// reportUnsigned records the masked emails which have not signed to the report of ctx.
+func reportUnsigned(ctx context.Context, results []agreementResult) {
+	r := checkReportOf(ctx)
+	if r == nil {
+		return
+	}
+
+	seen := map[string]bool{}
+	for i := range results {
+		for _, c := range results[i].unsigned {
+			if v := maskEmail(c.email); !seen[v] {
+				seen[v] = true
+				r.unsigned = append(r.unsigned, v)
+			}
+		}
+	}
+}
+
+// adminAPI serves the admin API which the support engineers use to
+// operate the robot without the contributors being involved.
+type adminAPI struct {
+	bot  *robot
+	auth adminAuth
+
+	// orgRechecks records the orgs being re-checked, so that an org will
+	// not be re-checked twice at the same time.
+	orgRechecks sync.Map
+}
+
+func (s *adminAPI) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/recheck", s.authenticated(s.recheck))
+	mux.HandleFunc("/v1/recheck-org", s.authenticated(s.recheckOrg))
+	mux.HandleFunc("/v1/audit", s.authenticated(s.auditHistory))
+
+	// The status is public, and the emails are unmasked for the admins only.
+	mux.HandleFunc("/v1/status", s.status)
+
+	return mux
+}
+
+// authenticated rejects the requests without a valid token, and passes the
+// caller to the handler.
+func (s *adminAPI) authenticated(
+	h func(caller string, w http.ResponseWriter, req *http.Request),
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		caller := s.auth.caller(req)
+		if caller == "" {
+			logrus.WithFields(logrus.Fields{
+				"audit":  "admin",
+				"path":   req.URL.Path,
+				"remote": req.RemoteAddr,
+			}).Warning("Rejected the unauthenticated admin request.")
+
+			writeJSONError(w, http.StatusUnauthorized, "invalid token")
+
+			return
+		}
+
+		h(caller, w, req)
+	}
+}
+
+func (s *adminAPI) recheck(caller string, w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "only POST is allowed")
+
+		return
+	}
+
+	var v recheckRequest
+	if err := json.NewDecoder(req.Body).Decode(&v); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid body: "+err.Error())
+
+		return
+	}
+
+	log := logrus.WithFields(logrus.Fields{
+		"audit":  "admin",
+		"caller": caller,
+		"remote": req.RemoteAddr,
+		"org":    v.Org,
+		"repo":   v.Repo,
+		"number": v.Number,
+	})
+
+	result, err := s.bot.recheckPRByNumber(req.Context(), v.Org, v.Repo, v.Number, "admin:"+caller, log)
+
+	var ae *adminError
+	if errors.As(err, &ae) {
+		log.WithField("reason", ae.reason).Warning("Refused the admin re-check.")
+		writeJSONError(w, ae.code, ae.reason)
+
+		return
+	}
+
+	log.WithFields(logrus.Fields{
+		"verdict":        result.Verdict,
+		"labels_added":   result.LabelsAdded,
+		"labels_removed": result.LabelsRemoved,
+		"error":          result.Error,
+	}).Info("Re-checked the pr by the admin request.")
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+type recheckOrgRequest struct {
+	Org string `json:"org"`
+}
+
+// recheckOrg starts re-checking all the open PRs of org in the background,
+// and replies the repos to re-check. The progress and the summary are logged.
+func (s *adminAPI) recheckOrg(caller string, w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "only POST is allowed")
+
+		return
+	}
+
+	var v recheckOrgRequest
+	if err := json.NewDecoder(req.Body).Decode(&v); err != nil || v.Org == "" {
+		writeJSONError(w, http.StatusBadRequest, "org is required")
+
+		return
+	}
+
+	log := logrus.WithFields(logrus.Fields{
+		"audit":       "admin",
+		"caller":      caller,
+		"remote":      req.RemoteAddr,
+		"recheck_org": v.Org,
+	})
+
+	c, ok := s.bot.latestConfig.Load().(*configuration)
+	if !ok {
+		writeJSONError(w, http.StatusServiceUnavailable, errConfigNotLoaded.Error())
+
+		return
+	}
+
+	repos := s.bot.orgRepos(req.Context(), c, v.Org, log)
+	if len(repos) == 0 {
+		log.Warning("Refused to re-check the org which has no config.")
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("no config for the org: %s", v.Org))
+
+		return
+	}
+
+	if _, running := s.orgRechecks.LoadOrStore(v.Org, true); running {
+		writeJSONError(w, http.StatusConflict, "the org is being re-checked")
+
+		return
+	}
+
+	if !s.bot.handlers.accept() {
+		s.orgRechecks.Delete(v.Org)
+		writeJSONError(w, http.StatusServiceUnavailable, errShuttingDown.Error())
+
+		return
+	}
+
+	go func() {
+		defer func() {
+			s.orgRechecks.Delete(v.Org)
+			s.bot.handlers.done()
+		}()
+
+		log.Infof("Start re-checking the open prs of %d repos.", len(repos))
+
+		r := s.bot.recheckOrg(context.Background(), v.Org, repos, c, log)
+
+		log.WithFields(logrus.Fields{
+			"checked": r.checked,
+			"flipped": r.flipped,
+			"errored": r.errored,
+		}).Info("Finished re-checking the org.")
+	}()
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"org": v.Org, "repos": repos})
+}
+
+// recheckPRByNumber checks the PR like the event of it arrives, and reports what
+// the check found and did.
+func (bot *robot) recheckPRByNumber(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	trigger string,
+	log *logrus.Entry,
+) (*recheckResult, error) {
+	if org == "" || repo == "" || number <= 0 {
+		return nil, newAdminError(http.StatusBadRequest, "org, repo and number are required")
+	}
+
+	if !bot.handlers.accept() {
+		return nil, newAdminError(http.StatusServiceUnavailable, errShuttingDown.Error())
+	}
+	defer bot.handlers.done()
+
+	c, ok := bot.latestConfig.Load().(*configuration)
+	if !ok {
+		return nil, newAdminError(http.StatusServiceUnavailable, errConfigNotLoaded.Error())
+	}
+
+	v, err := bot.cli.GetGiteePullRequest(ctx, org, repo, number)
+	if err != nil {
+		if isClientError(err) {
+			return nil, newAdminError(http.StatusNotFound, "can't get the pr: %v", err)
+		}
+
+		return nil, newAdminError(http.StatusBadGateway, "can't get the pr: %v", err)
+	}
+
+	pr := toPullRequestHook(&v)
+	branch := pr.GetBase().GetRef()
+
+	cfg, err := bot.getConfig(c, org, repo, branch)
+	if err != nil {
+		return nil, newAdminError(http.StatusNotFound, err.Error())
+	}
+
+	if !cfg.TargetBranches.match(branch) {
+		return nil, newAdminError(http.StatusUnprocessableEntity, "the target branch: %s is not checked", branch)
+	}
+
+	if pr.GetState() != "open" {
+		return nil, newAdminError(http.StatusConflict, "the pr is not open")
+	}
+
+	defer bot.prLocks.lock(prKey(org, repo, number))()
+
+	before, err := bot.getPRLabels(ctx, org, repo, number)
+	if err != nil {
+		return nil, newAdminError(http.StatusBadGateway, "can't get the labels of pr: %v", err)
+	}
+
+	ctx, cancel := bot.newEventContext()
+	defer cancel()
+
+	report := new(checkReport)
+	err = bot.handle(withAuditTrigger(withCheckReport(ctx, report), trigger), org, repo, pr, cfg, nil, log)
+
+	r := &recheckResult{
+		Org:            org,
+		Repo:           repo,
+		Number:         number,
+		Verdict:        checkVerdict(report.state, report.waived, err),
+		UnsignedEmails: report.unsigned,
+	}
+
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	if after, err := bot.getPRLabels(ctx, org, repo, number); err == nil {
+		r.LabelsAdded = after.Difference(before).List()
+		r.LabelsRemoved = before.Difference(after).List()
+	}
+
+	return r, nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.WithError(err).Warning("Error writing the response.")
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, code int, reason string) {
+	writeJSON(w, code, map[string]string{"error": reason})
+}
+
+// runAdminServer serves the admin API in the background.
+func runAdminServer(address string, s *adminAPI) *http.Server {
+	srv := &http.Server{Addr: address, Handler: s.handler()}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.WithError(err).Error("Error serving the admin API.")
+		}
+	}()
+
+	return srv
+}
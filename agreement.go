@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	sdk "github.com/opensourceways/go-gitee/gitee"
+)
+
+const (
+	defaultAgreementName = "CLA"
+
+	agreementTypeIndividual = "individual"
+)
+
+type agreementConfig struct {
+	// Name is the name of agreement which is shown in the comment.
+	Name string `json:"name" required:"true"`
+
+	// Paths are the globs of files covered by this agreement, such as docs/*.md.
+	// A glob ending with "/**" matches all the files under the directory.
+	Paths []string `json:"paths" required:"true"`
+
+	// CheckURL is the url used to check whether the contributor has signed this agreement.
+	CheckURL string `json:"check_url" required:"true"`
+
+	// SignURL is the url used to sign this agreement.
+	SignURL string `json:"sign_url" required:"true"`
+
+	// FAQURL is the url of faq about this agreement.
+	FAQURL string `json:"faq_url" required:"true"`
+}
+
+func (a *agreementConfig) isDefault() bool {
+	return a.Name == ""
+}
+
+func (a *agreementConfig) displayName() string {
+	if a.isDefault() {
+		return defaultAgreementName
+	}
+
+	return a.Name
+}
+
+func (a *agreementConfig) covers(file string) bool {
+	for _, p := range a.Paths {
+		if matchPath(p, file) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchPath(pattern, file string) bool {
+	if dir := strings.TrimSuffix(pattern, "/**"); dir != pattern {
+		return strings.HasPrefix(file, dir+"/")
+	}
+
+	v, err := path.Match(pattern, file)
+
+	return err == nil && v
+}
+
+func validateAgreements(items []agreementConfig) error {
+	names := map[string]bool{}
+
+	for i := range items {
+		item := &items[i]
+
+		if item.Name == "" {
+			return errors.New("missing name of agreement")
+		}
+
+		if names[item.Name] {
+			return fmt.Errorf("duplicate agreement: %s", item.Name)
+		}
+		names[item.Name] = true
+
+		if len(item.Paths) == 0 {
+			return fmt.Errorf("missing paths of agreement: %s", item.Name)
+		}
+
+		for _, p := range item.Paths {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid path: %s of agreement: %s", p, item.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// defaultAgreement returns the agreement specified by the repo-level config.
+func (c *botConfig) defaultAgreement() agreementConfig {
+	return agreementConfig{
+		CheckURL: c.CheckURL,
+		SignURL:  c.SignURL,
+		FAQURL:   c.FAQURL,
+	}
+}
+
+// applicableAgreements returns the agreements which cover the changed files.
+// The default agreement applies when some file is covered by none of the agreements.
+func (c *botConfig) applicableAgreements(files []string) []agreementConfig {
+	if len(files) == 0 {
+		return []agreementConfig{c.defaultAgreement()}
+	}
+
+	applied := make([]bool, len(c.Agreements))
+	needDefault := false
+
+	for _, f := range files {
+		covered := false
+
+		for i := range c.Agreements {
+			if c.Agreements[i].covers(f) {
+				applied[i] = true
+				covered = true
+			}
+		}
+
+		if !covered {
+			needDefault = true
+		}
+	}
+
+	r := make([]agreementConfig, 0, len(c.Agreements)+1)
+	if needDefault {
+		r = append(r, c.defaultAgreement())
+	}
+
+	for i := range c.Agreements {
+		if applied[i] {
+			r = append(r, c.Agreements[i])
+		}
+	}
+
+	return r
+}
+
+const (
+	emailSigned   = "signed"
+	emailUnsigned = "unsigned"
+	emailExempt   = "exempt"
+	emailInvalid  = "invalid"
+	emailUnknown  = "unknown"
+)
+
+// agreementResult is the result of checking the commits against an agreement.
+type agreementResult struct {
+	agreement agreementConfig
+	signed    []*sdk.PullRequestCommits
+	unsigned  []unsignedCommit
+	unknown   []unknownCommit
+
+	// emails is the breakdown by the unique emails in the order they appear.
+	emails []emailResult
+
+	// faqs are the FAQ links picked for the unsigned commits. The FAQ url of
+	// agreement is used if it is empty.
+	faqs []faqLink
+
+	// stale means some emails use the last known results in the degraded mode.
+	stale bool
+}
+
+// faqLink is the FAQ url for the commits whose role, author or committer, is unsigned.
+// role is empty if the url is not specific to a role.
+type faqLink struct {
+	role string
+	url  string
+}
+
+// emailResult is the result of checking an email against an agreement.
+type emailResult struct {
+	email   string
+	role    string
+	status  string
+	commits []*sdk.PullRequestCommits
+
+	// signing is the detail of signing returned by the CLA service if signed.
+	signing signingInfo
+}
+
+// addEmailResult records the commit to the result of email, and returns the result.
+func (r *agreementResult) addEmailResult(c *sdk.PullRequestCommits, email, role, status string) *emailResult {
+	for i := range r.emails {
+		if item := &r.emails[i]; item.email == email && item.role == role {
+			item.commits = append(item.commits, c)
+
+			return item
+		}
+	}
+
+	r.emails = append(r.emails, emailResult{
+		email:   email,
+		role:    role,
+		status:  status,
+		commits: []*sdk.PullRequestCommits{c},
+	})
+
+	return &r.emails[len(r.emails)-1]
+}
+
+// signingInfo is the result of checking an email by the CLA service. The
+// metadata is optional, and is empty if the service returns only whether signed.
+type signingInfo struct {
+	Signed bool `json:"signed"`
+
+	// Corporation is the name of corporation whose CLA covers the email.
+	Corporation string `json:"corporation_name,omitempty"`
+
+	// AgreementType is the type of agreement signed, such as individual or corporation.
+	AgreementType string `json:"agreement_type,omitempty"`
+}
+
+// coveredByCorporation returns the name of corporation whose CLA covers the email.
+func (s *signingInfo) coveredByCorporation() string {
+	if s.Corporation == "" || s.AgreementType == agreementTypeIndividual {
+		return ""
+	}
+
+	return s.Corporation
+}
+
+// unsignedCommit is the commit whose author or committer has not signed.
+type unsignedCommit struct {
+	commit *sdk.PullRequestCommits
+	email  string
+	// role is the identity whose CLA was checked, author or committer.
+	role string
+	// login is the Gitee account of the identity, which is mentioned in the
+	// sign guide. It is empty if unknown.
+	login string
+}
+
+// unknownCommit is the commit whose author can't be verified.
+type unknownCommit struct {
+	commit *sdk.PullRequestCommits
+	email  string
+	reason error
+}
+
+// commitTimeLayouts are the formats of the dates of commit which Gitee returns.
+var commitTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05 -0700", "2006-01-02 15:04:05"}
+
+// commitTime returns when the commit was committed, or the authored time if
+// it is missing. It is the zero time if neither can be parsed.
+func commitTime(c *sdk.PullRequestCommits) time.Time {
+	if c.Commit == nil {
+		return time.Time{}
+	}
+
+	for _, u := range []*sdk.GitUser{c.Commit.Committer, c.Commit.Author} {
+		if u == nil || u.Date == "" {
+			continue
+		}
+
+		for _, layout := range commitTimeLayouts {
+			if t, err := time.Parse(layout, u.Date); err == nil {
+				return t
+			}
+		}
+	}
+
+	return time.Time{}
+}
+
+// sortUnsignedCommits sorts the unsigned commits of each result chronologically,
+// so that the list is stable across the pages and force-pushes. The ties and the
+// commits without time keep the order of API, and the latter are put at the end.
+func sortUnsignedCommits(results []agreementResult) {
+	for i := range results {
+		items := results[i].unsigned
+
+		times := make(map[*sdk.PullRequestCommits]time.Time, len(items))
+		for _, item := range items {
+			times[item.commit] = commitTime(item.commit)
+		}
+
+		sort.SliceStable(items, func(a, b int) bool {
+			ta, tb := times[items[a].commit], times[items[b].commit]
+			if ta.IsZero() || tb.IsZero() {
+				return !ta.IsZero() && tb.IsZero()
+			}
+
+			return ta.Before(tb)
+		})
+	}
+}
+
+func filterUnsigned(results []agreementResult) []agreementResult {
+	r := make([]agreementResult, 0, len(results))
+	for i := range results {
+		if len(results[i].unsigned) > 0 {
+			r = append(r, results[i])
+		}
+	}
+
+	return r
+}
+
+func filterUnknown(results []agreementResult) []agreementResult {
+	r := make([]agreementResult, 0, len(results))
+	for i := range results {
+		if len(results[i].unknown) > 0 {
+			r = append(r, results[i])
+		}
+	}
+
+	return r
+}
+
+func (bot *robot) getApplicableAgreements(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	cfg *botConfig,
+) ([]agreementConfig, error) {
+	if len(cfg.Agreements) == 0 {
+		return []agreementConfig{cfg.defaultAgreement()}, nil
+	}
+
+	files, err := bot.cli.GetPullRequestChanges(ctx, org, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(files))
+	for i := range files {
+		names[i] = files[i].Filename
+	}
+
+	return cfg.applicableAgreements(names), nil
+}
+
+// pickFAQs picks the FAQ links for the unsigned commits of each result. The url
+// specified for the agreement name is preferred, then the ones for the roles
+// whose signing is missing, and the FAQ url of agreement is the default.
+func (c *botConfig) pickFAQs(results []agreementResult) {
+	if len(c.FAQURLs) == 0 {
+		return
+	}
+
+	for i := range results {
+		item := &results[i]
+
+		if v, ok := c.FAQURLs[item.agreement.Name]; ok && !item.agreement.isDefault() {
+			item.faqs = []faqLink{{url: v}}
+
+			continue
+		}
+
+		seen := map[string]bool{}
+		links := []faqLink{}
+		for _, u := range item.unsigned {
+			if seen[u.role] {
+				continue
+			}
+			seen[u.role] = true
+
+			v, ok := c.FAQURLs[u.role]
+			if !ok {
+				v = item.agreement.FAQURL
+			}
+			links = append(links, faqLink{role: u.role, url: v})
+		}
+
+		// The role is not shown if all the roles share the same url.
+		if len(links) > 0 && sameFAQURL(links) {
+			links = []faqLink{{url: links[0].url}}
+		}
+
+		item.faqs = links
+	}
+}
+
+func sameFAQURL(links []faqLink) bool {
+	for i := range links {
+		if links[i].url != links[0].url {
+			return false
+		}
+	}
+
+	return true
+}
+
+func countUnsignedAuthors(results []agreementResult) int {
+	emails := map[string]bool{}
+	for i := range results {
+		for _, c := range results[i].unsigned {
+			emails[c.email] = true
+		}
+	}
+
+	return len(emails)
+}
+
+func countUnknownAuthors(results []agreementResult) int {
+	emails := map[string]bool{}
+	for i := range results {
+		for _, c := range results[i].unknown {
+			emails[c.email] = true
+		}
+	}
+
+	return len(emails)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	sdk "github.com/opensourceways/go-gitee/gitee"
+)
+
+func TestCommitTime(t *testing.T) {
+	want := time.Date(2022, 1, 17, 8, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		committer string
+		author    string
+		want      time.Time
+	}{
+		{name: "RFC3339", committer: "2022-01-17T16:30:00+08:00", want: want},
+		{name: "without zone", committer: "2022-01-17T08:30:00", want: want},
+		{name: "with space and zone", committer: "2022-01-17 16:30:00 +0800", want: want},
+		{name: "with space", committer: "2022-01-17 08:30:00", want: want},
+		{name: "committer date is missing", author: "2022-01-17T08:30:00Z", want: want},
+		{name: "committer date is preferred", committer: "2022-01-17T08:30:00Z", author: "2021-01-01T00:00:00Z", want: want},
+		{name: "committer date is invalid", committer: "yesterday", author: "2022-01-17T08:30:00Z", want: want},
+		{name: "all dates are missing"},
+		{name: "all dates are invalid", committer: "yesterday", author: "today"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &sdk.PullRequestCommits{Commit: &sdk.GitCommit{
+				Committer: &sdk.GitUser{Date: tc.committer},
+				Author:    &sdk.GitUser{Date: tc.author},
+			}}
+
+			if got := commitTime(c); !got.Equal(tc.want) {
+				t.Fatalf("expect %v, got %v", tc.want, got)
+			}
+		})
+	}
+
+	if got := commitTime(&sdk.PullRequestCommits{}); !got.IsZero() {
+		t.Fatalf("expect zero time for the commit without detail, got %v", got)
+	}
+}
+
+// datedCommit returns the unsigned commit of sha which was committed at date.
+func datedCommit(sha, date string) unsignedCommit {
+	return unsignedCommit{
+		commit: &sdk.PullRequestCommits{
+			Sha: sha,
+			Commit: &sdk.GitCommit{
+				Author:    &sdk.GitUser{Email: sha + "@example.com"},
+				Committer: &sdk.GitUser{Date: date},
+			},
+		},
+		email: sha + "@example.com",
+		role:  roleAuthor,
+	}
+}
+
+func TestSortUnsignedCommits(t *testing.T) {
+	cases := []struct {
+		name    string
+		commits []unsignedCommit
+		want    []string
+	}{
+		{
+			name: "chronological",
+			commits: []unsignedCommit{
+				datedCommit("c", "2022-01-03T00:00:00Z"),
+				datedCommit("a", "2022-01-01T00:00:00Z"),
+				datedCommit("b", "2022-01-02 00:00:00"),
+			},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "ties keep the order of API",
+			commits: []unsignedCommit{
+				datedCommit("b", "2022-01-01T00:00:00Z"),
+				datedCommit("c", "2022-01-02T00:00:00Z"),
+				datedCommit("a", "2022-01-01T08:00:00+08:00"),
+			},
+			want: []string{"b", "a", "c"},
+		},
+		{
+			name: "missing dates are put at the end",
+			commits: []unsignedCommit{
+				datedCommit("x", ""),
+				datedCommit("b", "2022-01-02T00:00:00Z"),
+				datedCommit("y", "unknown"),
+				datedCommit("a", "2022-01-01T00:00:00Z"),
+			},
+			want: []string{"a", "b", "x", "y"},
+		},
+		{
+			name: "all dates are missing",
+			commits: []unsignedCommit{
+				datedCommit("b", ""),
+				datedCommit("a", ""),
+			},
+			want: []string{"b", "a"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := []agreementResult{{unsigned: tc.commits}}
+			sortUnsignedCommits(results)
+
+			got := make([]string, len(results[0].unsigned))
+			for i, c := range results[0].unsigned {
+				got[i] = c.commit.Sha
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expect %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSortUnsignedCommitsIsDeterministic(t *testing.T) {
+	cfg := newTestConfig("https://example.com/check")
+
+	a := datedCommit("a", "2022-01-01T00:00:00Z")
+	b := datedCommit("b", "2022-01-02T00:00:00Z")
+	c := datedCommit("c", "2022-01-03T00:00:00Z")
+
+	// The same commits returned in different orders, such as after a force-push.
+	orders := [][]unsignedCommit{{a, b, c}, {c, b, a}, {b, c, a}}
+
+	var comment, fp string
+	for i, commits := range orders {
+		results := []agreementResult{{agreement: cfg.defaultAgreement(), unsigned: commits}}
+		sortUnsignedCommits(results)
+
+		s := generateUnSignComment(cfg, results[0].unsigned, len(commits))
+		f := unsignedFingerprint(results)
+
+		if i == 0 {
+			comment, fp = s, f
+
+			continue
+		}
+
+		if s != comment {
+			t.Fatalf("expect the same list %q, got %q", comment, s)
+		}
+		if f != fp {
+			t.Fatalf("expect the same fingerprint %s, got %s", fp, f)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The triggers of checks which are recorded by the audit log.
+const (
+	auditTriggerPR         = "pull_request"
+	auditTriggerNote       = "note"
+	auditTriggerRecheck    = "recheck"
+	auditTriggerReconcile  = "reconcile"
+	auditTriggerSigning    = "signing_hook"
+	auditTriggerBackground = "background"
+)
+
+var markerRe = regexp.MustCompile(`^<!-- cla-robot:([\w-]+) -->`)
+
+// auditRecord is a mutation of PR performed by the robot.
+type auditRecord struct {
+	Time   time.Time `json:"time"`
+	Org    string    `json:"org"`
+	Repo   string    `json:"repo"`
+	Number int32     `json:"number,omitempty"`
+
+	// Action is the mutation, such as add_label or create_comment.
+	Action string `json:"action"`
+
+	// Target is what the action applies to, such as the label or the id of comment.
+	Target string `json:"target,omitempty"`
+
+	// Reason is the code of decision, such as "unsigned:2 emails" or manual_override.
+	Reason string `json:"reason,omitempty"`
+
+	// Trigger is what starts the check, such as the type of event or the admin API.
+	Trigger string `json:"trigger"`
+
+	// Result is ok or the error.
+	Result string `json:"result"`
+}
+
+// auditDecision is the decision of a check which the mutations are made for.
+type auditDecision struct {
+	mu      sync.Mutex
+	trigger string
+	reason  string
+}
+
+func (d *auditDecision) setReason(format string, args ...interface{}) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	d.reason = fmt.Sprintf(format, args...)
+	d.mu.Unlock()
+}
+
+func (d *auditDecision) get() (string, string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.trigger, d.reason
+}
+
+// auditLog records every mutation of PRs. The decisions of the checks in
+// progress are registered by PR, which is safe since the checks of the same
+// PR are serialized by prLocks.
+type auditLog struct {
+	// decisions maps org/repo/number to *auditDecision.
+	decisions sync.Map
+
+	mu   sync.Mutex
+	file *os.File
+
+	// store keeps the records for the audit API. It is nil if disabled.
+	store *cacheStore
+}
+
+// newAuditLog returns the audit log which tees the records to the file of
+// path, in append-only mode, if path is not empty.
+func newAuditLog(path string) (*auditLog, error) {
+	a := new(auditLog)
+	if path == "" {
+		return a, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	a.file = f
+
+	return a, nil
+}
+
+// begin registers the decision of checking PR, and returns it with the
+// function to deregister it.
+func (a *auditLog) begin(org, repo string, number int32, trigger string) (*auditDecision, func()) {
+	if a == nil {
+		return nil, func() {}
+	}
+
+	key := prKey(org, repo, number)
+	d := &auditDecision{trigger: trigger}
+	a.decisions.Store(key, d)
+
+	return d, func() { a.decisions.Delete(key) }
+}
+
+// decisionOf returns the decision of PR. The PR is found by the only check
+// in progress of the repo if number is 0, such as updating a comment by id.
+func (a *auditLog) decisionOf(org, repo string, number int32) (int32, *auditDecision) {
+	if number > 0 {
+		if v, ok := a.decisions.Load(prKey(org, repo, number)); ok {
+			return number, v.(*auditDecision)
+		}
+
+		return number, nil
+	}
+
+	prefix := org + "/" + repo + "/"
+	var (
+		found int
+		d     *auditDecision
+	)
+
+	a.decisions.Range(func(k, v interface{}) bool {
+		if s := k.(string); strings.HasPrefix(s, prefix) {
+			found++
+			d = v.(*auditDecision)
+			fmt.Sscanf(strings.TrimPrefix(s, prefix), "%d", &number)
+		}
+
+		return found < 2
+	})
+
+	if found != 1 {
+		return 0, nil
+	}
+
+	return number, d
+}
+
+func (a *auditLog) record(org, repo string, number int32, action, target string, err error) {
+	r := auditRecord{
+		Time:    time.Now(),
+		Org:     org,
+		Repo:    repo,
+		Action:  action,
+		Target:  target,
+		Trigger: auditTriggerBackground,
+		Result:  "ok",
+	}
+
+	if err != nil {
+		r.Result = err.Error()
+	}
+
+	n, d := a.decisionOf(org, repo, number)
+	r.Number = n
+	if d != nil {
+		r.Trigger, r.Reason = d.get()
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"audit":   "mutation",
+		"org":     r.Org,
+		"repo":    r.Repo,
+		"number":  r.Number,
+		"action":  r.Action,
+		"target":  r.Target,
+		"reason":  r.Reason,
+		"trigger": r.Trigger,
+		"result":  r.Result,
+	}).Info("Audit.")
+
+	a.store.putAudit(&r)
+
+	if a.file == nil {
+		return
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, err := a.file.Write(append(b, '\n')); err != nil {
+		logrus.WithError(err).Error("Error writing the audit log.")
+	}
+}
+
+func (a *auditLog) close() {
+	if a != nil && a.file != nil {
+		a.file.Close()
+	}
+}
+
+type auditTriggerKey struct{}
+
+// withAuditTrigger sets the trigger of the checks running with ctx, which is
+// the event by default.
+func withAuditTrigger(ctx context.Context, trigger string) context.Context {
+	return context.WithValue(ctx, auditTriggerKey{}, trigger)
+}
+
+func auditTriggerOf(ctx context.Context, byCommand bool) string {
+	if v, ok := ctx.Value(auditTriggerKey{}).(string); ok {
+		return v
+	}
+
+	if byCommand {
+		return auditTriggerNote
+	}
+
+	return auditTriggerPR
+}
+
+// auditClient records the mutations of PRs to the audit log.
+type auditClient struct {
+	iClient
+
+	audit *auditLog
+}
+
+func newAuditClient(cli iClient, audit *auditLog) *auditClient {
+	return &auditClient{iClient: cli, audit: audit}
+}
+
+func (c *auditClient) AddPRLabel(ctx context.Context, org, repo string, number int32, label string) error {
+	err := c.iClient.AddPRLabel(ctx, org, repo, number, label)
+	c.audit.record(org, repo, number, "add_label", label, err)
+
+	return err
+}
+
+func (c *auditClient) RemovePRLabel(ctx context.Context, org, repo string, number int32, label string) error {
+	err := c.iClient.RemovePRLabel(ctx, org, repo, number, label)
+	c.audit.record(org, repo, number, "remove_label", label, err)
+
+	return err
+}
+
+func (c *auditClient) CreatePRComment(ctx context.Context, org, repo string, number int32, comment string) error {
+	err := c.iClient.CreatePRComment(ctx, org, repo, number, comment)
+	c.audit.record(org, repo, number, "create_comment", commentKind(comment), err)
+
+	return err
+}
+
+func (c *auditClient) CreatePRCommentWithID(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	comment string,
+) (int32, error) {
+	id, err := c.iClient.CreatePRCommentWithID(ctx, org, repo, number, comment)
+	c.audit.record(org, repo, number, "create_comment", fmt.Sprintf("%d %s", id, commentKind(comment)), err)
+
+	return id, err
+}
+
+func (c *auditClient) UpdatePRComment(ctx context.Context, org, repo string, commentID int32, comment string) error {
+	err := c.iClient.UpdatePRComment(ctx, org, repo, commentID, comment)
+	c.audit.record(org, repo, 0, "update_comment", fmt.Sprintf("%d %s", commentID, commentKind(comment)), err)
+
+	return err
+}
+
+func (c *auditClient) DeletePRComment(ctx context.Context, org, repo string, ID int32) error {
+	err := c.iClient.DeletePRComment(ctx, org, repo, ID)
+	c.audit.record(org, repo, 0, "delete_comment", fmt.Sprint(ID), err)
+
+	return err
+}
+
+func (c *auditClient) CreateCommitStatus(ctx context.Context, org, repo, sha string, status commitStatus) error {
+	err := c.iClient.CreateCommitStatus(ctx, org, repo, sha, status)
+	c.audit.record(org, repo, 0, "set_status", sha, err)
+
+	return err
+}
+
+func (c *auditClient) ClosePR(ctx context.Context, org, repo string, number int32) error {
+	err := c.iClient.ClosePR(ctx, org, repo, number)
+	c.audit.record(org, repo, number, "close_pr", "", err)
+
+	return err
+}
+
+// commentKind returns the kind of the marker of robot comment, or empty if none.
+func commentKind(comment string) string {
+	if m := markerRe.FindStringSubmatch(comment); m != nil {
+		return m[1]
+	}
+
+	return ""
+}
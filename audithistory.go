@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	bucketAuditRecords = "audit_records"
+
+	// auditPruneInterval is the interval of removing the audit records beyond
+	// the retention from the store.
+	auditPruneInterval = time.Hour
+
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+// auditSeq tells apart the records of the same PR at the same time.
+var auditSeq uint32
+
+// auditRecordKey is the key of record in the store. The records of a PR
+// share the prefix and are sorted by time.
+func auditRecordKey(r *auditRecord) string {
+	return fmt.Sprintf(
+		"%s%020d%06d", auditPRPrefix(r.Org, r.Repo, r.Number),
+		r.Time.UnixNano(), atomic.AddUint32(&auditSeq, 1)%1000000,
+	)
+}
+
+func auditPRPrefix(org, repo string, number int32) string {
+	return fmt.Sprintf("%s/%s/%d/", org, repo, number)
+}
+
+// putAudit writes the audit record to the store asynchronously.
+func (s *cacheStore) putAudit(r *auditRecord) {
+	if s == nil {
+		return
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+
+	s.enqueue(storeWrite{bucket: bucketAuditRecords, key: auditRecordKey(r), value: b})
+}
+
+// auditPage is a page of the audit history of PR.
+type auditPage struct {
+	Records []auditRecord `json:"records"`
+
+	// Next is the cursor of the next page. It is empty if it is the last page.
+	Next string `json:"next,omitempty"`
+}
+
+// auditHistory returns the records of PR not earlier than since in time
+// order, at most limit ones after the cursor.
+func (s *cacheStore) auditHistory(
+	org, repo string,
+	number int32,
+	since time.Time,
+	cursor string,
+	limit int,
+) (*auditPage, error) {
+	prefix := []byte(auditPRPrefix(org, repo, number))
+
+	start := append([]byte(nil), prefix...)
+	if !since.IsZero() {
+		start = append(start, fmt.Sprintf("%020d", since.UnixNano())...)
+	}
+	if cursor != "" {
+		if !bytes.HasPrefix([]byte(cursor), prefix) {
+			return nil, newAdminError(http.StatusBadRequest, "the cursor does not belong to the pr")
+		}
+
+		if c := []byte(cursor); bytes.Compare(c, start) > 0 {
+			start = c
+		}
+	}
+
+	page := &auditPage{Records: []auditRecord{}}
+	var lastKey []byte
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketAuditRecords))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		for k, v := c.Seek(start); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if cursor != "" && string(k) == cursor {
+				continue
+			}
+
+			if len(page.Records) == limit {
+				page.Next = string(lastKey)
+
+				return nil
+			}
+
+			var r auditRecord
+			if err := json.Unmarshal(v, &r); err != nil {
+				continue
+			}
+
+			page.Records = append(page.Records, r)
+			lastKey = append(lastKey[:0], k...)
+		}
+
+		return nil
+	})
+
+	return page, err
+}
+
+// pruneAudit removes the audit records earlier than before.
+func (s *cacheStore) pruneAudit(before time.Time) (int, error) {
+	var expired [][]byte
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketAuditRecords))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			var r auditRecord
+			if err := json.Unmarshal(v, &r); err != nil || r.Time.Before(before) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+
+			return nil
+		})
+	})
+	if err != nil || len(expired) == 0 {
+		return 0, err
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketAuditRecords))
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return len(expired), err
+}
+
+// runAuditPruner removes the audit records beyond the retention periodically
+// until stop is closed.
+func (s *cacheStore) runAuditPruner(retention time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(auditPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := s.pruneAudit(time.Now().Add(-retention))
+		if err != nil {
+			logrus.WithError(err).Warning("Error pruning the audit records.")
+		} else if n > 0 {
+			logrus.Infof("Pruned %d audit records beyond the retention.", n)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// auditHistory replies the actions the robot took on the PR in time order.
+func (s *adminAPI) auditHistory(caller string, w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "only GET is allowed")
+
+		return
+	}
+
+	store := s.bot.cacheStore
+	if store == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "the audit history is not stored, the cache store is disabled")
+
+		return
+	}
+
+	q := req.URL.Query()
+	org, repo := q.Get("org"), q.Get("repo")
+
+	number, err := strconv.Atoi(q.Get("number"))
+	if err != nil || org == "" || repo == "" || number < 0 {
+		writeJSONError(w, http.StatusBadRequest, "org, repo and number are required")
+
+		return
+	}
+
+	var since time.Time
+	if v := q.Get("since"); v != "" {
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "since must be in the format of RFC3339")
+
+			return
+		}
+	}
+
+	limit := defaultAuditPageSize
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > maxAuditPageSize {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("limit must be in [1, %d]", maxAuditPageSize))
+
+			return
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"audit":  "admin",
+		"caller": caller,
+		"remote": req.RemoteAddr,
+		"org":    org,
+		"repo":   repo,
+		"number": number,
+	}).Info("Read the audit history of pr.")
+
+	page, err := store.auditHistory(org, repo, int32(number), since, q.Get("cursor"), limit)
+	var ae *adminError
+	if errors.As(err, &ae) {
+		writeJSONError(w, ae.code, ae.reason)
+
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "can't read the audit history: "+err.Error())
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
@@ -29,12 +29,24 @@ update_repo(){
     $bazel run //:gazelle
 }
 
+# The build info is injected by the x_defs of go_binary with --stamp, and the
+# values come from publish/command_status.sh.
 build(){
     update_repo
 
     tips "build binary"
 
-    $bazel build --platforms=@io_bazel_rules_go//go/toolchain:linux_amd64 //:$robot_name
+    $bazel build --stamp --platforms=@io_bazel_rules_go//go/toolchain:linux_amd64 //:$robot_name
+}
+
+go_build(){
+    tips "build binary by go"
+
+    local ldflags="-X main.version=$(git describe --tags --always --dirty)"
+    ldflags="$ldflags -X main.gitSHA=$(git rev-parse HEAD)"
+    ldflags="$ldflags -X main.buildDate=$(date -u +%FT%TZ)"
+
+    go build -ldflags "$ldflags" -o $robot_name .
 }
 
 image(){
@@ -42,7 +54,7 @@ image(){
 
     tips "build image"
 
-    $bazel run --platforms=@io_bazel_rules_go//go/toolchain:linux_amd64 //:image
+    $bazel run --stamp --platforms=@io_bazel_rules_go//go/toolchain:linux_amd64 //:image
 }
 
 push_image(){
@@ -50,7 +62,7 @@ push_image(){
 
     tips "push image"
 
-    $bazel run --platforms=@io_bazel_rules_go//go/toolchain:linux_amd64 //:push_image
+    $bazel run --stamp --platforms=@io_bazel_rules_go//go/toolchain:linux_amd64 //:push_image
 }
 
 clean(){
@@ -64,6 +76,7 @@ usage: $me cmd
 supported cmd:
     clean: clean local environment.
     build: build binary.
+    go_build: build binary by go without bazel.
     image: build image.
     push_image: build and push image.
     help: show the usage for each commands.
@@ -79,6 +92,9 @@ EOF
         "build")
             echo "$me build"
             ;;
+        "go_build")
+            echo "$me go_build"
+            ;;
         "image")
             echo "$me image"
             ;;
@@ -116,6 +132,9 @@ case $cmd in
     "build")
         build $(fetch_parameter 2)
         ;;
+    "go_build")
+        go_build
+        ;;
     "image")
         image
         ;;
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+type cacheItem struct {
+	value    interface{}
+	expireAt time.Time
+}
+
+// ttlCache is a concurrency-safe cache whose items expire after ttl.
+type ttlCache struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	items map[string]cacheItem
+
+	// persist is called with the item set, such as writing it through to the
+	// disk. It must not block. It is nil if the cache is in memory only.
+	persist func(key string, v interface{}, expireAt time.Time)
+}
+
+func newTTLCache(ttl time.Duration) *ttlCache {
+	return &ttlCache{
+		ttl:   ttl,
+		items: map[string]cacheItem{},
+	}
+}
+
+func (c *ttlCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	if time.Now().After(item.expireAt) {
+		delete(c.items, key)
+
+		return nil, false
+	}
+
+	return item.value, true
+}
+
+func (c *ttlCache) set(key string, v interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	expireAt := now.Add(c.ttl)
+
+	// Evict the expired items lazily to keep the cache bounded.
+	for k, item := range c.items {
+		if now.After(item.expireAt) {
+			delete(c.items, k)
+		}
+	}
+
+	c.items[key] = cacheItem{value: v, expireAt: expireAt}
+
+	if c.persist != nil {
+		c.persist(key, v, expireAt)
+	}
+}
+
+// restore puts the item loaded from the disk with its original expiry, so
+// that it expires as if the process had not restarted. The expired one is
+// ignored, and the item is not persisted again.
+func (c *ttlCache) restore(key string, v interface{}, expireAt time.Time) bool {
+	if time.Now().After(expireAt) {
+		return false
+	}
+
+	c.mu.Lock()
+	c.items[key] = cacheItem{value: v, expireAt: expireAt}
+	c.mu.Unlock()
+
+	return true
+}
+
+// flush removes all the items and returns the number of them.
+func (c *ttlCache) flush() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := len(c.items)
+	c.items = map[string]cacheItem{}
+
+	return n
+}
+
+// removeIf removes the items whose keys satisfy f.
+func (c *ttlCache) removeIf(f func(key string) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for k := range c.items {
+		if f(k) {
+			delete(c.items, k)
+			n++
+		}
+	}
+
+	return n
+}
+
+func (c *ttlCache) remove(key string) {
+	c.mu.Lock()
+	delete(c.items, key)
+	c.mu.Unlock()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLCache(t *testing.T) {
+	cases := []struct {
+		name   string
+		ttl    time.Duration
+		wait   time.Duration
+		wantOK bool
+	}{
+		{name: "item is alive", ttl: time.Hour, wantOK: true},
+		{name: "item has expired", ttl: 10 * time.Millisecond, wait: 50 * time.Millisecond},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTTLCache(tc.ttl)
+			c.set("key", "value")
+
+			time.Sleep(tc.wait)
+
+			v, ok := c.get("key")
+			if ok != tc.wantOK {
+				t.Fatalf("expect hit: %v, got %v", tc.wantOK, ok)
+			}
+			if ok && v.(string) != "value" {
+				t.Fatalf("expect value, got %v", v)
+			}
+		})
+	}
+}
+
+func TestTTLCacheEvictsExpiredItems(t *testing.T) {
+	c := newTTLCache(10 * time.Millisecond)
+	c.set("old", 1)
+
+	time.Sleep(50 * time.Millisecond)
+
+	// The expired items are evicted when a new one is set.
+	c.set("new", 2)
+
+	if n := c.flush(); n != 1 {
+		t.Fatalf("expect 1 item left, got %d", n)
+	}
+}
+
+func TestTTLCacheRestore(t *testing.T) {
+	c := newTTLCache(time.Hour)
+
+	if c.restore("expired", 1, time.Now().Add(-time.Second)) {
+		t.Fatal("expect the expired item not to be restored")
+	}
+
+	if !c.restore("alive", 2, time.Now().Add(time.Minute)) {
+		t.Fatal("expect the alive item to be restored")
+	}
+
+	if v, ok := c.get("alive"); !ok || v.(int) != 2 {
+		t.Fatalf("expect the restored item, got %v", v)
+	}
+
+	if _, ok := c.get("expired"); ok {
+		t.Fatal("expect no expired item")
+	}
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	sdk "github.com/opensourceways/go-gitee/gitee"
+)
+
+const (
+	giteeAPIEndpoint = "https://gitee.com/api/v5"
+
+	// giteePageSize is the size of page when listing all the items.
+	giteePageSize = 100
+)
+
+// giteeClient calls the API of Gitee with the context of caller, so that the
+// in-flight request is stopped once the check is cancelled or timed out.
+type giteeClient struct {
+	getToken func() []byte
+	endpoint string
+	hc       http.Client
+}
+
+func newGiteeClient(getToken func() []byte) *giteeClient {
+	return &giteeClient{
+		getToken: getToken,
+		endpoint: giteeAPIEndpoint,
+		hc:       http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// giteeError is the failed response of Gitee.
+type giteeError struct {
+	code   int
+	status string
+	body   string
+}
+
+func (e *giteeError) Error() string {
+	return fmt.Sprintf("response has status %q and body %q", e.status, e.body)
+}
+
+// GetBot returns the user of token.
+func (c *giteeClient) GetBot(ctx context.Context) (sdk.User, error) {
+	var v sdk.User
+	err := c.get(ctx, "user", url.Values{}, &v)
+
+	return v, err
+}
+
+func (c *giteeClient) AddPRLabel(ctx context.Context, org, repo string, number int32, label string) error {
+	return c.post(ctx, fmt.Sprintf("repos/%s/%s/pulls/%d/labels", org, repo, number), []string{label}, nil)
+}
+
+// RemovePRLabel removes the label from PR. It succeeds if the label is absent.
+func (c *giteeClient) RemovePRLabel(ctx context.Context, org, repo string, number int32, label string) error {
+	// Gitee can't deal with the label which includes '/' unless it is escaped.
+	err := c.delete(ctx, fmt.Sprintf("repos/%s/%s/pulls/%d/labels/%s", org, repo, number, url.PathEscape(label)))
+	if statusCode(err) == http.StatusNotFound {
+		return nil
+	}
+
+	return err
+}
+
+func (c *giteeClient) CreatePRComment(ctx context.Context, org, repo string, number int32, comment string) error {
+	return c.post(
+		ctx, fmt.Sprintf("repos/%s/%s/pulls/%d/comments", org, repo, number),
+		map[string]string{"body": comment}, nil,
+	)
+}
+
+// CreatePRCommentWithID creates the comment and returns its id.
+func (c *giteeClient) CreatePRCommentWithID(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	comment string,
+) (int32, error) {
+	var v sdk.PullRequestComments
+
+	err := c.post(
+		ctx, fmt.Sprintf("repos/%s/%s/pulls/%d/comments", org, repo, number),
+		map[string]string{"body": comment}, &v,
+	)
+
+	return v.Id, err
+}
+
+func (c *giteeClient) UpdatePRComment(ctx context.Context, org, repo string, commentID int32, comment string) error {
+	return c.patch(
+		ctx, fmt.Sprintf("repos/%s/%s/pulls/comments/%d", org, repo, commentID),
+		map[string]string{"body": comment},
+	)
+}
+
+func (c *giteeClient) DeletePRComment(ctx context.Context, org, repo string, ID int32) error {
+	return c.delete(ctx, fmt.Sprintf("repos/%s/%s/pulls/comments/%d", org, repo, ID))
+}
+
+func (c *giteeClient) GetPRCommits(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+) ([]sdk.PullRequestCommits, error) {
+	var v []sdk.PullRequestCommits
+	err := c.get(ctx, fmt.Sprintf("repos/%s/%s/pulls/%d/commits", org, repo, number), url.Values{}, &v)
+
+	return v, err
+}
+
+func (c *giteeClient) GetPullRequestChanges(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+) ([]sdk.PullRequestFiles, error) {
+	var v []sdk.PullRequestFiles
+	err := c.get(ctx, fmt.Sprintf("repos/%s/%s/pulls/%d/files", org, repo, number), url.Values{}, &v)
+
+	return v, err
+}
+
+func (c *giteeClient) GetGiteePullRequest(ctx context.Context, org, repo string, number int32) (sdk.PullRequest, error) {
+	var v sdk.PullRequest
+	err := c.get(ctx, fmt.Sprintf("repos/%s/%s/pulls/%d", org, repo, number), url.Values{}, &v)
+
+	return v, err
+}
+
+func (c *giteeClient) GetPRLabels(ctx context.Context, org, repo string, number int32) ([]sdk.Label, error) {
+	path := fmt.Sprintf("repos/%s/%s/pulls/%d/labels", org, repo, number)
+
+	var r []sdk.Label
+	err := listAllPages(path, func(page int) (int, error) {
+		var v []sdk.Label
+		err := c.get(ctx, path, pageParams(page), &v)
+		r = append(r, v...)
+
+		return len(v), err
+	})
+
+	return r, err
+}
+
+// HasMergedPR checks whether the author has any merged PR in the repo.
+func (c *giteeClient) HasMergedPR(ctx context.Context, org, repo, author string) (bool, error) {
+	var v []sdk.PullRequest
+
+	err := c.get(
+		ctx, fmt.Sprintf("repos/%s/%s/pulls", org, repo),
+		url.Values{
+			"state":    []string{"merged"},
+			"author":   []string{author},
+			"per_page": []string{"1"},
+		},
+		&v,
+	)
+
+	return len(v) > 0, err
+}
+
+// FindUserByEmail searches the user by the email, and returns the login of user.
+// It is empty if the email doesn't match exactly one user.
+func (c *giteeClient) FindUserByEmail(ctx context.Context, email string) (string, error) {
+	var v []sdk.UserBasic
+
+	err := c.get(
+		ctx, "search/users",
+		url.Values{
+			"q":        []string{email},
+			"per_page": []string{"2"},
+		},
+		&v,
+	)
+	if err != nil || len(v) != 1 {
+		return "", err
+	}
+
+	return v[0].Login, nil
+}
+
+// CreateCommitStatus creates a status on the commit.
+func (c *giteeClient) CreateCommitStatus(ctx context.Context, org, repo, sha string, status commitStatus) error {
+	return c.post(ctx, fmt.Sprintf("repos/%s/%s/statuses/%s", org, repo, sha), status, nil)
+}
+
+func (c *giteeClient) CreateRepoLabel(ctx context.Context, org, repo, label, color string) error {
+	return c.post(
+		ctx, fmt.Sprintf("repos/%s/%s/labels", org, repo),
+		map[string]string{"name": label, "color": color}, nil,
+	)
+}
+
+func (c *giteeClient) GetUserPermissionsOfRepo(
+	ctx context.Context,
+	org, repo, login string,
+) (sdk.ProjectMemberPermission, error) {
+	var v sdk.ProjectMemberPermission
+	err := c.get(ctx, fmt.Sprintf("repos/%s/%s/collaborators/%s/permission", org, repo, login), url.Values{}, &v)
+
+	return v, err
+}
+
+// IsCollaborator checks whether the login is a collaborator of repo.
+func (c *giteeClient) IsCollaborator(ctx context.Context, owner, repo, login string) (bool, error) {
+	err := c.get(ctx, fmt.Sprintf("repos/%s/%s/collaborators/%s", owner, repo, login), url.Values{}, nil)
+	if err == nil {
+		return true, nil
+	}
+
+	if statusCode(err) == http.StatusNotFound {
+		return false, nil
+	}
+
+	return false, err
+}
+
+// ListPRCommentsByPage lists the comments of PR in the specified page.
+func (c *giteeClient) ListPRCommentsByPage(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	page, perPage int,
+) ([]sdk.PullRequestComments, error) {
+	var v []sdk.PullRequestComments
+
+	err := c.get(
+		ctx, fmt.Sprintf("repos/%s/%s/pulls/%d/comments", org, repo, number),
+		url.Values{
+			"page":     []string{fmt.Sprint(page)},
+			"per_page": []string{fmt.Sprint(perPage)},
+		},
+		&v,
+	)
+
+	return v, err
+}
+
+func (c *giteeClient) ListPROperationLogs(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+) ([]sdk.OperateLog, error) {
+	var v []sdk.OperateLog
+	err := c.get(ctx, fmt.Sprintf("repos/%s/%s/pulls/%d/operate_logs", org, repo, number), url.Values{}, &v)
+
+	return v, err
+}
+
+// ListOpenPRsByPage lists the open PRs of repo in the specified page.
+func (c *giteeClient) ListOpenPRsByPage(
+	ctx context.Context,
+	org, repo string,
+	page, perPage int,
+) ([]sdk.PullRequest, error) {
+	var v []sdk.PullRequest
+
+	err := c.get(
+		ctx, fmt.Sprintf("repos/%s/%s/pulls", org, repo),
+		url.Values{
+			"state":    []string{"open"},
+			"page":     []string{fmt.Sprint(page)},
+			"per_page": []string{fmt.Sprint(perPage)},
+		},
+		&v,
+	)
+
+	return v, err
+}
+
+func (c *giteeClient) GetRepos(ctx context.Context, org string) ([]sdk.Project, error) {
+	path := fmt.Sprintf("orgs/%s/repos", org)
+
+	var r []sdk.Project
+	err := listAllPages(path, func(page int) (int, error) {
+		var v []sdk.Project
+		err := c.get(ctx, path, pageParams(page), &v)
+		r = append(r, v...)
+
+		return len(v), err
+	})
+
+	return r, err
+}
+
+func (c *giteeClient) GetRepoLabels(ctx context.Context, owner, repo string) ([]sdk.Label, error) {
+	var v []sdk.Label
+	err := c.get(ctx, fmt.Sprintf("repos/%s/%s/labels", owner, repo), url.Values{}, &v)
+
+	return v, err
+}
+
+func (c *giteeClient) ClosePR(ctx context.Context, org, repo string, number int32) error {
+	return c.patch(
+		ctx, fmt.Sprintf("repos/%s/%s/pulls/%d", org, repo, number),
+		map[string]string{"state": "closed"},
+	)
+}
+
+func pageParams(page int) url.Values {
+	return url.Values{
+		"page":     []string{fmt.Sprint(page)},
+		"per_page": []string{fmt.Sprint(giteePageSize)},
+	}
+}
+
+// listAllPages calls list with the pages in turn until the last page, which
+// is the one with less items than giteePageSize.
+func listAllPages(path string, list func(page int) (int, error)) error {
+	for page := 1; ; page++ {
+		n, err := list(page)
+		if err != nil {
+			return fmt.Errorf("list %s at page %d: %w", path, page, err)
+		}
+
+		if n < giteePageSize {
+			return nil
+		}
+	}
+}
+
+func (c *giteeClient) get(ctx context.Context, path string, params url.Values, result interface{}) error {
+	return c.do(ctx, http.MethodGet, path, params, nil, result)
+}
+
+func (c *giteeClient) post(ctx context.Context, path string, body interface{}, result interface{}) error {
+	return c.do(ctx, http.MethodPost, path, url.Values{}, body, result)
+}
+
+func (c *giteeClient) patch(ctx context.Context, path string, body interface{}) error {
+	return c.do(ctx, http.MethodPatch, path, url.Values{}, body, nil)
+}
+
+func (c *giteeClient) delete(ctx context.Context, path string) error {
+	return c.do(ctx, http.MethodDelete, path, url.Values{}, nil, nil)
+}
+
+func (c *giteeClient) do(
+	ctx context.Context,
+	method, path string,
+	params url.Values,
+	body interface{},
+	result interface{},
+) error {
+	params.Set("access_token", string(c.getToken()))
+
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, method, fmt.Sprintf("%s/%s?%s", c.endpoint, path, params.Encode()), reader,
+	)
+	if err != nil {
+		return err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+
+	return parseResponse(resp, result)
+}
+
+func parseResponse(resp *http.Response, result interface{}) error {
+	defer resp.Body.Close()
+
+	rb, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &giteeError{code: resp.StatusCode, status: resp.Status, body: string(rb)}
+	}
+
+	if result == nil || len(rb) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(rb, result)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGiteeClientStopsOnCancel(t *testing.T) {
+	cases := []struct {
+		name string
+		call func(ctx context.Context, c *giteeClient) error
+	}{
+		{
+			name: "get",
+			call: func(ctx context.Context, c *giteeClient) error {
+				_, err := c.ListOpenPRsByPage(ctx, "org", "repo", 1, 1)
+
+				return err
+			},
+		},
+		{
+			name: "post",
+			call: func(ctx context.Context, c *giteeClient) error {
+				return c.CreatePRComment(ctx, "org", "repo", 1, "comment")
+			},
+		},
+		{
+			name: "patch",
+			call: func(ctx context.Context, c *giteeClient) error {
+				return c.UpdatePRComment(ctx, "org", "repo", 1, "comment")
+			},
+		},
+		{
+			name: "delete",
+			call: func(ctx context.Context, c *giteeClient) error {
+				return c.DeletePRComment(ctx, "org", "repo", 1)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			arrived := make(chan struct{})
+			release := make(chan struct{})
+
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(arrived)
+
+				select {
+				case <-r.Context().Done():
+				case <-release:
+				}
+			}))
+			defer s.Close()
+			// The handler may miss the disconnection, so it is released before closing the server.
+			defer close(release)
+
+			c := newGiteeClient(func() []byte { return []byte("token") })
+			c.endpoint = s.URL
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() { done <- tc.call(ctx, c) }()
+
+			<-arrived
+			cancel()
+
+			select {
+			case err := <-done:
+				if !errors.Is(err, context.Canceled) {
+					t.Fatalf("expect context.Canceled, got %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("the in-flight call is not stopped by the cancelled context")
+			}
+		})
+	}
+}
+
+func TestGiteeClientStatusCode(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		want   int
+	}{
+		{name: "ok", status: http.StatusOK, want: 0},
+		{name: "not found", status: http.StatusNotFound, want: http.StatusNotFound},
+		{name: "rate limited", status: http.StatusTooManyRequests, want: http.StatusTooManyRequests},
+		{name: "server error", status: http.StatusBadGateway, want: http.StatusBadGateway},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				w.Write([]byte("[]"))
+			}))
+			defer s.Close()
+
+			c := newGiteeClient(func() []byte { return []byte("token") })
+			c.endpoint = s.URL
+
+			_, err := c.ListOpenPRsByPage(context.Background(), "org", "repo", 1, 1)
+			if got := statusCode(err); got != tc.want {
+				t.Fatalf("expect status %d, got %d, err: %v", tc.want, got, err)
+			}
+		})
+	}
+}
+
+func TestGiteeClientRemoveAbsentLabel(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want := "/repos/org/repo/pulls/1/labels/kind%2Fbug"; r.URL.EscapedPath() != want {
+			t.Errorf("expect path %s, got %s", want, r.URL.EscapedPath())
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer s.Close()
+
+	c := newGiteeClient(func() []byte { return []byte("token") })
+	c.endpoint = s.URL
+
+	if err := c.RemovePRLabel(context.Background(), "org", "repo", 1, "kind/bug"); err != nil {
+		t.Fatalf("expect no error for the absent label, got %v", err)
+	}
+}
+
+func TestGiteeClientCreateCommentWithID(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/org/repo/pulls/1/comments" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 7, "body": "comment"}`))
+	}))
+	defer s.Close()
+
+	c := newGiteeClient(func() []byte { return []byte("token") })
+	c.endpoint = s.URL
+
+	id, err := c.CreatePRCommentWithID(context.Background(), "org", "repo", 1, "comment")
+	if err != nil || id != 7 {
+		t.Fatalf("expect id 7, got %d, err: %v", id, err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	cmdCheckCLA       = "/check-cla"
+	cmdCLAHelp        = "/cla-help"
+	cmdCLAExempt      = "/cla-exempt"
+	cmdCLAExemptEmail = "/cla-exempt-email"
+	cmdCLAStatus      = "/cla-status"
+)
+
+// commandRe matches the comments which may contain a command. It is a cheap
+// filter before parsing, and the commands are recognized by parseCommand.
+var commandRe = regexp.MustCompile(`(?m)^\s*/\S+`)
+
+type command struct {
+	// name is the canonical name of command, such as /check-cla.
+	name string
+
+	// args are the words following the command on the same line.
+	args []string
+}
+
+// parseCommand returns the first command recognized in the comment.
+// aliases maps the canonical name of command to its aliases, and the canonical
+// name is always recognized. The command must start a line and can be followed
+// by any text. The lines in code blocks or quoted are skipped to avoid false positives.
+func parseCommand(body string, aliases map[string][]string) (command, bool) {
+	type alias struct {
+		name  string
+		value string
+	}
+
+	all := make([]alias, 0, len(aliases))
+	for name, items := range aliases {
+		all = append(all, alias{name: name, value: name})
+
+		for _, v := range items {
+			if v = strings.ToLower(normalizeCommand(v)); v != "" {
+				all = append(all, alias{name: name, value: v})
+			}
+		}
+	}
+
+	// Match the longest alias first, such as "/cla check" before "/cla".
+	sort.Slice(all, func(i, j int) bool {
+		return len(all[i].value) > len(all[j].value)
+	})
+
+	inCodeBlock := false
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			inCodeBlock = !inCodeBlock
+			continue
+		}
+
+		if inCodeBlock || !strings.HasPrefix(line, "/") {
+			continue
+		}
+
+		line = normalizeCommand(line)
+		lower := strings.ToLower(line)
+
+		for _, item := range all {
+			if lower == item.value || strings.HasPrefix(lower, item.value+" ") {
+				return command{
+					name: item.name,
+					args: strings.Fields(line[len(item.value):]),
+				}, true
+			}
+		}
+	}
+
+	return command{}, false
+}
+
+// normalizeCommand collapses the spaces between the words.
+func normalizeCommand(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	aliases := map[string][]string{
+		cmdCheckCLA:       {"/checkcla", "/cla check"},
+		cmdCLAExempt:      nil,
+		cmdCLAExemptEmail: nil,
+	}
+
+	cases := []struct {
+		name string
+		body string
+		ok   bool
+		want command
+	}{
+		{name: "canonical", body: "/check-cla", ok: true, want: command{name: cmdCheckCLA}},
+		{name: "trailing text", body: "/check-cla please", ok: true, want: command{name: cmdCheckCLA, args: []string{"please"}}},
+		{name: "alias", body: "/checkcla", ok: true, want: command{name: cmdCheckCLA}},
+		{name: "alias of two words", body: "/cla   check now", ok: true, want: command{name: cmdCheckCLA, args: []string{"now"}}},
+		{name: "case insensitive", body: "/Check-CLA", ok: true, want: command{name: cmdCheckCLA}},
+		{name: "leading spaces", body: "  /check-cla", ok: true, want: command{name: cmdCheckCLA}},
+		{name: "later line", body: "I have signed.\n/check-cla", ok: true, want: command{name: cmdCheckCLA}},
+		{name: "longest first", body: "/cla-exempt-email a@b.com", ok: true, want: command{name: cmdCLAExemptEmail, args: []string{"a@b.com"}}},
+		{name: "prefix of other word", body: "/check-claim", ok: false},
+		{name: "not at the line start", body: "please run /check-cla", ok: false},
+		{name: "quoted", body: "> /check-cla", ok: false},
+		{name: "in code block", body: "```\n/check-cla\n```", ok: false},
+		{name: "after code block", body: "~~~\n/cla-exempt\n~~~\n/check-cla", ok: true, want: command{name: cmdCheckCLA}},
+		{name: "unknown alias", body: "/cla-check", ok: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := parseCommand(tc.body, aliases)
+			if ok != tc.ok {
+				t.Fatalf("expect recognized: %v, got %v", tc.ok, ok)
+			}
+
+			if !ok {
+				return
+			}
+
+			if len(got.args) == 0 {
+				got.args = nil
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expect %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,681 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	sdk "github.com/opensourceways/go-gitee/gitee"
+)
+
+const (
+	commentsPerPage    = 100
+	maxPagesOfComments = 50
+
+	// The kinds of hidden marker of comments created by the robot.
+	markerSignGuide     = "sign-guide"
+	markerAlreadySigned = "already-signed"
+	markerStillSigned   = "still-signed"
+	markerCheckFailed   = "check-failed"
+	markerLegacyPR      = "legacy-pr"
+	markerExempt        = "exempt"
+	markerLabelManaged  = "label-managed"
+	markerOverridden    = "overridden"
+	markerDraft         = "draft"
+	markerCooldown      = "cooldown"
+	markerCheckRefused  = "check-refused"
+	markerHelp          = "help"
+	markerExemptRefused = "exempt-refused"
+	markerEmailCheck    = "email-check"
+	markerCheckAll      = "check-all"
+	markerChecking      = "checking"
+	markerClosedPR      = "closed-pr"
+	markerStatus        = "status"
+	markerPermission    = "permission"
+	markerReminder      = "reminder"
+	markerCloseWarning  = "close-warning"
+	markerEscalation    = "escalation"
+
+	// The audit comments of exemption which are not cleaned up, see markerKinds.
+	markerExempted       = "exempted"
+	markerExemptRevoked  = "exempt-revoked"
+	markerEmailExemption = "email-exemption"
+
+	// markerOutdated is the comment superseded in the mode of preserving comments.
+	// It is never cleaned up.
+	markerOutdated = "outdated"
+
+	// markerAutoClosed is kept to restart the clock of closing when the PR is reopened.
+	markerAutoClosed = "auto-closed"
+)
+
+// markerKinds are all the kinds of comments created by the robot except
+// the audit comments of exemption, which are kept for the record.
+var markerKinds = []string{
+	markerSignGuide, markerAlreadySigned, markerStillSigned,
+	markerCheckFailed, markerLegacyPR, markerExempt, markerLabelManaged,
+	markerOverridden, markerDraft, markerCooldown, markerCheckRefused,
+	markerHelp, markerExemptRefused, markerEmailCheck, markerCheckAll,
+	markerChecking, markerClosedPR, markerStatus, markerPermission,
+	markerReminder, markerCloseWarning, markerEscalation,
+}
+
+// resultKinds are the kinds of comments which show the result of check.
+var resultKinds = []string{
+	markerSignGuide, markerAlreadySigned, markerStillSigned,
+	markerCheckFailed, markerExempt, markerOverridden,
+}
+
+// commentMarker returns the hidden marker which is put at the top of comment
+// created by the robot, so that the comment can be recognized regardless of its wording.
+func commentMarker(kind string) string {
+	return fmt.Sprintf("<!-- cla-robot:%s -->", kind)
+}
+
+func withMarker(kind, content string) string {
+	return commentMarker(kind) + "\n" + content
+}
+
+func hasMarker(body, kind string) bool {
+	return strings.HasPrefix(body, commentMarker(kind))
+}
+
+const (
+	triggerUpdate  = "pull request update"
+	triggerCommand = "/check-cla"
+)
+
+// checkInfoRe matches the info of check in both the formatting profiles.
+var checkInfoRe = regexp.MustCompile(`(?:<sub>)?Last checked at: [^<\n]*(?:</sub>)?`)
+
+// withCheckInfo appends when the check ran and what triggered it. It is outside
+// the fingerprint, so that it can be refreshed without changing the content.
+func withCheckInfo(content, trigger string) string {
+	return fmt.Sprintf(
+		"%s\n\n<sub>Last checked at: %s, triggered by: %s</sub>",
+		content, time.Now().UTC().Format(time.RFC3339), trigger,
+	)
+}
+
+// checkTrigger returns the trigger of check. The check notifying the author
+// even if signed is the one triggered by the command.
+func checkTrigger(byCommand bool) string {
+	if byCommand {
+		return triggerCommand
+	}
+
+	return triggerUpdate
+}
+
+// triggerNote is the comment of command which triggers the check.
+type triggerNote struct {
+	author    string
+	body      string
+	url       string
+	createdAt time.Time
+}
+
+func newTriggerNote(note *sdk.NoteHook) *triggerNote {
+	if note == nil {
+		return &triggerNote{}
+	}
+
+	return &triggerNote{
+		author:    note.GetUser().GetLogin(),
+		body:      note.GetBody(),
+		url:       note.HtmlUrl,
+		createdAt: note.CreatedAt,
+	}
+}
+
+// quote quotes the author, the time and the first line of the comment, which
+// links to the comment if its url is known, so that the reply can be told apart
+// on the busy PRs.
+func (t *triggerNote) quote(lang string) string {
+	if t.author == "" {
+		return ""
+	}
+
+	line := strings.TrimSpace(strings.SplitN(strings.TrimSpace(t.body), "\n", 2)[0])
+	if t.url != "" {
+		line = fmt.Sprintf("[%s](%s)", line, t.url)
+	}
+
+	when := ""
+	if !t.createdAt.IsZero() {
+		when = fmt.Sprintf(message(lang, msgQuotedCommentAt), t.createdAt.UTC().Format(time.RFC3339))
+	}
+
+	return fmt.Sprintf(message(lang, msgQuotedComment), t.author, when, line) + "\n\n"
+}
+
+var fingerprintRe = regexp.MustCompile(`<!-- cla-fingerprint: ([0-9a-f]+) -->`)
+
+// fingerprint computes a stable fingerprint of the items regardless of their order.
+func fingerprint(items []string) string {
+	v := make([]string, len(items))
+	copy(v, items)
+	sort.Strings(v)
+
+	h := sha256.Sum256([]byte(strings.Join(v, "\n")))
+
+	return hex.EncodeToString(h[:])
+}
+
+// withFingerprint embeds the fingerprint invisibly into the content.
+func withFingerprint(content, fp string) string {
+	return fmt.Sprintf("%s\n\n<!-- cla-fingerprint: %s -->", content, fp)
+}
+
+func getFingerprint(body string) string {
+	if m := fingerprintRe.FindStringSubmatch(body); len(m) == 2 {
+		return m[1]
+	}
+
+	return ""
+}
+
+func unsignedFingerprint(results []agreementResult) string {
+	items := []string{}
+	for i := range results {
+		name := results[i].agreement.displayName()
+
+		for _, c := range results[i].unsigned {
+			items = append(items, fmt.Sprintf("%s|%s|%s|%s", name, c.role, c.email, c.commit.Sha))
+		}
+	}
+
+	return fingerprint(items)
+}
+
+func unknownFingerprint(results []agreementResult) string {
+	items := []string{"unknown"}
+	for i := range results {
+		name := results[i].agreement.displayName()
+
+		for _, c := range results[i].unknown {
+			items = append(items, fmt.Sprintf("%s|%s|%s", name, c.email, c.commit.Sha))
+		}
+	}
+
+	return fingerprint(items)
+}
+
+// isBotComment checks whether the comment is the one of kind created by the robot.
+// The comments created before the marker was introduced are still matched
+// so that the historical comments can be cleaned up. They are all in English,
+// and the localized ones are recognized only by the marker.
+func isBotComment(body, kind string) bool {
+	if hasMarker(body, kind) {
+		return true
+	}
+
+	switch kind {
+	case markerSignGuide:
+		prefixes := []string{
+			signGuideTitle(langEN, false),
+			signGuideTitle(langEN, true),
+			"Thanks for your pull request. Before we can look at your pull request, you'll need to sign a Contributor License Agreement (CLA).",
+		}
+
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(body, prefix) {
+				return true
+			}
+		}
+
+	case markerCheckFailed:
+		return strings.HasPrefix(body, checkCLAErrorNoticeTitle(langEN))
+
+	case markerAlreadySigned:
+		return strings.HasPrefix(body, "***@") &&
+			strings.Contains(body, "thanks for your pull request. All authors of the commits have signed the CLA.")
+	}
+
+	return false
+}
+
+// findBotComments returns the comments of kind created by the robot, the newest one is the last.
+func findBotComments(comments []sdk.PullRequestComments, kind string) []*sdk.PullRequestComments {
+	r := make([]*sdk.PullRequestComments, 0, 1)
+	for i := range comments {
+		if item := &comments[i]; isBotComment(item.Body, kind) {
+			r = append(r, item)
+		}
+	}
+
+	n := len(r) - 1
+	for i := 0; i < n; i++ {
+		if r[i].Id > r[n].Id {
+			r[i], r[n] = r[n], r[i]
+		}
+	}
+
+	return r
+}
+
+// listAllPRComments walks all the pages of comments of PR. The number of pages
+// is bounded by maxPagesOfComments to avoid unbounded loops.
+func listAllPRComments(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	c iClient,
+) ([]sdk.PullRequestComments, error) {
+	var r []sdk.PullRequestComments
+
+	for page := 1; page <= maxPagesOfComments; page++ {
+		v, err := c.ListPRCommentsByPage(ctx, org, repo, number, page, commentsPerPage)
+		if err != nil {
+			return nil, err
+		}
+
+		r = append(r, v...)
+
+		if len(v) < commentsPerPage {
+			break
+		}
+	}
+
+	return r, nil
+}
+
+func deleteSignGuide(ctx context.Context, org string, repo string, number int32, cfg *botConfig, c iClient) {
+	v, err := listAllPRComments(ctx, org, repo, number, c)
+	if err != nil {
+		return
+	}
+
+	deleteLegacyComments(ctx, org, repo, v, cfg, c)
+	retireBotComments(ctx, org, repo, v, markerSignGuide, cfg, c)
+	retireBotComments(ctx, org, repo, v, markerCheckFailed, cfg, c)
+	retireBotComments(ctx, org, repo, v, markerOverridden, cfg, c)
+	retireBotComments(ctx, org, repo, v, markerReminder, cfg, c)
+	retireBotComments(ctx, org, repo, v, markerCloseWarning, cfg, c)
+}
+
+func deleteBotComments(
+	ctx context.Context,
+	org, repo string,
+	comments []sdk.PullRequestComments,
+	kind string,
+	c iClient,
+) {
+	for _, item := range findBotComments(comments, kind) {
+		_ = c.DeletePRComment(ctx, org, repo, item.Id)
+	}
+}
+
+// retireBotComments deletes the comments of kind, or marks them as outdated
+// if the comments are preserved.
+func retireBotComments(
+	ctx context.Context,
+	org, repo string,
+	comments []sdk.PullRequestComments,
+	kind string,
+	cfg *botConfig,
+	c iClient,
+) {
+	if !cfg.PreserveComments {
+		deleteBotComments(ctx, org, repo, comments, kind, c)
+
+		return
+	}
+
+	for _, item := range findBotComments(comments, kind) {
+		_ = c.UpdatePRComment(ctx, org, repo, item.Id, outdatedComment(cfg.CommentLanguage, kind, item.Body))
+	}
+}
+
+// outdatedComment collapses the superseded comment with its content kept. The
+// marker of kind is replaced, so that it will not be recognized as kind again.
+func outdatedComment(lang, kind, body string) string {
+	body = strings.TrimPrefix(stripFooter(body), commentMarker(kind))
+
+	return withMarker(
+		markerOutdated,
+		fmt.Sprintf(
+			"<details><summary>~~%s~~</summary>\n\n%s\n\n</details>",
+			outdatedCommentSummary(lang), strings.TrimSpace(body),
+		),
+	)
+}
+
+// supersedeSignGuides marks the sign guides as outdated if their fingerprint is
+// not fp, so that a new one will be created, and keeps the newest one otherwise.
+// It returns the comments without the ones marked.
+func supersedeSignGuides(
+	ctx context.Context,
+	org, repo string,
+	comments []sdk.PullRequestComments,
+	fp string,
+	cfg *botConfig,
+	c iClient,
+) []sdk.PullRequestComments {
+	items := findBotComments(comments, markerSignGuide)
+	n := len(items)
+	if n == 0 {
+		return comments
+	}
+
+	retired := items[:n-1]
+	if getFingerprint(stripFooter(items[n-1].Body)) != fp {
+		retired = items
+	}
+
+	ids := map[int32]bool{}
+	for _, item := range retired {
+		ids[item.Id] = true
+		_ = c.UpdatePRComment(ctx, org, repo, item.Id, outdatedComment(cfg.CommentLanguage, markerSignGuide, item.Body))
+	}
+
+	r := make([]sdk.PullRequestComments, 0, len(comments))
+	for i := range comments {
+		if !ids[comments[i].Id] {
+			r = append(r, comments[i])
+		}
+	}
+
+	return r
+}
+
+// deleteBotCommentsOfPR deletes all the comments created by the robot.
+func deleteBotCommentsOfPR(ctx context.Context, org, repo string, number int32, c iClient) error {
+	v, err := listAllPRComments(ctx, org, repo, number, c)
+	if err != nil {
+		return err
+	}
+
+	for _, kind := range markerKinds {
+		deleteBotComments(ctx, org, repo, v, kind, c)
+	}
+
+	return nil
+}
+
+// minimizeBotCommentsOfPR collapses all the comments created by the robot into one line.
+// The markers are kept so that the comments can be managed again when the PR is reopened.
+func minimizeBotCommentsOfPR(ctx context.Context, org, repo string, number int32, lang string, c iClient) error {
+	v, err := listAllPRComments(ctx, org, repo, number, c)
+	if err != nil {
+		return err
+	}
+
+	for _, kind := range markerKinds {
+		content := withMarker(kind, minimizedComment(lang))
+
+		for _, item := range findBotComments(v, kind) {
+			if stripFooter(item.Body) != content {
+				_ = c.UpdatePRComment(ctx, org, repo, item.Id, content)
+			}
+		}
+	}
+
+	return nil
+}
+
+// deleteLegacyComments deletes the comments created by the CLA bots used before.
+func deleteLegacyComments(
+	ctx context.Context,
+	org, repo string,
+	comments []sdk.PullRequestComments,
+	cfg *botConfig,
+	c iClient,
+) {
+	for i := range comments {
+		if item := &comments[i]; cfg.isLegacyComment(item) {
+			_ = c.DeletePRComment(ctx, org, repo, item.Id)
+		}
+	}
+}
+
+// updateSignGuide keeps at most one sign guide on the PR and removes the
+// stale already-signed comments, because the PR is not signed any more.
+// The sign guide will not be updated if its fingerprint is same as fp unless force is true.
+func updateSignGuide(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	content, fp string,
+	force bool,
+	cfg *botConfig,
+	c iClient,
+) error {
+	v, err := listAllPRComments(ctx, org, repo, number, c)
+	if err != nil {
+		return err
+	}
+
+	deleteLegacyComments(ctx, org, repo, v, cfg, c)
+	retireBotComments(ctx, org, repo, v, markerAlreadySigned, cfg, c)
+	retireBotComments(ctx, org, repo, v, markerStillSigned, cfg, c)
+	retireBotComments(ctx, org, repo, v, markerCheckFailed, cfg, c)
+	retireBotComments(ctx, org, repo, v, markerOverridden, cfg, c)
+
+	if cfg.PreserveComments {
+		v = supersedeSignGuides(ctx, org, repo, v, fp, cfg, c)
+	}
+
+	return upsertBotComment(
+		ctx, org, repo, number, v, markerSignGuide,
+		withFingerprint(content, fp), fp, force, c,
+	)
+}
+
+// updateAlreadySigned keeps at most one already-signed comment on the PR
+// and removes the sign guides.
+func updateAlreadySigned(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	content string,
+	cfg *botConfig,
+	c iClient,
+) error {
+	v, err := listAllPRComments(ctx, org, repo, number, c)
+	if err != nil {
+		return err
+	}
+
+	deleteLegacyComments(ctx, org, repo, v, cfg, c)
+	retireBotComments(ctx, org, repo, v, markerSignGuide, cfg, c)
+	retireBotComments(ctx, org, repo, v, markerCheckFailed, cfg, c)
+	retireBotComments(ctx, org, repo, v, markerOverridden, cfg, c)
+	retireBotComments(ctx, org, repo, v, markerReminder, cfg, c)
+	retireBotComments(ctx, org, repo, v, markerCloseWarning, cfg, c)
+
+	return upsertBotComment(ctx, org, repo, number, v, markerAlreadySigned, content, "", true, c)
+}
+
+// updateOverriddenNotice keeps at most one notice of manual override on the PR
+// and removes the sign guides, because the check is skipped.
+func updateOverriddenNotice(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	content string,
+	cfg *botConfig,
+	c iClient,
+) error {
+	v, err := listAllPRComments(ctx, org, repo, number, c)
+	if err != nil {
+		return err
+	}
+
+	deleteLegacyComments(ctx, org, repo, v, cfg, c)
+	retireBotComments(ctx, org, repo, v, markerSignGuide, cfg, c)
+	retireBotComments(ctx, org, repo, v, markerCheckFailed, cfg, c)
+	retireBotComments(ctx, org, repo, v, markerReminder, cfg, c)
+	retireBotComments(ctx, org, repo, v, markerCloseWarning, cfg, c)
+
+	return upsertBotComment(ctx, org, repo, number, v, markerOverridden, content, "", true, c)
+}
+
+// updateCheckCLAFailedNotice keeps at most one notice of checking CLA failed on the PR.
+// It is not re-posted on the subsequent failures, and will be removed on the
+// next successful check.
+func updateCheckCLAFailedNotice(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	content, fp string,
+	force bool,
+	c iClient,
+) error {
+	v, err := listAllPRComments(ctx, org, repo, number, c)
+	if err != nil {
+		return err
+	}
+
+	return upsertBotComment(
+		ctx, org, repo, number, v, markerCheckFailed,
+		withFingerprint(content, fp), fp, force, c,
+	)
+}
+
+// updateBotComment keeps at most one comment of kind on the PR.
+func updateBotComment(ctx context.Context, org, repo string, number int32, kind, content string, c iClient) error {
+	v, err := listAllPRComments(ctx, org, repo, number, c)
+	if err != nil {
+		return err
+	}
+
+	return upsertBotComment(ctx, org, repo, number, v, kind, content, "", true, c)
+}
+
+// replaceBotComment deletes the existing comments of kind and creates a new one,
+// so that the users will be notified.
+func replaceBotComment(ctx context.Context, org, repo string, number int32, kind, content string, c iClient) error {
+	v, err := listAllPRComments(ctx, org, repo, number, c)
+	if err != nil {
+		return err
+	}
+
+	deleteBotComments(ctx, org, repo, v, kind, c)
+
+	return c.CreatePRComment(ctx, org, repo, number, withMarker(kind, content))
+}
+
+// upsertBotComment updates the newest comment of kind in place and deletes the
+// duplicate ones. A new comment will be created only when there is no such comment.
+// The comment will not be updated if its fingerprint is same as fp unless force is true.
+func upsertBotComment(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	comments []sdk.PullRequestComments,
+	kind, content, fp string,
+	force bool,
+	c iClient,
+) error {
+	content = withMarker(kind, content)
+
+	items := findBotComments(comments, kind)
+	if len(items) == 0 {
+		return c.CreatePRComment(ctx, org, repo, number, content)
+	}
+
+	n := len(items) - 1
+	for _, item := range items[:n] {
+		_ = c.DeletePRComment(ctx, org, repo, item.Id)
+	}
+
+	// The footer is ignored, so that the comment will not be updated only because of it.
+	newest := items[n]
+	body := stripFooter(newest.Body)
+	if body == content {
+		return nil
+	}
+
+	// The content is not changed, and only the time of last check is refreshed.
+	if !force && fp != "" && getFingerprint(body) == fp {
+		old, info := checkInfoRe.FindString(body), checkInfoRe.FindString(content)
+		if old == "" || info == "" {
+			return nil
+		}
+
+		content = strings.Replace(body, old, info, 1)
+	}
+
+	return c.UpdatePRComment(ctx, org, repo, newest.Id, content)
+}
+
+// postPlaceholder posts the placeholder or updates the existing one, and returns its id.
+func postPlaceholder(ctx context.Context, org, repo string, number int32, content string, c iClient) (int32, error) {
+	v, err := listAllPRComments(ctx, org, repo, number, c)
+	if err != nil {
+		return 0, err
+	}
+
+	content = withMarker(markerChecking, content)
+
+	if items := findBotComments(v, markerChecking); len(items) > 0 {
+		id := items[len(items)-1].Id
+
+		return id, c.UpdatePRComment(ctx, org, repo, id, content)
+	}
+
+	return c.CreatePRCommentWithID(ctx, org, repo, number, content)
+}
+
+// resolvePlaceholder edits the placeholder into the newest result of check, so
+// that the result shows up where the user is waiting. The placeholder becomes
+// the fallback if there is no result, or is deleted if fallback is empty.
+// Nothing is deleted if the comments are preserved, and the superseded ones
+// are marked as outdated instead.
+func resolvePlaceholder(
+	ctx context.Context,
+	org, repo string,
+	number, id int32,
+	fallback string,
+	cfg *botConfig,
+	c iClient,
+) error {
+	v, err := listAllPRComments(ctx, org, repo, number, c)
+	if err != nil {
+		return err
+	}
+
+	var result *sdk.PullRequestComments
+	resultKind := ""
+	for _, kind := range resultKinds {
+		for _, item := range findBotComments(v, kind) {
+			if item.Id != id && (result == nil || item.Id > result.Id) {
+				result, resultKind = item, kind
+			}
+		}
+	}
+
+	if result != nil {
+		if err := c.UpdatePRComment(ctx, org, repo, id, result.Body); err != nil {
+			return err
+		}
+
+		if cfg.PreserveComments {
+			return c.UpdatePRComment(ctx, org, repo, result.Id, outdatedComment(cfg.CommentLanguage, resultKind, result.Body))
+		}
+
+		return c.DeletePRComment(ctx, org, repo, result.Id)
+	}
+
+	if fallback != "" {
+		return c.UpdatePRComment(ctx, org, repo, id, fallback)
+	}
+
+	if !cfg.PreserveComments {
+		return c.DeletePRComment(ctx, org, repo, id)
+	}
+
+	for i := range v {
+		if item := &v[i]; item.Id == id {
+			return c.UpdatePRComment(ctx, org, repo, id, outdatedComment(cfg.CommentLanguage, markerChecking, item.Body))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	sdk "github.com/opensourceways/go-gitee/gitee"
+)
+
+func TestPostPlaceholder(t *testing.T) {
+	cases := []struct {
+		name     string
+		comments []sdk.PullRequestComments
+		wantID   int32
+		wantOps  []string
+	}{
+		{
+			name:     "create",
+			comments: []sdk.PullRequestComments{{Id: 1, Body: "/check-cla"}},
+			wantID:   9,
+			wantOps:  []string{"create 9"},
+		},
+		{
+			name: "reuse the existing one",
+			comments: []sdk.PullRequestComments{
+				{Id: 1, Body: "/check-cla"},
+				{Id: 2, Body: withMarker(markerChecking, "checking")},
+			},
+			wantID:  2,
+			wantOps: []string{"update 2"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := &fakeClient{comments: tc.comments, newID: 9}
+
+			id, err := postPlaceholder(context.Background(), "org", "repo", 1, "checking", cli)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if id != tc.wantID {
+				t.Fatalf("expect id %d, got %d", tc.wantID, id)
+			}
+
+			if !reflect.DeepEqual(cli.ops, tc.wantOps) {
+				t.Fatalf("expect %v, got %v", tc.wantOps, cli.ops)
+			}
+		})
+	}
+}
+
+func TestResolvePlaceholder(t *testing.T) {
+	placeholder := sdk.PullRequestComments{Id: 2, Body: withMarker(markerChecking, "checking")}
+	result := sdk.PullRequestComments{Id: 3, Body: withMarker(markerSignGuide, "sign the CLA")}
+
+	cases := []struct {
+		name     string
+		comments []sdk.PullRequestComments
+		fallback string
+		preserve bool
+		wantOps  []string
+		outdated int32
+	}{
+		{
+			name:     "move the result",
+			comments: []sdk.PullRequestComments{placeholder, result},
+			wantOps:  []string{"update 2", "delete 3"},
+		},
+		{
+			name:     "move the result and preserve the original",
+			comments: []sdk.PullRequestComments{placeholder, result},
+			preserve: true,
+			wantOps:  []string{"update 2", "update 3"},
+			outdated: 3,
+		},
+		{
+			name:     "fallback",
+			comments: []sdk.PullRequestComments{placeholder},
+			fallback: "failed",
+			wantOps:  []string{"update 2"},
+		},
+		{
+			name:     "delete the placeholder",
+			comments: []sdk.PullRequestComments{placeholder},
+			wantOps:  []string{"delete 2"},
+		},
+		{
+			name:     "preserve the placeholder",
+			comments: []sdk.PullRequestComments{placeholder},
+			preserve: true,
+			wantOps:  []string{"update 2"},
+			outdated: 2,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := &fakeClient{comments: tc.comments}
+			cfg := &botConfig{PreserveComments: tc.preserve}
+
+			if err := resolvePlaceholder(context.Background(), "org", "repo", 1, 2, tc.fallback, cfg, cli); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(cli.ops, tc.wantOps) {
+				t.Fatalf("expect %v, got %v", tc.wantOps, cli.ops)
+			}
+
+			if tc.outdated != 0 && !strings.HasPrefix(cli.bodies[tc.outdated], commentMarker(markerOutdated)) {
+				t.Fatalf("expect comment %d marked as outdated, got %q", tc.outdated, cli.bodies[tc.outdated])
+			}
+		})
+	}
+}
+
+// commentsOfPages returns n comments of humans, and the sign guides are put
+// at the specified indexes.
+func commentsOfPages(n int, guides ...int) []sdk.PullRequestComments {
+	r := make([]sdk.PullRequestComments, n)
+	for i := range r {
+		r[i] = sdk.PullRequestComments{Id: int32(i + 1), Body: "LGTM"}
+	}
+
+	for _, i := range guides {
+		r[i].Body = withMarker(markerSignGuide, "sign the CLA")
+	}
+
+	return r
+}
+
+func TestDeleteSignGuideAcrossPages(t *testing.T) {
+	cases := []struct {
+		name      string
+		comments  []sdk.PullRequestComments
+		wantOps   []string
+		wantPages int
+	}{
+		{
+			name:      "one page",
+			comments:  commentsOfPages(3, 1),
+			wantOps:   []string{"delete 2"},
+			wantPages: 1,
+		},
+		{
+			name:      "guides scattered across three pages",
+			comments:  commentsOfPages(2*commentsPerPage+10, 0, commentsPerPage+5, 2*commentsPerPage+9),
+			wantOps:   []string{"delete 1", "delete 106", "delete 210"},
+			wantPages: 3,
+		},
+		{
+			name:      "full last page",
+			comments:  commentsOfPages(2*commentsPerPage, commentsPerPage-1, 2*commentsPerPage-1),
+			wantOps:   []string{"delete 100", "delete 200"},
+			wantPages: 3,
+		},
+		{
+			name:      "bounded pages",
+			comments:  commentsOfPages((maxPagesOfComments+2)*commentsPerPage, 0, (maxPagesOfComments+1)*commentsPerPage),
+			wantOps:   []string{"delete 1"},
+			wantPages: maxPagesOfComments,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := &fakeClient{comments: tc.comments}
+
+			deleteSignGuide(context.Background(), "org", "repo", 1, &botConfig{}, cli)
+
+			if !reflect.DeepEqual(cli.ops, tc.wantOps) {
+				t.Fatalf("expect %v, got %v", tc.wantOps, cli.ops)
+			}
+
+			if cli.listedPages != tc.wantPages {
+				t.Fatalf("expect %d pages listed, got %d", tc.wantPages, cli.listedPages)
+			}
+
+			// The cleanup is idempotent.
+			cli.ops = nil
+			deleteSignGuide(context.Background(), "org", "repo", 1, &botConfig{}, cli)
+
+			if len(cli.ops) != 0 {
+				t.Fatalf("expect nothing to delete again, got %v", cli.ops)
+			}
+		})
+	}
+}
+
+func TestIsBotComment(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		kind string
+		want bool
+	}{
+		{name: "marker", body: withMarker(markerSignGuide, "anything"), kind: markerSignGuide, want: true},
+		{name: "marker of other kind", body: withMarker(markerAlreadySigned, "anything"), kind: markerSignGuide, want: false},
+		{name: "marker not at the top", body: "quote:\n" + commentMarker(markerSignGuide), kind: markerSignGuide, want: false},
+		{name: "localized with marker", body: withMarker(markerSignGuide, signGuideTitle(langZhCN, false)), kind: markerSignGuide, want: true},
+		{name: "localized without marker", body: signGuideTitle(langZhCN, false), kind: markerSignGuide, want: false},
+		{name: "legacy guide", body: signGuideTitle(langEN, false) + "\n\nlist", kind: markerSignGuide, want: true},
+		{name: "legacy guide by committer", body: signGuideTitle(langEN, true) + "\n\nlist", kind: markerSignGuide, want: true},
+		{
+			name: "legacy guide of old wording",
+			body: "Thanks for your pull request. Before we can look at your pull request, you'll need to sign a Contributor License Agreement (CLA).",
+			kind: markerSignGuide,
+			want: true,
+		},
+		{name: "legacy error notice", body: checkCLAErrorNoticeTitle(langEN) + "\n\nlist", kind: markerCheckFailed, want: true},
+		{
+			name: "legacy already signed",
+			body: "***@alice, thanks for your pull request. All authors of the commits have signed the CLA. :+1:",
+			kind: markerAlreadySigned,
+			want: true,
+		},
+		{name: "legacy prefix of other kind", body: signGuideTitle(langEN, false), kind: markerAlreadySigned, want: false},
+		{name: "human", body: "please sign the CLA", kind: markerSignGuide, want: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isBotComment(tc.body, tc.kind); got != tc.want {
+				t.Fatalf("expect %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDeleteSignGuideMigratesLegacyComments(t *testing.T) {
+	cli := &fakeClient{comments: []sdk.PullRequestComments{
+		{Id: 1, Body: signGuideTitle(langEN, false) + "\n\nlist"},
+		{Id: 2, Body: "please sign the CLA"},
+		{Id: 3, Body: checkCLAErrorNoticeTitle(langEN)},
+		{Id: 4, Body: withMarker(markerSignGuide, signGuideTitle(langZhCN, false))},
+		{Id: 5, Body: withMarker(markerAlreadySigned, "signed")},
+	}}
+
+	deleteSignGuide(context.Background(), "org", "repo", 1, &botConfig{}, cli)
+
+	want := []string{"delete 1", "delete 4", "delete 3"}
+	if !reflect.DeepEqual(cli.ops, want) {
+		t.Fatalf("expect %v, got %v", want, cli.ops)
+	}
+}
+
+func TestDeleteSignGuideKeepsHumanComments(t *testing.T) {
+	cfg := &botConfig{
+		LegacyCommentPrefixes: []string{"Please sign the CLA"},
+		LegacyCommentAuthors:  []string{"old-cla-bot"},
+	}
+
+	cli := &fakeClient{comments: []sdk.PullRequestComments{
+		{Id: 1, Body: "Please sign the CLA at the link", User: &sdk.UserBasic{Login: "old-cla-bot"}},
+		{Id: 2, Body: "Please sign the CLA, @bob", User: &sdk.UserBasic{Login: "alice"}},
+		{Id: 3, Body: "LGTM", User: &sdk.UserBasic{Login: "old-cla-bot"}},
+	}}
+
+	deleteSignGuide(context.Background(), "org", "repo", 1, cfg, cli)
+
+	if want := []string{"delete 1"}; !reflect.DeepEqual(cli.ops, want) {
+		t.Fatalf("expect %v, got %v", want, cli.ops)
+	}
+
+	if len(cli.comments) != 2 || cli.comments[0].Id != 2 {
+		t.Fatalf("expect the comment of human kept, got %v", cli.comments)
+	}
+}
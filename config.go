@@ -2,9 +2,15 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/huaweicloud/golangsdk"
 	"github.com/opensourceways/community-robot-lib/config"
+	sdk "github.com/opensourceways/go-gitee/gitee"
 )
 
 type configuration struct {
@@ -66,6 +72,32 @@ type botConfig struct {
 	// the cla has not been signed
 	CLALabelNo string `json:"cla_label_no" required:"true"`
 
+	// CLALabelError is the cla label name for org/repos indicating
+	// the cla can't be checked, such as the CLA service is unavailable.
+	// It is optional and the labels will not be changed when the cla
+	// can't be checked if it is not set.
+	CLALabelError string `json:"cla_label_error,omitempty"`
+
+	// CLALabelChecking is the cla label name for org/repos indicating the cla
+	// is being checked. It is applied during the check and removed at the end.
+	// It is optional and disabled if it is not set.
+	CLALabelChecking string `json:"cla_label_checking,omitempty"`
+
+	// LabelColors are the colors of cla labels which are used to create the
+	// labels missing in the repo. The key is the label name and the value is
+	// the hex color without '#', such as 0e8a16. Gitee labels have no description.
+	LabelColors map[string]string `json:"label_colors,omitempty"`
+
+	// LabelOverridePermissions are the permissions of repo, such as admin, which
+	// allow the user to apply the cla/yes label manually. The label applied by
+	// other users will be reverted if the CLA check fails.
+	LabelOverridePermissions []string `json:"label_override_permissions,omitempty"`
+
+	// ManualOverrideLabel is the label, such as cla/manual-ok, which maintainers
+	// apply when an exception is approved. The CLA check is skipped and the PR is
+	// labeled as cla/yes while it is present. Removing it triggers a re-check.
+	ManualOverrideLabel string `json:"manual_override_label,omitempty"`
+
 	// CheckURL is the url used to check whether the contributor has signed cla
 	// The url has the format as https://**/{{org}}:{{repo}}?email={{email}}
 	CheckURL string `json:"check_url" required:"true"`
@@ -84,9 +116,291 @@ type botConfig struct {
 
 	// FAQURL is the url of faq which is corresponding to the way of checking CLA
 	FAQURL string `json:"faq_url" required:"true"`
+
+	// FAQURLs are the FAQ urls keyed by the role checked, author or committer,
+	// or by the name of agreement. The sign guide links to the ones of the
+	// unsigned commits, and faq_url is the default.
+	FAQURLs map[string]string `json:"faq_urls,omitempty"`
+
+	// LitePRMaxLines is the threshold of changed lines(additions plus deletions)
+	// under which a PR is treated as trivial and passes without checking CLA.
+	// 0 means disabling this feature.
+	LitePRMaxLines int `json:"lite_pr_max_lines,omitempty"`
+
+	// LitePRNote is the comment posted when a PR is treated as trivial.
+	LitePRNote string `json:"lite_pr_note,omitempty"`
+
+	// UnsignedBehavior decides what the robot does to show the CLA status.
+	// The valid values are "both", "label_only" and "comment_only". Default is "both".
+	UnsignedBehavior string `json:"unsigned_behavior,omitempty"`
+
+	// LabelsToRemoveWhenUnsigned are the labels, such as lgtm and approved, which
+	// will be removed when the PR becomes unsigned. They will not be re-added
+	// when the PR becomes signed later.
+	LabelsToRemoveWhenUnsigned []string `json:"labels_to_remove_when_unsigned,omitempty"`
+
+	// MentionAuthor means mentioning the author of PR in the sign guide,
+	// so that the author will be notified.
+	MentionAuthor bool `json:"mention_author,omitempty"`
+
+	// WelcomeFirstTimer means prepending the welcome paragraph to the sign guide
+	// if it is the first PR of the author in the repo.
+	WelcomeFirstTimer bool `json:"welcome_first_timer,omitempty"`
+
+	// FirstTimerWelcome is the welcome paragraph for the first-time contributor.
+	FirstTimerWelcome string `json:"first_timer_welcome,omitempty"`
+
+	// ExemptSameOrgSource means the PRs whose source branch lives in the same org
+	// as the target repo, rather than a fork of other namespace, are exempted from
+	// checking CLA, because they are created by the members who are already covered.
+	ExemptSameOrgSource bool `json:"exempt_same_org_source,omitempty"`
+
+	// LegacyCommentPrefixes are the prefixes of comments created by the CLA bots
+	// used before. Those comments will be deleted during the cleanup of sign guide.
+	LegacyCommentPrefixes []string `json:"legacy_comment_prefixes,omitempty"`
+
+	// LegacyCommentAuthors are the logins of the CLA bots used before. Only the
+	// comments created by them are deleted to avoid deleting the ones of human.
+	// It must be set when `legacy_comment_prefixes` is set.
+	LegacyCommentAuthors []string `json:"legacy_comment_authors,omitempty"`
+
+	// CleanupOnClose decides how to deal with the comments of robot when the PR
+	// is merged or closed. The valid values are "keep", "delete" and "minimize".
+	// "minimize" means collapsing the comments into one line. Default is "keep".
+	CleanupOnClose string `json:"cleanup_on_close,omitempty"`
+
+	// PreserveComments means marking the superseded comments of robot, such as
+	// the sign guides, as outdated instead of deleting them, so that the history
+	// of notifications is kept. Only one sign guide is active at any time.
+	PreserveComments bool `json:"preserve_comments,omitempty"`
+
+	// SetCommitStatus means setting the CLA status as a commit status on the
+	// head commit of PR, so that it can be used by the branch protection rules.
+	SetCommitStatus bool `json:"set_commit_status,omitempty"`
+
+	// CommitStatusContext is the context of commit status. Default is "cla/robot".
+	CommitStatusContext string `json:"commit_status_context,omitempty"`
+
+	// EnforceAfter is a RFC3339 time. The CLA is only enforced for the PRs
+	// created after it. It is disabled when empty.
+	EnforceAfter string `json:"enforce_after,omitempty"`
+
+	// LegacyPRBehavior decides how to deal with the PRs created before EnforceAfter.
+	// The valid values are "ignore" and "comment". "ignore" means doing nothing and
+	// "comment" means posting an informational comment once. Default is "ignore".
+	// Note: "/check-cla" always runs a full check regardless of it.
+	LegacyPRBehavior string `json:"legacy_pr_behavior,omitempty"`
+
+	// SkipDraft means the draft PRs are not checked until they are ready for review.
+	// Note: "/check-cla" always runs a full check regardless of it.
+	SkipDraft bool `json:"skip_draft,omitempty"`
+
+	// DraftNote is the comment posted once on the draft PR when skip_draft is true.
+	// Nothing is posted if it is empty.
+	DraftNote string `json:"draft_note,omitempty"`
+
+	// DebounceSeconds is the window in which the rapid updates of source branch
+	// of PR are coalesced into a single check against the latest head.
+	// Default is 30, and a negative value disables it. "/check-cla" is never debounced.
+	DebounceSeconds int `json:"debounce_seconds,omitempty"`
+
+	// TargetBranches filters the PRs by their target branches. The PRs
+	// filtered out are not touched at all. All the PRs are checked if it is not set.
+	TargetBranches targetBranchFilter `json:"target_branches,omitempty"`
+
+	// AllowedCheckRoles are the roles of users who can trigger the check by "/check-cla".
+	// The valid values are "author", "collaborator" and "anyone". Default is "anyone".
+	// The author of PR is always allowed.
+	AllowedCheckRoles []string `json:"allowed_check_roles,omitempty"`
+
+	// CheckCLAAliases are the aliases of "/check-cla", such as "/checkcla".
+	// Default is "/checkcla" and "/cla check".
+	CheckCLAAliases []string `json:"check_cla_aliases,omitempty"`
+
+	// DetectDrift means re-fetching the labels of PR after the check to compare
+	// them with the intended ones, and logging the mismatch.
+	DetectDrift bool `json:"detect_drift,omitempty"`
+
+	// AdminTeam is mentioned in the alert posted when the robot lacks the
+	// permissions on the repo, such as "@alice @bob".
+	AdminTeam string `json:"admin_team,omitempty"`
+
+	// MaskEmails means masking the emails shown in the comments, such as j***@example.com.
+	MaskEmails bool `json:"mask_emails,omitempty"`
+
+	// IgnoredCommenters are the logins of accounts, such as other robots, whose
+	// comments are ignored. The comments of the robot itself are always ignored.
+	IgnoredCommenters []string `json:"ignored_commenters,omitempty"`
+
+	// CheckCooldownSeconds is the window after a check triggered by "/check-cla",
+	// within which the later "/check-cla" will not re-run the check. Default is 60,
+	// the max is 3600, and a negative value disables it. The updates of PR are not limited.
+	CheckCooldownSeconds int `json:"check_cooldown_seconds,omitempty"`
+
+	// RemindAfterDays is the days after which the author of PR left unsigned
+	// is reminded by the periodic sweep. 0 means disabling it.
+	RemindAfterDays int `json:"remind_after_days,omitempty"`
+
+	// RemindIntervalDays is the min days between two reminders of a PR.
+	// Default is the value of remind_after_days.
+	RemindIntervalDays int `json:"remind_interval_days,omitempty"`
+
+	// CloseUnsignedAfterDays is the days after which the PR left unsigned is
+	// closed by the periodic sweep. 0 means disabling it. The exempted and
+	// overridden PRs are never closed, and reopening the PR restarts the clock.
+	CloseUnsignedAfterDays int `json:"close_unsigned_after_days,omitempty"`
+
+	// CloseWarningDays is the days before closing the PR left unsigned when
+	// the author is warned. Default is 7.
+	CloseWarningDays int `json:"close_warning_days,omitempty"`
+
+	// StaleLabel is the label added to the PR closed because it is left unsigned.
+	// Default is cla/stale.
+	StaleLabel string `json:"stale_label,omitempty"`
+
+	// EscalateAfterDays is the days after the sign guide was posted when the
+	// maintainers are mentioned on the PR still left unsigned. It is done at most
+	// once per PR by the periodic sweep. 0 means disabling it.
+	EscalateAfterDays int `json:"escalate_after_days,omitempty"`
+
+	// EscalationMentions are the logins or the team handles mentioned in the escalation.
+	// It must be set when escalate_after_days is set.
+	EscalationMentions []string `json:"escalation_mentions,omitempty"`
+
+	// PlainMessages means writing the comments in the minimal formatting profile,
+	// which has no emoji, no HTML and no tables, and writes the links out in full.
+	// It applies to the whole repo and can't be overridden by the branches.
+	PlainMessages bool `json:"plain_messages,omitempty"`
+
+	// MentionCommitAuthors means mentioning the Gitee accounts of the unsigned
+	// commits in the sign guide, so that they are notified even if they are not
+	// the author of PR. The accounts which can't be resolved are not mentioned.
+	MentionCommitAuthors bool `json:"mention_commit_authors,omitempty"`
+
+	// QuoteTriggerComment means quoting the "/check-cla" comment at the top of the
+	// reply, so that it is clear which command the result corresponds to.
+	QuoteTriggerComment bool `json:"quote_trigger_comment,omitempty"`
+
+	// CommentLanguage is the language of comments, which is en or zh_CN. Default is en.
+	CommentLanguage string `json:"comment_language,omitempty"`
+
+	// MaxCommentBytes is the budget of bytes of the sign guide, which must stay under
+	// the limit of comment body of Gitee. The commits listed are truncated to fit
+	// in it. Default is 60000.
+	MaxCommentBytes int `json:"max_comment_bytes,omitempty"`
+
+	// Templates are the overrides of the sign guide, the confirmation, the error
+	// notice and the help text in Go text/template, see templateData for the variables.
+	Templates messageTemplates `json:"templates,omitempty"`
+
+	// DegradedMode is the policy of checking when the CLA service fails.
+	DegradedMode degradedMode `json:"degraded_mode,omitempty"`
+
+	// Branches is the overrides of config for the PRs targeting the specified branches.
+	// The first one which matches the target branch of PR will be applied.
+	Branches []branchConfig `json:"branches,omitempty"`
+
+	// Agreements are the agreements which apply to the PRs changing the files
+	// matching their paths. The files matching none of them are covered by the
+	// repo-level agreement which is specified by check_url, sign_url and faq_url.
+	Agreements []agreementConfig `json:"agreements,omitempty"`
+
+	enforceAfter time.Time
+	templates    map[string]*template.Template
+}
+
+// matchedBranch returns the branch config which applies to the branch, or empty if none.
+func (c *botConfig) matchedBranch(branch string) string {
+	for i := range c.Branches {
+		if item := &c.Branches[i]; item.match(branch) {
+			return item.Branch
+		}
+	}
+
+	return ""
+}
+
+// configForBranch returns the config for the PR targeting the branch.
+// The fields which are not overridden are inherited from the repo-level config.
+func (c *botConfig) configForBranch(branch string) *botConfig {
+	for i := range c.Branches {
+		if item := &c.Branches[i]; item.match(branch) {
+			v := *c
+			item.override(&v)
+
+			return &v
+		}
+	}
+
+	return c
 }
 
 func (c *botConfig) setDefault() {
+	if c.LitePRNote == "" {
+		c.LitePRNote = "This pull request changes only a few lines, which qualifies as a trivial contribution. The CLA check is skipped."
+	}
+
+	if c.FirstTimerWelcome == "" {
+		c.FirstTimerWelcome = "Welcome! It looks like this is your first pull request here. " +
+			"Before we can accept it, you need to sign the Contributor License Agreement (CLA). " +
+			"It only needs to be done once, and all your later contributions will be covered."
+	}
+
+	if c.UnsignedBehavior == "" {
+		c.UnsignedBehavior = behaviorBoth
+	}
+
+	if c.CommitStatusContext == "" {
+		c.CommitStatusContext = "cla/robot"
+	}
+
+	if c.CleanupOnClose == "" {
+		c.CleanupOnClose = cleanupKeep
+	}
+
+	if c.LegacyPRBehavior == "" {
+		c.LegacyPRBehavior = legacyPRIgnore
+	}
+
+	if c.DebounceSeconds == 0 {
+		c.DebounceSeconds = 30
+	}
+
+	if c.DegradedMode.MaxAgeHours <= 0 {
+		c.DegradedMode.MaxAgeHours = 24
+	}
+
+	if c.CheckCooldownSeconds == 0 {
+		c.CheckCooldownSeconds = 60
+	}
+
+	if c.RemindIntervalDays <= 0 {
+		c.RemindIntervalDays = c.RemindAfterDays
+	}
+
+	if c.CloseWarningDays <= 0 {
+		c.CloseWarningDays = 7
+	}
+
+	if c.StaleLabel == "" {
+		c.StaleLabel = "cla/stale"
+	}
+
+	if c.CommentLanguage == "" {
+		c.CommentLanguage = langEN
+	}
+
+	if c.MaxCommentBytes <= 0 {
+		c.MaxCommentBytes = 60000
+	}
+
+	if len(c.CheckCLAAliases) == 0 {
+		c.CheckCLAAliases = []string{"/checkcla", "/cla check"}
+	}
+
+	if len(c.AllowedCheckRoles) == 0 {
+		c.AllowedCheckRoles = []string{checkRoleAnyone}
+	}
 }
 
 func (c *botConfig) validate() error {
@@ -100,9 +414,251 @@ func (c *botConfig) validate() error {
 		}
 	}
 
+	if c.LitePRMaxLines < 0 {
+		return errors.New("lite_pr_max_lines must be non-negative")
+	}
+
+	for _, v := range c.AllowedCheckRoles {
+		switch v {
+		case checkRoleAuthor, checkRoleCollaborator, checkRoleAnyone:
+		default:
+			return fmt.Errorf("invalid allowed_check_roles: %s", v)
+		}
+	}
+
+	for _, v := range c.CheckCLAAliases {
+		if !strings.HasPrefix(strings.TrimSpace(v), "/") {
+			return fmt.Errorf("invalid check_cla_aliases: %s", v)
+		}
+	}
+
+	if len(c.FAQURLs) > 0 && c.FAQURL == "" {
+		return errors.New("faq_url must be set as the default of faq_urls")
+	}
+
+	for k, v := range c.FAQURLs {
+		if v == "" {
+			return fmt.Errorf("empty url of faq_urls: %s", k)
+		}
+	}
+
+	templates, err := c.Templates.parse()
+	if err != nil {
+		return err
+	}
+	c.templates = templates
+
+	if c.CommentLanguage != "" && !isSupportedLanguage(c.CommentLanguage) {
+		return fmt.Errorf("unsupported comment_language: %s", c.CommentLanguage)
+	}
+
+	if n := c.CloseUnsignedAfterDays; n > 0 && c.CloseWarningDays >= n {
+		return errors.New("close_warning_days must be less than close_unsigned_after_days")
+	}
+
+	if l := c.CLALabelChecking; l != "" && (l == c.CLALabelYes || l == c.CLALabelNo || l == c.CLALabelError) {
+		return errors.New("cla_label_checking must be different from the other cla labels")
+	}
+
+	if c.EscalateAfterDays > 0 && len(c.EscalationMentions) == 0 {
+		return errors.New("missing escalation_mentions")
+	}
+
+	for _, v := range c.EscalationMentions {
+		if strings.TrimPrefix(v, "@") == "" {
+			return errors.New("empty login of escalation_mentions")
+		}
+	}
+
+	if c.DegradedMode.maxAge() > lastKnownResultTTL {
+		return fmt.Errorf("max_age_hours of degraded_mode must not be greater than %d", int(lastKnownResultTTL.Hours()))
+	}
+
+	if c.checkCooldown() > checkRecordTTL {
+		return fmt.Errorf("check_cooldown_seconds must not be greater than %d", int(checkRecordTTL.Seconds()))
+	}
+
+	if c.EnforceAfter != "" {
+		t, err := time.Parse(time.RFC3339, c.EnforceAfter)
+		if err != nil {
+			return fmt.Errorf("invalid enforce_after: %s", err.Error())
+		}
+		c.enforceAfter = t
+	}
+
+	if v := c.LegacyPRBehavior; v != legacyPRIgnore && v != legacyPRComment {
+		return fmt.Errorf("invalid legacy_pr_behavior: %s", v)
+	}
+
+	switch c.UnsignedBehavior {
+	case behaviorBoth, behaviorLabelOnly, behaviorCommentOnly:
+	default:
+		return fmt.Errorf("invalid unsigned_behavior: %s", c.UnsignedBehavior)
+	}
+
+	switch c.CleanupOnClose {
+	case cleanupKeep, cleanupDelete, cleanupMinimize:
+	default:
+		return fmt.Errorf("invalid cleanup_on_close: %s", c.CleanupOnClose)
+	}
+
+	if c.PreserveComments && c.CleanupOnClose == cleanupDelete {
+		return errors.New("cleanup_on_close can't be delete when preserve_comments is set")
+	}
+
+	if len(c.LegacyCommentPrefixes) > 0 && len(c.LegacyCommentAuthors) == 0 {
+		return errors.New("missing legacy_comment_authors")
+	}
+
+	if err := c.TargetBranches.validate(); err != nil {
+		return err
+	}
+
+	if err := validateBranches(c.Branches); err != nil {
+		return err
+	}
+
+	if err := validateAgreements(c.Agreements); err != nil {
+		return err
+	}
+
 	return c.RepoFilter.Validate()
 }
 
+func (c *botConfig) labelColor(label string) string {
+	if v, ok := c.LabelColors[label]; ok {
+		return v
+	}
+
+	switch label {
+	case c.CLALabelYes:
+		return "0e8a16"
+	case c.CLALabelNo:
+		return "b60205"
+	}
+
+	return "fbca04"
+}
+
+// displayEmail returns the email which is shown in the comments.
+func (c *botConfig) displayEmail(email string) string {
+	if !c.MaskEmails {
+		return email
+	}
+
+	return maskEmail(email)
+}
+
+func maskEmail(email string) string {
+	i := strings.LastIndex(email, "@")
+	if i <= 0 {
+		return "***"
+	}
+
+	return email[:1] + "***" + email[i:]
+}
+
+func (c *botConfig) isIgnoredCommenter(login string) bool {
+	for _, v := range c.IgnoredCommenters {
+		if v == login {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *botConfig) canOverrideLabel(permission string) bool {
+	for _, v := range c.LabelOverridePermissions {
+		if v == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *botConfig) debounceDelay() time.Duration {
+	return time.Duration(c.DebounceSeconds) * time.Second
+}
+
+// needSweep checks whether the open PRs need the periodic sweep.
+func (c *botConfig) needSweep() bool {
+	return c.RemindAfterDays > 0 || c.CloseUnsignedAfterDays > 0 || c.EscalateAfterDays > 0 ||
+		c.CLALabelChecking != ""
+}
+
+func (c *botConfig) checkCooldown() time.Duration {
+	return time.Duration(c.CheckCooldownSeconds) * time.Second
+}
+
+// commandAliases returns the aliases of commands which the robot understands.
+func (c *botConfig) commandAliases() map[string][]string {
+	return map[string][]string{
+		cmdCheckCLA:       c.CheckCLAAliases,
+		cmdCLAHelp:        nil,
+		cmdCLAExempt:      nil,
+		cmdCLAExemptEmail: nil,
+		cmdCLAStatus:      nil,
+	}
+}
+
+func (c *botConfig) labelEnabled() bool {
+	return c.UnsignedBehavior != behaviorCommentOnly
+}
+
+func (c *botConfig) commentEnabled() bool {
+	return c.UnsignedBehavior != behaviorLabelOnly
+}
+
+// isLegacyComment checks whether the comment was created by the CLA bots used before.
+func (c *botConfig) isLegacyComment(comment *sdk.PullRequestComments) bool {
+	if len(c.LegacyCommentPrefixes) == 0 || comment.User == nil {
+		return false
+	}
+
+	isAuthor := false
+	for _, v := range c.LegacyCommentAuthors {
+		if v == comment.User.Login {
+			isAuthor = true
+			break
+		}
+	}
+
+	if !isAuthor {
+		return false
+	}
+
+	for _, v := range c.LegacyCommentPrefixes {
+		if strings.HasPrefix(comment.Body, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isLegacyPR checks whether the PR was created before the CLA is enforced.
+func (c *botConfig) isLegacyPR(createdAt time.Time) bool {
+	return !c.enforceAfter.IsZero() && createdAt.Before(c.enforceAfter)
+}
+
+// degradedMode lets the checks trust the last known results of emails when
+// the CLA service fails, so that the PRs are not left unlabeled during an
+// outage. The emails without a fresh enough result are still unknown.
+type degradedMode struct {
+	// Enabled means using the last known results when the CLA service fails.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// MaxAgeHours is the max age of the last known result which is trusted.
+	// Default is 24, and the max is 168.
+	MaxAgeHours int `json:"max_age_hours,omitempty"`
+}
+
+func (d degradedMode) maxAge() time.Duration {
+	return time.Duration(d.MaxAgeHours) * time.Hour
+}
+
 type litePRCommiter struct {
 	// Email is the one of committer in a commit when a PR is lite
 	Email string `json:"email" required:"true"`
@@ -126,3 +682,134 @@ func (l litePRCommiter) validate() error {
 func (l litePRCommiter) isLitePR(email, name string) bool {
 	return email == l.Email || name == l.Name
 }
+
+type branchConfig struct {
+	// Branch is the name or glob of target branch, such as release-*.
+	Branch string `json:"branch" required:"true"`
+
+	// CLALabelYes overrides the one of repo-level config if it is set.
+	CLALabelYes string `json:"cla_label_yes,omitempty"`
+
+	// CLALabelNo overrides the one of repo-level config if it is set.
+	CLALabelNo string `json:"cla_label_no,omitempty"`
+
+	// CheckURL overrides the one of repo-level config if it is set.
+	CheckURL string `json:"check_url,omitempty"`
+
+	// SignURL overrides the one of repo-level config if it is set.
+	SignURL string `json:"sign_url,omitempty"`
+
+	// FAQURL overrides the one of repo-level config if it is set.
+	FAQURL string `json:"faq_url,omitempty"`
+}
+
+func (b *branchConfig) match(branch string) bool {
+	v, err := path.Match(b.Branch, branch)
+
+	return err == nil && v
+}
+
+func (b *branchConfig) override(c *botConfig) {
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+
+	set(&c.CLALabelYes, b.CLALabelYes)
+	set(&c.CLALabelNo, b.CLALabelNo)
+	set(&c.CheckURL, b.CheckURL)
+	set(&c.SignURL, b.SignURL)
+	set(&c.FAQURL, b.FAQURL)
+}
+
+func validateBranches(items []branchConfig) error {
+	for i := range items {
+		p := items[i].Branch
+		if p == "" {
+			return errors.New("missing branch")
+		}
+
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid branch: %s", p)
+		}
+
+		for j := 0; j < i; j++ {
+			if globsOverlap(items[j].Branch, p) {
+				return fmt.Errorf("branch %s overlaps with %s", p, items[j].Branch)
+			}
+		}
+	}
+
+	return nil
+}
+
+// globsOverlap checks whether there is a branch which both of the globs match,
+// such as release-* and *-1.0 which both match release-1.0. The branch config
+// to apply would be ambiguous for such branch, so the overlapped globs are
+// rejected. The globs which can't be parsed are treated as overlapped.
+func globsOverlap(a, b string) bool {
+	if a == b {
+		return true
+	}
+
+	ta, err := parseGlob(a)
+	if err != nil {
+		return true
+	}
+
+	tb, err := parseGlob(b)
+	if err != nil {
+		return true
+	}
+
+	return globsIntersect(ta, tb)
+}
+
+type targetBranchFilter struct {
+	// Include are the names or globs of target branches which require the CLA.
+	// All the branches are included if it is empty.
+	Include []string `json:"include,omitempty"`
+
+	// Exclude are the names or globs of target branches which don't require the CLA.
+	// It takes precedence over Include.
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+func (f *targetBranchFilter) match(branch string) bool {
+	m := func(patterns []string) bool {
+		for _, p := range patterns {
+			if v, err := path.Match(p, branch); err == nil && v {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if m(f.Exclude) {
+		return false
+	}
+
+	return len(f.Include) == 0 || m(f.Include)
+}
+
+func (f *targetBranchFilter) validate() error {
+	for _, items := range [][]string{f.Include, f.Exclude} {
+		for _, p := range items {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid target branch: %s", p)
+			}
+		}
+	}
+
+	for _, p := range f.Include {
+		for _, q := range f.Exclude {
+			if p == q {
+				return fmt.Errorf("target branch %s is both included and excluded", p)
+			}
+		}
+	}
+
+	return nil
+}
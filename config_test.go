@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	sdk "github.com/opensourceways/go-gitee/gitee"
+)
+
+func TestIsLegacyComment(t *testing.T) {
+	cfg := &botConfig{
+		LegacyCommentPrefixes: []string{"Please sign the CLA", "CLA check:"},
+		LegacyCommentAuthors:  []string{"old-cla-bot", "another-bot"},
+	}
+
+	cases := []struct {
+		name    string
+		cfg     *botConfig
+		comment sdk.PullRequestComments
+		want    bool
+	}{
+		{
+			name:    "by legacy bot",
+			cfg:     cfg,
+			comment: sdk.PullRequestComments{Body: "Please sign the CLA first", User: &sdk.UserBasic{Login: "old-cla-bot"}},
+			want:    true,
+		},
+		{
+			name:    "other prefix by other legacy bot",
+			cfg:     cfg,
+			comment: sdk.PullRequestComments{Body: "CLA check: failed", User: &sdk.UserBasic{Login: "another-bot"}},
+			want:    true,
+		},
+		{
+			name:    "by human",
+			cfg:     cfg,
+			comment: sdk.PullRequestComments{Body: "Please sign the CLA, thanks", User: &sdk.UserBasic{Login: "alice"}},
+			want:    false,
+		},
+		{
+			name:    "unmatched prefix",
+			cfg:     cfg,
+			comment: sdk.PullRequestComments{Body: "LGTM", User: &sdk.UserBasic{Login: "old-cla-bot"}},
+			want:    false,
+		},
+		{
+			name:    "prefix not at the beginning",
+			cfg:     cfg,
+			comment: sdk.PullRequestComments{Body: "> Please sign the CLA", User: &sdk.UserBasic{Login: "old-cla-bot"}},
+			want:    false,
+		},
+		{
+			name:    "unknown author",
+			cfg:     cfg,
+			comment: sdk.PullRequestComments{Body: "Please sign the CLA"},
+			want:    false,
+		},
+		{
+			name:    "no prefixes",
+			cfg:     &botConfig{LegacyCommentAuthors: []string{"old-cla-bot"}},
+			comment: sdk.PullRequestComments{Body: "Please sign the CLA", User: &sdk.UserBasic{Login: "old-cla-bot"}},
+			want:    false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.cfg.isLegacyComment(&tc.comment); got != tc.want {
+				t.Fatalf("expect %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
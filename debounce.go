@@ -0,0 +1,91 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+type debouncedTask struct {
+	timer *time.Timer
+	f     func()
+}
+
+// debouncer coalesces the tasks of same key submitted within the delay,
+// and only the latest one will run when the delay elapses.
+type debouncer struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	tasks   map[string]*debouncedTask
+	stopped bool
+}
+
+func newDebouncer() *debouncer {
+	return &debouncer{tasks: map[string]*debouncedTask{}}
+}
+
+// submit schedules f to run after the delay. It replaces the pending task
+// of key and postpones it. f runs immediately once the debouncer is stopped.
+func (d *debouncer) submit(key string, delay time.Duration, f func()) {
+	d.mu.Lock()
+
+	if d.stopped {
+		d.mu.Unlock()
+		f()
+
+		return
+	}
+
+	// The timer can't be stopped if it has fired, then the task
+	// is running and a new one is needed.
+	if t, ok := d.tasks[key]; ok && t.timer.Stop() {
+		t.f = f
+		t.timer.Reset(delay)
+		d.mu.Unlock()
+
+		return
+	}
+
+	t := &debouncedTask{f: f}
+	d.tasks[key] = t
+	d.wg.Add(1)
+	t.timer = time.AfterFunc(delay, func() { d.run(key, t) })
+
+	d.mu.Unlock()
+}
+
+func (d *debouncer) run(key string, t *debouncedTask) {
+	defer d.wg.Done()
+
+	d.mu.Lock()
+	if d.tasks[key] == t {
+		delete(d.tasks, key)
+	}
+	f := t.f
+	d.mu.Unlock()
+
+	f()
+}
+
+// stop runs all the pending tasks at once and waits for them to finish.
+func (d *debouncer) stop() {
+	d.mu.Lock()
+
+	d.stopped = true
+
+	pending := make([]*debouncedTask, 0, len(d.tasks))
+	for k, t := range d.tasks {
+		if t.timer.Stop() {
+			pending = append(pending, t)
+		}
+		delete(d.tasks, k)
+	}
+
+	d.mu.Unlock()
+
+	for _, t := range pending {
+		t.f()
+		d.wg.Done()
+	}
+
+	d.wg.Wait()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDebouncer(t *testing.T) {
+	type submission struct {
+		key   string
+		value string
+	}
+
+	cases := []struct {
+		name        string
+		submissions []submission
+		want        []string
+	}{
+		{
+			name:        "rapid updates of same PR are coalesced into the latest",
+			submissions: []submission{{"pr1", "a"}, {"pr1", "b"}, {"pr1", "c"}},
+			want:        []string{"c"},
+		},
+		{
+			name:        "updates of different PRs are kept",
+			submissions: []submission{{"pr1", "a"}, {"pr2", "b"}, {"pr1", "c"}},
+			want:        []string{"b", "c"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var got []string
+
+			d := newDebouncer()
+			for _, s := range tc.submissions {
+				v := s.value
+				d.submit(s.key, 50*time.Millisecond, func() {
+					mu.Lock()
+					got = append(got, v)
+					mu.Unlock()
+				})
+			}
+
+			time.Sleep(200 * time.Millisecond)
+			d.stop()
+
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expect %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDebouncerStop(t *testing.T) {
+	d := newDebouncer()
+
+	ran := make(chan string, 2)
+	d.submit("pr1", time.Hour, func() { ran <- "pending" })
+
+	// The pending task runs at once instead of waiting for the delay.
+	d.stop()
+
+	select {
+	case v := <-ran:
+		if v != "pending" {
+			t.Fatalf("expect the pending task, got %s", v)
+		}
+	default:
+		t.Fatal("expect the pending task to run on stop")
+	}
+
+	// The task submitted after stop runs immediately.
+	d.submit("pr1", time.Hour, func() { ran <- "late" })
+
+	select {
+	case v := <-ran:
+		if v != "late" {
+			t.Fatalf("expect the late task, got %s", v)
+		}
+	default:
+		t.Fatal("expect the task submitted after stop to run immediately")
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	sdk "github.com/opensourceways/go-gitee/gitee"
+)
+
+// maxSeenEvents bounds the number of events remembered for the deduplication.
+const maxSeenEvents = 4096
+
+// eventDeduper remembers the recently seen events in a bounded LRU, so that
+// the events redelivered by Gitee on timeouts are handled only once.
+type eventDeduper struct {
+	mu     sync.Mutex
+	window time.Duration
+	// order keeps the keys from the most recently seen to the least.
+	order *list.List
+	items map[string]*list.Element
+}
+
+type seenEvent struct {
+	key string
+	at  time.Time
+}
+
+// newEventDeduper returns nil, which dedupes nothing, if window is not positive.
+func newEventDeduper(window time.Duration) *eventDeduper {
+	if window <= 0 {
+		return nil
+	}
+
+	return &eventDeduper{
+		window: window,
+		order:  list.New(),
+		items:  map[string]*list.Element{},
+	}
+}
+
+// isDuplicate records the event of key, and reports whether it has been seen
+// within the window. The empty key means the event can't be identified
+// reliably, and it is never regarded as a duplicate.
+func (d *eventDeduper) isDuplicate(key string) bool {
+	if d == nil || key == "" {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+
+	if e, ok := d.items[key]; ok {
+		v := e.Value.(*seenEvent)
+		if now.Sub(v.at) <= d.window {
+			d.order.MoveToFront(e)
+
+			return true
+		}
+
+		v.at = now
+		d.order.MoveToFront(e)
+
+		return false
+	}
+
+	d.items[key] = d.order.PushFront(&seenEvent{key: key, at: now})
+
+	for d.order.Len() > maxSeenEvents {
+		e := d.order.Back()
+		d.order.Remove(e)
+		delete(d.items, e.Value.(*seenEvent).key)
+	}
+
+	return false
+}
+
+// forget removes the event of key, so that its redelivery will be handled,
+// such as when handling it failed.
+func (d *eventDeduper) forget(key string) {
+	if d == nil || key == "" {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if e, ok := d.items[key]; ok {
+		d.order.Remove(e)
+		delete(d.items, key)
+	}
+}
+
+// prEventKey identifies the PR event by the state of PR it carries. It is empty
+// if the time of updating PR is missing, since the label updates of the same
+// head would not be told apart then.
+func prEventKey(e *sdk.PullRequestEvent) string {
+	pr := e.GetPullRequest()
+	if pr == nil || pr.Number == 0 || pr.UpdatedAt.IsZero() {
+		return ""
+	}
+
+	org, repo := e.GetOrgRepo()
+
+	return fmt.Sprintf(
+		"pr/%s/%s/%d/%s/%s/%s/%d",
+		org, repo, pr.Number, e.GetAction(), e.GetActionDesc(),
+		pr.GetHead().GetSha(), pr.UpdatedAt.UnixNano(),
+	)
+}
+
+// noteEventKey identifies the note event by the comment. The edited comment
+// is a new event, since the time of updating it changes.
+func noteEventKey(e *sdk.NoteEvent) string {
+	c := e.GetComment()
+	if c == nil || c.GetID() == 0 {
+		return ""
+	}
+
+	org, repo := e.GetOrgRepo()
+
+	action := ""
+	if e.Action != nil {
+		action = *e.Action
+	}
+
+	return fmt.Sprintf("note/%s/%s/%d/%s/%d", org, repo, c.GetID(), action, c.UpdatedAt.UnixNano())
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// lastKnownResultTTL is how long the last result of checking an email is
+// kept for the degraded mode. It bounds max_age_hours of degraded_mode.
+const lastKnownResultTTL = 7 * 24 * time.Hour
+
+// lastKnownResult is the last result of checking an email which the CLA
+// service answered.
+type lastKnownResult struct {
+	Signing   signingInfo `json:"signing"`
+	CheckedAt time.Time   `json:"checked_at"`
+}
+
+// rememberResult records the result of live check for the degraded mode.
+func (bot *robot) rememberResult(checkURL, email string, signing signingInfo) {
+	bot.lastKnown.set(signedEmailKey(checkURL, email), lastKnownResult{
+		Signing:   signing,
+		CheckedAt: time.Now(),
+	})
+}
+
+// lastKnownResultOf returns the last result of checking the email which is
+// not older than maxAge.
+func (bot *robot) lastKnownResultOf(checkURL, email string, maxAge time.Duration) (signingInfo, bool) {
+	v, ok := bot.lastKnown.get(signedEmailKey(checkURL, email))
+	if !ok {
+		return signingInfo{}, false
+	}
+
+	r := v.(lastKnownResult)
+	if time.Since(r.CheckedAt) > maxAge {
+		return signingInfo{}, false
+	}
+
+	return r.Signing, true
+}
+
+// degradedTracker tracks the hosts of CLA service which the checks fall back
+// to the last known results for. A host leaves the degraded mode once a live
+// check of it succeeds.
+type degradedTracker struct {
+	mu sync.Mutex
+	// since maps the host to when the time spent degraded was counted last.
+	since map[string]time.Time
+}
+
+func newDegradedTracker() *degradedTracker {
+	return &degradedTracker{since: map[string]time.Time{}}
+}
+
+// degrade records that a check of the CLA service falls back to the last known result.
+func (t *degradedTracker) degrade(checkURL string) {
+	host := checkerHost(checkURL)
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	degradedResults.WithLabelValues(host).Inc()
+
+	if last, ok := t.since[host]; ok {
+		degradedSeconds.WithLabelValues(host).Add(now.Sub(last).Seconds())
+	} else {
+		degradedActive.WithLabelValues(host).Set(1)
+	}
+
+	t.since[host] = now
+}
+
+// recover ends the degraded mode of the CLA service since a live check succeeds.
+func (t *degradedTracker) recover(checkURL string) {
+	host := checkerHost(checkURL)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last, ok := t.since[host]
+	if !ok {
+		return
+	}
+
+	degradedSeconds.WithLabelValues(host).Add(time.Since(last).Seconds())
+	degradedActive.WithLabelValues(host).Set(0)
+	delete(t.since, host)
+}
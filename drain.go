@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const drainPollInterval = 100 * time.Millisecond
+
+var errShuttingDown = errors.New("the robot is shutting down")
+
+// handlerTracker tracks the in-flight handlers, so that they can be drained
+// before the process exits instead of being killed midway.
+type handlerTracker struct {
+	mu     sync.Mutex
+	n      int
+	closed bool
+
+	// ctx is the context of the handlers, which is canceled once they are
+	// abandoned, so that they stop before the process exits.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// context returns the context which the handlers should derive from.
+func (t *handlerTracker) context() context.Context {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.initContext()
+
+	return t.ctx
+}
+
+func (t *handlerTracker) initContext() {
+	if t.ctx == nil {
+		t.ctx, t.cancel = context.WithCancel(context.Background())
+	}
+}
+
+// accept tracks a new handler of event. It returns false once the tracker is closed.
+func (t *handlerTracker) accept() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return false
+	}
+
+	t.n++
+
+	return true
+}
+
+// track tracks the handler even if the tracker is closed, such as the
+// pending checks flushed on shutdown.
+func (t *handlerTracker) track() {
+	t.mu.Lock()
+	t.n++
+	t.mu.Unlock()
+}
+
+func (t *handlerTracker) done() {
+	t.mu.Lock()
+	t.n--
+	t.mu.Unlock()
+}
+
+func (t *handlerTracker) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.n
+}
+
+// close stops accepting the new handlers and returns the number of in-flight ones.
+func (t *handlerTracker) close() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+
+	return t.n
+}
+
+// wait waits for the in-flight handlers at most timeout and returns the number
+// of handlers abandoned, whose contexts are canceled.
+func (t *handlerTracker) wait(timeout time.Duration) int {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		n := t.count()
+		if n == 0 {
+			return n
+		}
+
+		if !time.Now().Before(deadline) {
+			t.abort()
+
+			return n
+		}
+
+		time.Sleep(drainPollInterval)
+	}
+}
+
+func (t *handlerTracker) abort() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.initContext()
+	t.cancel()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHandlerTrackerDrain(t *testing.T) {
+	cases := []struct {
+		name          string
+		inFlight      int
+		finishAfter   time.Duration
+		timeout       time.Duration
+		wantAbandoned int
+	}{
+		{name: "nothing in flight", timeout: time.Second},
+		{name: "handlers finish in time", inFlight: 2, finishAfter: 50 * time.Millisecond, timeout: 2 * time.Second},
+		{name: "handlers are abandoned", inFlight: 2, finishAfter: time.Hour, timeout: 150 * time.Millisecond, wantAbandoned: 2},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tracker := &handlerTracker{}
+
+			for i := 0; i < tc.inFlight; i++ {
+				if !tracker.accept() {
+					t.Fatal("expect the handler to be accepted before closing")
+				}
+
+				time.AfterFunc(tc.finishAfter, tracker.done)
+			}
+
+			if n := tracker.close(); n != tc.inFlight {
+				t.Fatalf("expect %d in-flight handlers, got %d", tc.inFlight, n)
+			}
+
+			// The new events are refused once it is draining.
+			if tracker.accept() {
+				t.Fatal("expect the handler to be refused after closing")
+			}
+
+			if n := tracker.wait(tc.timeout); n != tc.wantAbandoned {
+				t.Fatalf("expect %d abandoned handlers, got %d", tc.wantAbandoned, n)
+			}
+
+			// The abandoned handlers are canceled, so that they stop before the process exits.
+			if canceled := tracker.context().Err() != nil; canceled != (tc.wantAbandoned > 0) {
+				t.Fatalf("expect the handlers canceled to be %t", tc.wantAbandoned > 0)
+			}
+		})
+	}
+}
+
+func TestHandlerTrackerTrackAfterClose(t *testing.T) {
+	tracker := &handlerTracker{}
+	tracker.close()
+
+	// The pending checks flushed on shutdown are still waited for.
+	tracker.track()
+	if n := tracker.count(); n != 1 {
+		t.Fatalf("expect 1 in-flight handler, got %d", n)
+	}
+
+	tracker.done()
+	if n := tracker.wait(time.Second); n != 0 {
+		t.Fatalf("expect no abandoned handler, got %d", n)
+	}
+}
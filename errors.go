@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrCheckerUnavailable means the CLA service fails temporarily, so it is worth retrying.
+	ErrCheckerUnavailable = errors.New("cla checker is unavailable")
+
+	// ErrBadConfig means the config is wrong, and retrying is pointless until it is fixed.
+	ErrBadConfig = errors.New("bad config")
+
+	// ErrGiteePermission means the robot lacks the permissions on the repo.
+	ErrGiteePermission = errors.New("no permission on gitee")
+
+	// errNoCommits means the commits of PR can't be retrieved yet.
+	errNoCommits = errors.New("commits is empty, cla cannot be checked")
+)
+
+// isPermanentError reports whether err will fail again if retrying.
+func isPermanentError(err error) bool {
+	return errors.Is(err, ErrBadConfig) || errors.Is(err, ErrGiteePermission)
+}
+
+// classifyGiteeError wraps the error of calling Gitee with ErrGiteePermission
+// if it is caused by the lack of permissions.
+func classifyGiteeError(err error) error {
+	if err == nil || !isPermissionError(err) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %v", ErrGiteePermission, err)
+}
+
+// isClientError reports whether err is a 4xx failure of Gitee caused by the
+// request itself, such as the content rejected. The 429 and the failures of
+// permission are not, because sending another content won't help.
+func isClientError(err error) bool {
+	code := statusCode(err)
+
+	return code >= 400 && code < 500 && code != http.StatusTooManyRequests && !isPermissionError(err)
+}
+
+// responseBody returns the body of the failed response of Gitee carried by err.
+func responseBody(err error) string {
+	var e *giteeError
+	if errors.As(err, &e) {
+		return e.body
+	}
+
+	return ""
+}
+
+// statusCode returns the HTTP status code of the failed response of Gitee
+// carried by err. It is 0 if err is not caused by a response, such as the
+// network errors.
+func statusCode(err error) int {
+	var e *giteeError
+	if errors.As(err, &e) {
+		return e.code
+	}
+
+	return 0
+}
+
+// classifyCheckerStatus classifies the failure of CLA service by the HTTP status code.
+// The 429 and 5xx are transient, and 404 means the check url is wrong.
+func classifyCheckerStatus(code int, err error) error {
+	switch {
+	case code == 429 || code >= 500:
+		return fmt.Errorf("%w: %v", ErrCheckerUnavailable, err)
+	case code == 404:
+		return fmt.Errorf("%w: %v", ErrBadConfig, err)
+	default:
+		return err
+	}
+}
+
+// checkerUnavailableError returns the first failure of CLA service which is transient.
+func checkerUnavailableError(unknown []agreementResult) error {
+	for i := range unknown {
+		for _, c := range unknown[i].unknown {
+			if errors.Is(c.reason, ErrCheckerUnavailable) {
+				return c.reason
+			}
+		}
+	}
+
+	return nil
+}
+
+// settleError swallows the permanent errors after reporting them to the admins
+// once per repo a day, since retrying is pointless until they are fixed. The
+// transient errors are returned so that the upstream can retry.
+func (bot *robot) settleError(org, repo string, err error, log *logrus.Entry) error {
+	if err == nil || !isPermanentError(err) {
+		return err
+	}
+
+	kind := ErrBadConfig
+	if errors.Is(err, ErrGiteePermission) {
+		kind = ErrGiteePermission
+	}
+
+	key := fmt.Sprintf("%s/%s/%s", org, repo, kind)
+	if _, ok := bot.errorAlerts.get(key); !ok {
+		bot.errorAlerts.set(key, true)
+
+		log.WithError(err).WithField("admin_alert", true).Error("Permanent failure, it will not be retried.")
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestIsClientError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "no error", err: nil, want: false},
+		{name: "bad request", err: giteeStatusError(http.StatusBadRequest), want: true},
+		{name: "unprocessable", err: giteeStatusError(http.StatusUnprocessableEntity), want: true},
+		{name: "wrapped bad request", err: fmt.Errorf("comment: %w", giteeStatusError(http.StatusBadRequest)), want: true},
+		{name: "rate limited", err: giteeStatusError(http.StatusTooManyRequests), want: false},
+		{name: "server error", err: giteeStatusError(http.StatusInternalServerError), want: false},
+		{name: "status in text only", err: errors.New("the comment #400 is rejected"), want: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isClientError(tc.err); got != tc.want {
+				t.Fatalf("expect %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// footerMarker separates the footer from the content of comment.
+const footerMarker = "<!-- cla-robot:footer -->"
+
+// footerClient appends the footer to the comments created by the robot,
+// so that the maintainers can tell which deployment and version posted them.
+type footerClient struct {
+	iClient
+
+	footer string
+}
+
+// newFooterClient reports the build injected by -ldflags, see version.go.
+func newFooterClient(cli iClient, botLogin string, build buildInfo) *footerClient {
+	return &footerClient{iClient: cli, footer: commentFooter(botLogin, build.String())}
+}
+
+func (c *footerClient) CreatePRComment(ctx context.Context, org, repo string, number int32, comment string) error {
+	return c.iClient.CreatePRComment(ctx, org, repo, number, c.withFooter(comment))
+}
+
+func (c *footerClient) CreatePRCommentWithID(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	comment string,
+) (int32, error) {
+	return c.iClient.CreatePRCommentWithID(ctx, org, repo, number, c.withFooter(comment))
+}
+
+func (c *footerClient) UpdatePRComment(ctx context.Context, org, repo string, commentID int32, comment string) error {
+	return c.iClient.UpdatePRComment(ctx, org, repo, commentID, c.withFooter(comment))
+}
+
+// withFooter replaces the footer of comment if it is one of the robot.
+func (c *footerClient) withFooter(comment string) string {
+	if !strings.HasPrefix(comment, "<!-- cla-robot:") {
+		return comment
+	}
+
+	return stripFooter(comment) + c.footer
+}
+
+// stripFooter removes the footer, so that the comments are compared regardless of it.
+func stripFooter(body string) string {
+	if i := strings.Index(body, footerMarker); i >= 0 {
+		return strings.TrimRight(body[:i], "\n")
+	}
+
+	return body
+}
+
+func commentFooter(botLogin, ver string) string {
+	return fmt.Sprintf(
+		"\n\n%s\n> <sub>Posted by @%s (%s robot %s). Comment `%s` to re-check the CLA or `%s` for all the commands.</sub>",
+		footerMarker, botLogin, botName, ver, cmdCheckCLA, cmdCLAHelp,
+	)
+}
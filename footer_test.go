@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// commentRecorder records the comments created.
+type commentRecorder struct {
+	iClient
+
+	comments []string
+}
+
+func (c *commentRecorder) CreatePRComment(ctx context.Context, org, repo string, number int32, comment string) error {
+	c.comments = append(c.comments, comment)
+
+	return nil
+}
+
+func TestFooterClient(t *testing.T) {
+	build := buildInfo{Version: "v1.2.0", GitSHA: "0123456789abcdef", BuildDate: "2022-01-17T00:00:00Z"}
+	guide := withMarker(markerSignGuide, withFingerprint("sign the CLA", "0f1e"))
+	old := newFooterClient(nil, "cla-bot", buildInfo{Version: "v1.1.0"}).withFooter(guide)
+
+	cases := []struct {
+		name    string
+		comment string
+		want    string
+	}{
+		{
+			name:    "robot comment",
+			comment: guide,
+			want:    guide + commentFooter("cla-bot", "v1.2.0 (0123456789ab, built at 2022-01-17T00:00:00Z)"),
+		},
+		{
+			name:    "footer of old version is replaced",
+			comment: old,
+			want:    guide + commentFooter("cla-bot", "v1.2.0 (0123456789ab, built at 2022-01-17T00:00:00Z)"),
+		},
+		{
+			name:    "not robot comment",
+			comment: "/check-cla",
+			want:    "/check-cla",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := &commentRecorder{}
+			c := newFooterClient(rec, "cla-bot", build)
+
+			if err := c.CreatePRComment(context.Background(), "org", "repo", 1, tc.comment); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := rec.comments[0]; got != tc.want {
+				t.Fatalf("expect %q, got %q", tc.want, got)
+			}
+
+			if strings.Count(rec.comments[0], footerMarker) > 1 {
+				t.Fatalf("expect at most one footer, got %q", rec.comments[0])
+			}
+		})
+	}
+}
+
+func TestStripFooterKeepsFingerprint(t *testing.T) {
+	guide := withMarker(markerSignGuide, withFingerprint("sign the CLA", "0f1e"))
+
+	for _, ver := range []string{"v1.1.0", "v1.2.0"} {
+		body := newFooterClient(nil, "cla-bot", buildInfo{Version: ver}).withFooter(guide)
+
+		if got := stripFooter(body); got != guide {
+			t.Fatalf("expect %q without the footer of %s, got %q", guide, ver, got)
+		}
+
+		if got := getFingerprint(stripFooter(body)); got != "0f1e" {
+			t.Fatalf("expect the fingerprint unchanged by the footer of %s, got %q", ver, got)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+var (
+	emojiRe    = regexp.MustCompile(`\s?:[a-z_]+[a-z0-9_+-]*:`)
+	htmlTagRe  = regexp.MustCompile(`(?i)</?(details|summary|sub|sup|br|b|i|p|div|span)\s*/?>`)
+	mdLinkRe   = regexp.MustCompile(`\[([^\]]*)\]\(([^)\s]+)\)`)
+	tableSepRe = regexp.MustCompile(`^\|(\s*:?-+:?\s*\|)+$`)
+
+	// emphasisRe matches the bold or strikethrough text. The delimiters inside
+	// a word, such as the masked email a***@example.com, are not matched.
+	emphasisRe = regexp.MustCompile(`(^|[^\w*~])(?:\*{2,3}|~~)([^*~\s](?:[^*~\n]*[^*~\s])?)(?:\*{2,3}|~~)`)
+)
+
+// formatClient rewrites the comments of robot in the formatting profile of the
+// repo, so that the choice of profile is made in a single place.
+type formatClient struct {
+	iClient
+
+	// plain tells whether the repo wants the plain messages. Nil means none does.
+	plain func(org, repo string) bool
+}
+
+func newFormatClient(cli iClient) *formatClient {
+	return &formatClient{iClient: cli}
+}
+
+func (c *formatClient) CreatePRComment(ctx context.Context, org, repo string, number int32, comment string) error {
+	return c.iClient.CreatePRComment(ctx, org, repo, number, c.format(org, repo, comment))
+}
+
+func (c *formatClient) CreatePRCommentWithID(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	comment string,
+) (int32, error) {
+	return c.iClient.CreatePRCommentWithID(ctx, org, repo, number, c.format(org, repo, comment))
+}
+
+func (c *formatClient) UpdatePRComment(ctx context.Context, org, repo string, commentID int32, comment string) error {
+	return c.iClient.UpdatePRComment(ctx, org, repo, commentID, c.format(org, repo, comment))
+}
+
+func (c *formatClient) format(org, repo, comment string) string {
+	if c.plain == nil || !strings.HasPrefix(comment, "<!-- cla-robot:") || !c.plain(org, repo) {
+		return comment
+	}
+
+	return plainMessage(comment)
+}
+
+// plainMessage converts the markdown of comment into the minimal formatting
+// profile: no emoji, no HTML, dashes instead of tables and links written out
+// in full. The hidden markers are kept, and it is idempotent.
+func plainMessage(s string) string {
+	s = emojiRe.ReplaceAllString(s, "")
+	s = htmlTagRe.ReplaceAllString(s, "")
+
+	s = mdLinkRe.ReplaceAllStringFunc(s, func(v string) string {
+		m := mdLinkRe.FindStringSubmatch(v)
+		if text := strings.Trim(m[1], "*~ "); text != "" && text != m[2] {
+			return text + " (" + m[2] + ")"
+		}
+
+		return m[2]
+	})
+
+	s = emphasisRe.ReplaceAllString(s, "$1$2")
+
+	return plainTables(s)
+}
+
+// plainTables converts the rows of markdown tables into the lines of dashes.
+// The header is kept as a plain line which names the columns.
+func plainTables(s string) string {
+	lines := strings.Split(s, "\n")
+	r := make([]string, 0, len(lines))
+
+	for i, line := range lines {
+		v := strings.TrimSpace(line)
+		if !strings.HasPrefix(v, "|") || !strings.HasSuffix(v, "|") {
+			r = append(r, line)
+
+			continue
+		}
+
+		if tableSepRe.MatchString(v) {
+			continue
+		}
+
+		cells := strings.Split(strings.Trim(v, "|"), "|")
+		for j := range cells {
+			cells[j] = strings.TrimSpace(cells[j])
+		}
+		row := strings.Join(cells, ", ")
+
+		if i+1 < len(lines) && tableSepRe.MatchString(strings.TrimSpace(lines[i+1])) {
+			r = append(r, row+":")
+		} else {
+			r = append(r, "- "+row)
+		}
+	}
+
+	return strings.Join(r, "\n")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFormatProfiles(t *testing.T) {
+	cases := []struct {
+		name      string
+		comment   string
+		wantPlain string
+	}{
+		{
+			name: "already signed",
+			comment: withMarker(markerAlreadySigned,
+				"***@alice***, thanks for your pull request. All authors of the commits have signed the CLA. :wave: \n\n"+
+					"Verified addresses: a***@example.com, b***@example.org."),
+			wantPlain: withMarker(markerAlreadySigned,
+				"@alice, thanks for your pull request. All authors of the commits have signed the CLA. \n\n"+
+					"Verified addresses: a***@example.com, b***@example.org."),
+		},
+		{
+			name: "sign guide",
+			comment: withMarker(markerSignGuide,
+				"1. **00000000** | fix typo | (author: bob@example.com)\n\n"+
+					"Please check the [**FAQs**](https://example.com/faq) first.\n"+
+					"You can click [**here**](https://example.com/sign) to sign the CLA."),
+			wantPlain: withMarker(markerSignGuide,
+				"1. 00000000 | fix typo | (author: bob@example.com)\n\n"+
+					"Please check the FAQs (https://example.com/faq) first.\n"+
+					"You can click here (https://example.com/sign) to sign the CLA."),
+		},
+		{
+			name:      "link without text",
+			comment:   withMarker(markerHelp, "See [https://example.com/faq](https://example.com/faq)."),
+			wantPlain: withMarker(markerHelp, "See https://example.com/faq."),
+		},
+		{
+			name:      "table",
+			comment:   withMarker(markerStatus, "| Email | Status |\n|---|:---:|\n| a@b.com | signed |\n| c@d.com | unsigned |"),
+			wantPlain: withMarker(markerStatus, "Email, Status:\n- a@b.com, signed\n- c@d.com, unsigned"),
+		},
+		{
+			name: "html and strikethrough",
+			comment: withMarker(markerChecking,
+				"<details><summary>Details</summary>\n\n~~old~~ text\n</details>\n\n<sub>Last checked at: now</sub>"),
+			wantPlain: withMarker(markerChecking, "Details\n\nold text\n\n\nLast checked at: now"),
+		},
+		{
+			name:      "comment not created by robot",
+			comment:   "**LGTM** :+1:",
+			wantPlain: "**LGTM** :+1:",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, plain := range []bool{false, true} {
+				want := tc.comment
+				if plain {
+					want = tc.wantPlain
+				}
+
+				rec := &commentRecorder{}
+				c := newFormatClient(rec)
+				c.plain = func(org, repo string) bool { return plain }
+
+				if err := c.CreatePRComment(context.Background(), "org", "repo", 1, tc.comment); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if len(rec.comments) != 1 || rec.comments[0] != want {
+					t.Fatalf("plain: %v, expect %q, got %q", plain, want, rec.comments)
+				}
+
+				// The comment which is updated later must be stable.
+				if got := c.format("org", "repo", want); got != want {
+					t.Fatalf("plain: %v, formatting is not idempotent, got %q", plain, got)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatClientWithoutProfile(t *testing.T) {
+	rec := &commentRecorder{}
+	c := newFormatClient(rec)
+
+	comment := withMarker(markerSignGuide, "**bold** :wave:")
+	if err := c.CreatePRComment(context.Background(), "org", "repo", 1, comment); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(rec.comments) != 1 || rec.comments[0] != comment {
+		t.Fatalf("expect %q, got %q", comment, rec.comments)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"unicode/utf8"
+)
+
+// globToken is a step of the glob of path.Match. It is either a star, which
+// matches any sequence of non-'/' characters, or a single character.
+type globToken struct {
+	star bool
+
+	// any is '?', which matches any single non-'/' character.
+	any bool
+
+	// literal is the character if the token is neither a star, '?' nor a class.
+	literal rune
+	isClass bool
+	negated bool
+	ranges  [][2]rune
+}
+
+func (t *globToken) matchRune(r rune) bool {
+	switch {
+	case t.any:
+		return r != '/'
+
+	case t.isClass:
+		in := false
+		for _, v := range t.ranges {
+			if v[0] <= r && r <= v[1] {
+				in = true
+				break
+			}
+		}
+
+		return in != t.negated
+
+	default:
+		return t.literal == r
+	}
+}
+
+// candidates returns the characters at the boundaries of the sets the token
+// matches. Two sets of characters which are unions of ranges intersect only
+// if they share one of such characters.
+func (t *globToken) candidates() []rune {
+	switch {
+	case t.any:
+		return []rune{'/' - 1, '/' + 1}
+
+	case t.isClass:
+		r := make([]rune, 0, 4*len(t.ranges))
+		for _, v := range t.ranges {
+			r = append(r, v[0]-1, v[0], v[1], v[1]+1)
+		}
+
+		return r
+
+	default:
+		return []rune{t.literal}
+	}
+}
+
+// globAny is the single character which a star consumes.
+var globAny = globToken{any: true}
+
+// shareRune checks whether there is a character which both of a and b match.
+func shareRune(a, b *globToken) bool {
+	cs := append(a.candidates(), b.candidates()...)
+	cs = append(cs, 0, '/', utf8.MaxRune)
+
+	for _, r := range cs {
+		if r >= 0 && r <= utf8.MaxRune && a.matchRune(r) && b.matchRune(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var errBadGlob = errors.New("syntax error in glob")
+
+// parseGlob splits the glob of path.Match into tokens.
+func parseGlob(pattern string) ([]globToken, error) {
+	var r []globToken
+
+	for s := pattern; s != ""; {
+		switch s[0] {
+		case '*':
+			r = append(r, globToken{star: true})
+			s = s[1:]
+
+		case '?':
+			r = append(r, globToken{any: true})
+			s = s[1:]
+
+		case '[':
+			t, rest, err := parseGlobClass(s[1:])
+			if err != nil {
+				return nil, err
+			}
+
+			r = append(r, t)
+			s = rest
+
+		default:
+			c, rest, err := globChar(s)
+			if err != nil {
+				return nil, err
+			}
+
+			r = append(r, globToken{literal: c})
+			s = rest
+		}
+	}
+
+	return r, nil
+}
+
+// parseGlobClass parses the character class following '['.
+func parseGlobClass(s string) (globToken, string, error) {
+	t := globToken{isClass: true}
+
+	if s != "" && s[0] == '^' {
+		t.negated = true
+		s = s[1:]
+	}
+
+	for {
+		if s != "" && s[0] == ']' && len(t.ranges) > 0 {
+			return t, s[1:], nil
+		}
+
+		lo, rest, err := globClassChar(s)
+		if err != nil {
+			return t, "", err
+		}
+
+		hi := lo
+		if rest != "" && rest[0] == '-' {
+			if hi, rest, err = globClassChar(rest[1:]); err != nil {
+				return t, "", err
+			}
+		}
+
+		t.ranges = append(t.ranges, [2]rune{lo, hi})
+		s = rest
+	}
+}
+
+// globClassChar reads a character of the range in the character class.
+func globClassChar(s string) (rune, string, error) {
+	if s == "" || s[0] == '-' || s[0] == ']' {
+		return 0, "", errBadGlob
+	}
+
+	return globChar(s)
+}
+
+// globChar reads a character, which may be escaped, at the head of s.
+func globChar(s string) (rune, string, error) {
+	if s[0] == '\\' {
+		s = s[1:]
+		if s == "" {
+			return 0, "", errBadGlob
+		}
+	}
+
+	c, n := utf8.DecodeRuneInString(s)
+	if c == utf8.RuneError && n == 1 {
+		return 0, "", errBadGlob
+	}
+
+	return c, s[n:], nil
+}
+
+// globsIntersect checks whether there is a name which both of the globs match.
+func globsIntersect(a, b []globToken) bool {
+	type state struct{ i, j int }
+
+	visited := map[state]bool{}
+
+	var walk func(i, j int) bool
+	walk = func(i, j int) bool {
+		if i == len(a) && j == len(b) {
+			return true
+		}
+
+		k := state{i, j}
+		if visited[k] {
+			return false
+		}
+		visited[k] = true
+
+		aStar := i < len(a) && a[i].star
+		bStar := j < len(b) && b[j].star
+
+		// The star stops matching.
+		if aStar && walk(i+1, j) {
+			return true
+		}
+		if bStar && walk(i, j+1) {
+			return true
+		}
+
+		switch {
+		case aStar && bStar:
+			return false
+
+		// The star consumes the character of the other one.
+		case aStar:
+			return j < len(b) && shareRune(&globAny, &b[j]) && walk(i, j+1)
+
+		case bStar:
+			return i < len(a) && shareRune(&a[i], &globAny) && walk(i+1, j)
+
+		default:
+			return i < len(a) && j < len(b) && shareRune(&a[i], &b[j]) && walk(i+1, j+1)
+		}
+	}
+
+	return walk(0, 0)
+}
@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+func TestGlobsOverlap(t *testing.T) {
+	cases := []struct {
+		name string
+		a    string
+		b    string
+		want bool
+	}{
+		{name: "same glob", a: "release-*", b: "release-*", want: true},
+		{name: "same name", a: "master", b: "master", want: true},
+		{name: "different names", a: "master", b: "develop", want: false},
+		{name: "name matched by glob", a: "release-*", b: "release-1.0", want: true},
+		{name: "name not matched by glob", a: "release-*", b: "master", want: false},
+		{name: "prefix and suffix", a: "release-*", b: "*-1.0", want: true},
+		{name: "different prefixes", a: "release-*", b: "stable-*", want: false},
+		{name: "different suffixes", a: "*-lts", b: "*-dev", want: false},
+		{name: "star in the middle", a: "r*-1.0", b: "release-*", want: true},
+		{name: "question mark", a: "v?", b: "v1", want: true},
+		{name: "question mark needs a character", a: "v?", b: "v", want: false},
+		{name: "star spans no slash", a: "release-*", b: "release-1/*", want: false},
+		{name: "star and slash", a: "*/*", b: "feature/*", want: true},
+		{name: "overlapped classes", a: "v[0-5]", b: "v[3-9]", want: true},
+		{name: "disjoint classes", a: "v[0-2]", b: "v[5-9]", want: false},
+		{name: "negated class", a: "v[^0-9]", b: "v1", want: false},
+		{name: "negated class and star", a: "v[^0-9]*", b: "v*-rc", want: true},
+		{name: "escaped star", a: `a\*`, b: "ab", want: false},
+		{name: "escaped star and star", a: `a\*`, b: "a*", want: true},
+		{name: "invalid glob", a: "[", b: "master", want: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := globsOverlap(tc.a, tc.b); got != tc.want {
+				t.Fatalf("expect %v, got %v", tc.want, got)
+			}
+
+			if got := globsOverlap(tc.b, tc.a); got != tc.want {
+				t.Fatalf("expect %v in reverse, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateBranches(t *testing.T) {
+	cases := []struct {
+		name     string
+		branches []string
+		wantErr  bool
+	}{
+		{name: "disjoint", branches: []string{"master", "release-*", "stable-*"}},
+		{name: "missing branch", branches: []string{"master", ""}, wantErr: true},
+		{name: "invalid glob", branches: []string{"release-["}, wantErr: true},
+		{name: "overlapped globs", branches: []string{"release-*", "*-1.0"}, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			items := make([]branchConfig, len(tc.branches))
+			for i, b := range tc.branches {
+				items[i].Branch = b
+			}
+
+			if err := validateBranches(items); (err != nil) != tc.wantErr {
+				t.Fatalf("expect error: %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -6,5 +6,8 @@ require (
 	github.com/huaweicloud/golangsdk v0.0.0-20210831081626-d823fe11ceba
 	github.com/opensourceways/community-robot-lib v0.0.0-20220117111729-62e2fe1e7b9e
 	github.com/opensourceways/go-gitee v0.0.0-20211230094517-effa55336a8b
+	github.com/prometheus/client_golang v1.11.0
 	github.com/sirupsen/logrus v1.8.1
+	go.etcd.io/bbolt v1.3.6
+	k8s.io/apimachinery v0.22.1
 )
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/opensourceways/community-robot-lib/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// checkerProbeTTL is how long the result of probing a CLA service is
+	// reused, so that the probes of kubelet will not hammer the service.
+	checkerProbeTTL = time.Minute
+
+	checkerProbeTimeout = 5 * time.Second
+)
+
+var errConfigNotLoaded = errors.New("the config has not been loaded")
+
+// readiness tells whether the robot is ready to handle the events.
+type readiness struct {
+	bot *robot
+
+	// probeCheckers means probing the hosts of CLA service in the readiness check.
+	probeCheckers bool
+
+	// probes caches the results of probing the hosts of CLA service. The key
+	// is the host and the value is the error message, which is empty if healthy.
+	probes *ttlCache
+
+	// draining is set once the process is asked to stop.
+	draining int32
+}
+
+func newReadiness(bot *robot, probeCheckers bool) *readiness {
+	return &readiness{
+		bot:           bot,
+		probeCheckers: probeCheckers,
+		probes:        newTTLCache(checkerProbeTTL),
+	}
+}
+
+// drain marks the robot as not ready, so that the load balancer stops sending
+// the events during the shutdown.
+func (r *readiness) drain() {
+	atomic.StoreInt32(&r.draining, 1)
+}
+
+// watchShutdown marks the robot as not ready once the process receives the
+// signal of stopping, which is earlier than the framework stops serving.
+func (r *readiness) watchShutdown() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-sig
+		r.drain()
+	}()
+}
+
+func (r *readiness) check() error {
+	if atomic.LoadInt32(&r.draining) == 1 {
+		return errShuttingDown
+	}
+
+	c, ok := r.bot.latestConfig.Load().(*configuration)
+	if !ok {
+		return errConfigNotLoaded
+	}
+
+	if !r.probeCheckers {
+		return nil
+	}
+
+	for host, u := range checkerURLs(c) {
+		if err := r.probe(host, u); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// probe checks that the CLA service of url is reachable. The result is cached
+// per host.
+func (r *readiness) probe(host, url string) error {
+	if v, ok := r.probes.get(host); ok {
+		if s := v.(string); s != "" {
+			return errors.New(s)
+		}
+
+		return nil
+	}
+
+	err := probeChecker(url)
+
+	s := ""
+	if err != nil {
+		s = fmt.Sprintf("probing the cla checker %s: %v", host, err)
+	}
+	r.probes.set(host, s)
+
+	if s != "" {
+		return errors.New(s)
+	}
+
+	return nil
+}
+
+// probeChecker sends a HEAD request to the CLA service. Any response but the
+// server error means the service is up, since it may not support HEAD.
+func probeChecker(url string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), checkerProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("response has status %q", resp.Status)
+	}
+
+	return nil
+}
+
+// checkerURLs returns a check_url for each distinct host of CLA service in the config.
+func checkerURLs(c *configuration) map[string]string {
+	r := map[string]string{}
+	add := func(u string) {
+		if u == "" {
+			return
+		}
+
+		if host := checkerHost(u); r[host] == "" {
+			r[host] = u
+		}
+	}
+
+	for i := range c.ConfigItems {
+		item := &c.ConfigItems[i]
+
+		add(item.CheckURL)
+
+		for j := range item.Branches {
+			add(item.Branches[j].CheckURL)
+		}
+
+		for j := range item.Agreements {
+			add(item.Agreements[j].CheckURL)
+		}
+	}
+
+	return r
+}
+
+func (r *readiness) readyz(w http.ResponseWriter, req *http.Request) {
+	if err := r.check(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+
+		return
+	}
+
+	fmt.Fprintln(w, "ok")
+}
+
+func healthz(w http.ResponseWriter, req *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// runHealthServer serves the liveness and readiness probes in the background.
+func runHealthServer(address string, ready *readiness) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", healthz)
+	mux.HandleFunc("/readyz", ready.readyz)
+	mux.HandleFunc("/version", serveVersion)
+
+	s := &http.Server{Addr: address, Handler: mux}
+
+	go func() {
+		if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.WithError(err).Error("Error serving the health probes.")
+		}
+	}()
+
+	return s
+}
+
+// loadConfigFile loads the config of robot, which the framework will load too.
+// It lets the robot be ready before the first event delivers the config.
+func loadConfigFile(path string) (*configuration, error) {
+	c := new(configuration)
+	if err := utils.LoadFromYaml(path, c); err != nil {
+		return nil, err
+	}
+
+	c.SetDefault()
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"sync"
+)
+
+// errTooManyEvents means the event is rejected since the queue of handling is full.
+var errTooManyEvents = errors.New("too many events are queued")
+
+// eventLimiter bounds the events handled at the same time. The excess events
+// wait in a bounded queue, and are rejected once it is full. A share of the
+// slots is reserved for the commands, so that they are not starved by the
+// bursts of PR events.
+type eventLimiter struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	limit     int
+	reserved  int
+	maxQueued int
+
+	running     int
+	runningBulk int
+	queued      int
+}
+
+// newEventLimiter returns the limiter allowing limit events at the same time,
+// or nil which doesn't limit if limit is not positive. One fifth of the
+// slots, at least one, is reserved for the commands if there are two or more.
+func newEventLimiter(limit, maxQueued int) *eventLimiter {
+	if limit <= 0 {
+		return nil
+	}
+
+	l := &eventLimiter{
+		limit:     limit,
+		maxQueued: maxQueued,
+	}
+
+	if limit > 1 {
+		if l.reserved = limit / 5; l.reserved == 0 {
+			l.reserved = 1
+		}
+	}
+
+	l.cond = sync.NewCond(&l.mu)
+
+	return l
+}
+
+func (l *eventLimiter) available(command bool) bool {
+	if l.running >= l.limit {
+		return false
+	}
+
+	return command || l.runningBulk < l.limit-l.reserved
+}
+
+// acquire waits for a slot of handling the event, and returns the function
+// to release it. event is the type of event for the metrics, and command
+// tells whether it is a command which can use the reserved slots.
+func (l *eventLimiter) acquire(event string, command bool) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.available(command) {
+		if l.queued >= l.maxQueued {
+			rejectedEvents.WithLabelValues(event).Inc()
+
+			return nil, errTooManyEvents
+		}
+
+		l.queued++
+		queuedEvents.WithLabelValues(event).Inc()
+
+		for !l.available(command) {
+			l.cond.Wait()
+		}
+
+		l.queued--
+		queuedEvents.WithLabelValues(event).Dec()
+	}
+
+	l.running++
+	if !command {
+		l.runningBulk++
+	}
+
+	return func() {
+		l.mu.Lock()
+		l.running--
+		if !command {
+			l.runningBulk--
+		}
+		l.mu.Unlock()
+
+		l.cond.Broadcast()
+	}, nil
+}
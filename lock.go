@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+type lockEntry struct {
+	mu sync.Mutex
+
+	// refs is the number of goroutines holding or waiting for the lock.
+	refs int
+}
+
+// keyedMutex serializes the goroutines of same key while the ones of different
+// keys run in parallel. The entry of key is evicted once no goroutine uses it,
+// so that the map does not grow without bound.
+type keyedMutex struct {
+	mu      sync.Mutex
+	entries map[string]*lockEntry
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{entries: map[string]*lockEntry{}}
+}
+
+// lock locks the key and returns the function to unlock it.
+func (k *keyedMutex) lock(key string) func() {
+	k.mu.Lock()
+	e, ok := k.entries[key]
+	if !ok {
+		e = &lockEntry{}
+		k.entries[key] = e
+	}
+	e.refs++
+	k.mu.Unlock()
+
+	e.mu.Lock()
+
+	return func() {
+		e.mu.Unlock()
+
+		k.mu.Lock()
+		if e.refs--; e.refs == 0 {
+			delete(k.entries, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+func prKey(org, repo string, number int32) string {
+	return fmt.Sprintf("%s/%s/%d", org, repo, number)
+}
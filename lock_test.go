@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyedMutexSerializesSameKey(t *testing.T) {
+	cases := []struct {
+		name      string
+		keys      []string
+		wantOrder bool
+	}{
+		{name: "same pr", keys: []string{prKey("org", "repo", 1), prKey("org", "repo", 1)}, wantOrder: true},
+		{name: "different prs", keys: []string{prKey("org", "repo", 1), prKey("org", "repo", 2)}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			k := newKeyedMutex()
+			cli := &fakeClient{}
+
+			var mu sync.Mutex
+			var wg sync.WaitGroup
+
+			// The first handler holds the lock until the second one has started.
+			started := make(chan struct{})
+			for i, key := range tc.keys {
+				wg.Add(1)
+
+				go func(i int, key string) {
+					defer wg.Done()
+
+					if i == 1 {
+						<-started
+					}
+
+					defer k.lock(key)()
+
+					if i == 0 {
+						close(started)
+						time.Sleep(50 * time.Millisecond)
+					}
+
+					label := []string{"first", "second"}[i]
+
+					mu.Lock()
+					_ = cli.AddPRLabel(context.Background(), "org", "repo", 1, label)
+					mu.Unlock()
+				}(i, key)
+			}
+
+			wg.Wait()
+
+			first := len(cli.labelOps) > 0 && cli.labelOps[0] == "add first"
+			if first != tc.wantOrder {
+				t.Fatalf("expect the mutations ordered: %v, got %v", tc.wantOrder, cli.labelOps)
+			}
+
+			if n := len(k.entries); n != 0 {
+				t.Fatalf("expect the idle entries evicted, got %d", n)
+			}
+		})
+	}
+}
+
+func TestKeyedMutexEvictsIdleEntries(t *testing.T) {
+	k := newKeyedMutex()
+
+	unlock := k.lock("a")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		k.lock("a")()
+	}()
+
+	// Wait for the second goroutine to queue on the lock.
+	for i := 0; ; i++ {
+		k.mu.Lock()
+		refs := k.entries["a"].refs
+		k.mu.Unlock()
+
+		if refs == 2 {
+			break
+		}
+
+		if i > 1000 {
+			t.Fatal("the second goroutine is not waiting for the lock")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	unlock()
+	<-done
+
+	if n := len(k.entries); n != 0 {
+		t.Fatalf("expect no entry left, got %d", n)
+	}
+}
@@ -1,20 +1,71 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
 	"os"
+	"strings"
+	"time"
 
-	"github.com/opensourceways/community-robot-lib/giteeclient"
+	"github.com/opensourceways/community-robot-lib/interrupts"
 	"github.com/opensourceways/community-robot-lib/logrusutil"
 	liboptions "github.com/opensourceways/community-robot-lib/options"
 	"github.com/opensourceways/community-robot-lib/robot-gitee-framework"
 	"github.com/opensourceways/community-robot-lib/secret"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 )
 
 type options struct {
-	service liboptions.ServiceOptions
-	gitee   liboptions.GiteeOptions
+	service        liboptions.ServiceOptions
+	gitee          liboptions.GiteeOptions
+	extraTokens    string
+	exemptionStore string
+	eventTimeout   time.Duration
+	drainTimeout   time.Duration
+	commentFooter  bool
+	sweepInterval  time.Duration
+	reconcile      time.Duration
+	staleAfter     time.Duration
+	metricsAddress string
+	healthAddress  string
+	probeCheckers  bool
+	pprofAddress   string
+	validateConfig string
+	once           string
+	dedupeWindow   time.Duration
+	maxEvents      int
+	maxQueued      int
+	selfCheck      bool
+	strictCheck    bool
+	printVersion   bool
+	cacheStore     string
+	auditLogPath   string
+	auditRetain    time.Duration
+	adminAddress   string
+	adminTokenPath string
+	signingHook    string
+	signingSecret  string
+	smtp           smtpOptions
+}
+
+// tokenPaths returns the paths of all the gitee tokens, the first of which is
+// the one of gitee-token-path.
+func (o *options) tokenPaths() []string {
+	r := []string{o.gitee.TokenPath}
+
+	for _, p := range strings.Split(o.extraTokens, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			r = append(r, p)
+		}
+	}
+
+	return r
 }
 
 func (o *options) Validate() error {
@@ -22,15 +73,177 @@ func (o *options) Validate() error {
 		return err
 	}
 
-	return o.gitee.Validate()
+	if err := o.gitee.Validate(); err != nil {
+		return err
+	}
+
+	if o.healthAddress != "" && o.healthAddress == o.metricsAddress {
+		return errors.New("health-address must differ from metrics-address")
+	}
+
+	if o.adminAddress != "" && o.adminTokenPath == "" {
+		return errors.New("missing admin-token-path")
+	}
+
+	if o.signingHook != "" && o.signingSecret == "" {
+		return errors.New("missing signing-hook-secret-path")
+	}
+
+	if o.pprofAddress != "" {
+		if _, _, err := net.SplitHostPort(o.pprofAddress); err != nil {
+			return fmt.Errorf("invalid pprof-address: %v", err)
+		}
+	}
+
+	return o.smtp.validate()
 }
 
 func gatherOptions(fs *flag.FlagSet, args ...string) options {
 	var o options
 
 	o.gitee.AddFlags(fs)
+
+	fs.StringVar(
+		&o.extraTokens, "extra-gitee-token-paths", "",
+		"Comma separated paths of the extra tokens of the same robot account. The requests rotate across all the tokens "+
+			"and fail over when one is revoked or rate limited.",
+	)
 	o.service.AddFlags(fs)
 
+	fs.StringVar(
+		&o.exemptionStore, "exemption-store", "",
+		"Path of the file storing the emails exempted from the CLA check. It is disabled if empty.",
+	)
+
+	fs.DurationVar(
+		&o.eventTimeout, "event-timeout", 2*time.Minute,
+		"The deadline of handling an event including the checks of CLA. It is unlimited if not positive.",
+	)
+
+	fs.DurationVar(
+		&o.drainTimeout, "drain-timeout", 30*time.Second,
+		"The max time to wait for the in-flight events on shutdown.",
+	)
+
+	fs.DurationVar(
+		&o.dedupeWindow, "dedupe-window", 10*time.Minute,
+		"The window in which the events redelivered by Gitee are skipped. It is disabled if not positive.",
+	)
+
+	fs.IntVar(
+		&o.maxEvents, "max-concurrent-events", 0,
+		"The max number of events handled at the same time, a fifth of which is reserved for the commands. "+
+			"It is unlimited if not positive.",
+	)
+
+	fs.IntVar(
+		&o.maxQueued, "max-queued-events", 200,
+		"The max number of events waiting for handling when max-concurrent-events is reached. The excess events are rejected.",
+	)
+
+	fs.BoolVar(
+		&o.selfCheck, "self-check", false,
+		"Whether to verify the access to the repos, the cla labels and the CLA services in the config on startup.",
+	)
+
+	fs.BoolVar(
+		&o.strictCheck, "self-check-strict", false,
+		"Whether to abort the startup if the self-check fails. It implies self-check.",
+	)
+
+	fs.StringVar(
+		&o.cacheStore, "cache-store", "",
+		"Path of the bbolt file persisting the signing results and the states of PRs across restarts. It is disabled if empty.",
+	)
+
+	fs.StringVar(
+		&o.auditLogPath, "audit-log-path", "",
+		"Path of the append-only file which the audit records of mutations are also written to. They are only logged if empty.",
+	)
+
+	fs.DurationVar(
+		&o.auditRetain, "audit-retention", 90*24*time.Hour,
+		"How long the audit records are kept in the cache store for the audit API.",
+	)
+
+	fs.BoolVar(
+		&o.commentFooter, "comment-footer", true,
+		"Whether to append the footer of robot identity and version to the comments.",
+	)
+
+	fs.DurationVar(
+		&o.sweepInterval, "sweep-interval", time.Hour,
+		"The interval of sweeping the open PRs, such as reminding the unsigned ones. It is disabled if not positive.",
+	)
+
+	fs.DurationVar(
+		&o.reconcile, "reconcile-interval", 0,
+		"The interval of re-checking the open PRs which miss the cla labels or are stale. It is disabled if not positive.",
+	)
+
+	fs.DurationVar(
+		&o.staleAfter, "reconcile-stale-after", 7*24*time.Hour,
+		"The age of the last check after which the reconciliation re-checks the PR. Only the PRs missing labels are re-checked if not positive.",
+	)
+
+	fs.StringVar(
+		&o.metricsAddress, "metrics-address", "",
+		"The address to serve the Prometheus metrics on /metrics, such as :9090. It is disabled if empty.",
+	)
+
+	fs.StringVar(
+		&o.healthAddress, "health-address", "",
+		"The address to serve the probes on /healthz and /readyz, such as :8081. It is disabled if empty.",
+	)
+
+	fs.BoolVar(
+		&o.probeCheckers, "ready-probe-checkers", false,
+		"Whether /readyz probes the hosts of check_url. The results are cached for a minute.",
+	)
+
+	fs.StringVar(
+		&o.pprofAddress, "pprof-address", "",
+		"The address to serve net/http/pprof on, such as :6060. It binds to localhost if the host is omitted. It is disabled if empty.",
+	)
+
+	fs.StringVar(
+		&o.validateConfig, "validate-config", "",
+		"Path of the config file to validate. The robot reports the problems found and exits instead of starting if it is set.",
+	)
+
+	fs.StringVar(
+		&o.adminAddress, "admin-address", "",
+		"The address to serve the admin API on, such as :8082. It is disabled if empty.",
+	)
+
+	fs.StringVar(
+		&o.adminTokenPath, "admin-token-path", "",
+		"Path to the file of admin tokens, one \"<caller>:<token>\" per line. It is required if admin-address is set.",
+	)
+
+	fs.StringVar(
+		&o.signingHook, "signing-hook-address", "",
+		"The address to receive the notifications of signing from the CLA platform on /v1/signed. It is disabled if empty.",
+	)
+
+	fs.StringVar(
+		&o.signingSecret, "signing-hook-secret-path", "",
+		"Path to the file of the secret signing the notifications by HMAC-SHA256. It is required if signing-hook-address is set.",
+	)
+
+	fs.BoolVar(
+		&o.printVersion, "version", false,
+		"Print the version, git sha and build date, and exit.",
+	)
+
+	fs.StringVar(
+		&o.once, "once", "",
+		"The pr to check once in dry-run mode, such as org/repo#123. The robot prints a report and exits "+
+			"with 0 if signed, 1 if unsigned or 2 if it can't be checked, instead of starting.",
+	)
+
+	o.smtp.addFlags(fs)
+
 	fs.Parse(args)
 	return o
 }
@@ -39,20 +252,251 @@ func main() {
 	logrusutil.ComponentInit(botName)
 
 	o := gatherOptions(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:]...)
+	if o.printVersion {
+		fmt.Printf("%s %s\n", botName, currentBuild())
+		os.Exit(0)
+	}
+
+	logrus.WithFields(currentBuild().fields()).Info("Starting the robot.")
+
+	if o.validateConfig != "" {
+		os.Exit(runValidateConfig(o.validateConfig))
+	}
+
 	if err := o.Validate(); err != nil {
 		logrus.WithError(err).Fatal("Invalid options")
 	}
 
+	tokenPaths := o.tokenPaths()
+
+	secrets := append([]string(nil), tokenPaths...)
+	if o.adminAddress != "" {
+		secrets = append(secrets, o.adminTokenPath)
+	}
+	if o.signingHook != "" {
+		secrets = append(secrets, o.signingSecret)
+	}
+	if o.smtp.enabled() && o.smtp.passwordPath != "" {
+		secrets = append(secrets, o.smtp.passwordPath)
+	}
+
 	secretAgent := new(secret.Agent)
-	if err := secretAgent.Start([]string{o.gitee.TokenPath}); err != nil {
+	if err := secretAgent.Start(secrets); err != nil {
 		logrus.WithError(err).Fatal("Error starting secret agent.")
 	}
-	
+
 	defer secretAgent.Stop()
 
-	c := giteeclient.NewClient(secretAgent.GetTokenGenerator(o.gitee.TokenPath))
+	getTokens := make([]func() []byte, len(tokenPaths))
+	for i, p := range tokenPaths {
+		getTokens[i] = secretAgent.GetTokenGenerator(p)
+	}
+
+	c := newTokenPoolClient(getTokens)
 
-	r := newRobot(c)
+	bot, err := c.GetBot(context.Background())
+	if err != nil {
+		logrus.WithError(err).Fatal("Error getting bot name.")
+	}
+
+	var exemptions *exemptionStore
+	if o.exemptionStore != "" {
+		if exemptions, err = newExemptionStore(o.exemptionStore); err != nil {
+			logrus.WithError(err).Fatal("Error loading the exemption store.")
+		}
+	}
+
+	if o.once != "" {
+		code := checkOnce(
+			newRobot(newRetryClient(c), bot.Login, exemptions, nil, o.eventTimeout, 0),
+			o.service.ConfigFile, o.once,
+		)
+
+		secretAgent.Stop()
+		os.Exit(code)
+	}
+
+	var notifier *emailNotifier
+	if o.smtp.enabled() {
+		notifier, err = newEmailNotifier(&o.smtp, secretAgent.GetTokenGenerator(o.smtp.passwordPath))
+		if err != nil {
+			logrus.WithError(err).Fatal("Error initializing the email notifier.")
+		}
+	}
+
+	audit, err := newAuditLog(o.auditLogPath)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error opening the audit log.")
+	}
+
+	// The formatter is wrapped by the footer client, so that the footer is
+	// formatted in the profile of repo too.
+	formatter := newFormatClient(newAuditClient(newMetricsClient(newRetryClient(c)), audit))
+
+	var cli iClient = formatter
+	if o.commentFooter {
+		cli = newFooterClient(cli, bot.Login, currentBuild())
+	}
+
+	r := newRobot(cli, bot.Login, exemptions, notifier, o.eventTimeout, o.dedupeWindow)
+	formatter.plain = r.plainMessages
+	r.audit = audit
+	r.limiter = newEventLimiter(o.maxEvents, o.maxQueued)
+
+	if o.cacheStore != "" {
+		r.attachCacheStore(openCacheStore(o.cacheStore))
+	}
+
+	if c, err := loadConfigFile(o.service.ConfigFile); err != nil {
+		logrus.WithError(err).Warning("Error loading the config, waiting for the events to deliver it.")
+	} else {
+		r.recordConfig(c)
+	}
+
+	stop := make(chan struct{})
+	if o.sweepInterval > 0 {
+		go r.runSweeper(o.sweepInterval, stop)
+	}
+
+	if o.reconcile > 0 {
+		go r.runReconciler(o.reconcile, o.staleAfter, stop)
+	}
+
+	if r.cacheStore != nil && o.auditRetain > 0 {
+		go r.cacheStore.runAuditPruner(o.auditRetain, stop)
+	}
+
+	go r.transitions.runSummary(stop)
+
+	var metricsServer *http.Server
+	if o.metricsAddress != "" {
+		metricsServer = runMetricsServer(o.metricsAddress)
+	}
+
+	ready := newReadiness(r, o.probeCheckers)
+
+	if o.selfCheck || o.strictCheck {
+		if failed := r.runSelfCheck(context.Background(), ready); failed > 0 && o.strictCheck {
+			logrus.Fatalf("Aborted the startup since %d items of the self-check failed.", failed)
+		}
+	}
+	ready.watchShutdown()
+	r.watchHangup(ready, stop)
+
+	var healthServer *http.Server
+	if o.healthAddress != "" {
+		healthServer = runHealthServer(o.healthAddress, ready)
+	}
+
+	var adminServer *http.Server
+	if o.adminAddress != "" {
+		adminServer = runAdminServer(o.adminAddress, &adminAPI{
+			bot:  r,
+			auth: adminAuth{getTokens: secretAgent.GetTokenGenerator(o.adminTokenPath)},
+		})
+	}
+
+	var signingServer *http.Server
+	if o.signingHook != "" {
+		signingServer = runSigningHookServer(o.signingHook, &signingHook{
+			bot:       r,
+			getSecret: secretAgent.GetTokenGenerator(o.signingSecret),
+		})
+	}
+
+	pprofServer := runPprofServer(o.pprofAddress)
+
+	// The draining runs on the interrupt alongside the framework waiting for
+	// the handlers, so that the handlers left after the drain timeout are
+	// canceled instead of blocking the framework.
+	interrupts.OnInterrupt(func() {
+		ready.drain()
+		r.stop(o.drainTimeout)
+	})
 
 	framework.Run(r, o.service)
+
+	close(stop)
+
+	shutdownServer(metricsServer, "metrics")
+	shutdownServer(adminServer, "admin")
+	shutdownServer(signingServer, "signing hook")
+	shutdownServer(pprofServer, "pprof")
+
+	r.cacheStore.close()
+	audit.close()
+
+	// The health server stops last, so that the probes fail instead of
+	// being refused during the draining.
+	shutdownServer(healthServer, "health")
+}
+
+// runPprofServer serves the profiles of net/http/pprof in the background. It
+// is nil if address is empty.
+func runPprofServer(address string) *http.Server {
+	s := newPprofServer(address)
+	if s == nil {
+		return nil
+	}
+
+	go func() {
+		if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.WithError(err).Error("Error serving the pprof.")
+		}
+	}()
+
+	logrus.Infof("Serving pprof on %s.", s.Addr)
+
+	return s
+}
+
+// newPprofServer returns the server of profiles, or nil if address is empty.
+// It listens on localhost if the host of address is omitted, since the profiles
+// should not be exposed.
+func newPprofServer(address string) *http.Server {
+	if address == "" {
+		return nil
+	}
+
+	if host, port, err := net.SplitHostPort(address); err == nil && host == "" {
+		address = net.JoinHostPort("127.0.0.1", port)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	return &http.Server{Addr: address, Handler: mux}
+}
+
+func shutdownServer(s *http.Server, name string) {
+	if s == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); err != nil {
+		logrus.WithError(err).Warningf("Error shutting down the %s server.", name)
+	}
+}
+
+// runMetricsServer serves the Prometheus metrics in the background.
+func runMetricsServer(address string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	s := &http.Server{Addr: address, Handler: mux}
+
+	go func() {
+		if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.WithError(err).Error("Error serving the metrics.")
+		}
+	}()
+
+	return s
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPprofServer(t *testing.T) {
+	cases := []struct {
+		name     string
+		args     []string
+		wantAddr string
+	}{
+		{name: "flag is not provided"},
+		{name: "flag is empty", args: []string{"--pprof-address="}},
+		{name: "host is omitted", args: []string{"--pprof-address=:6060"}, wantAddr: "127.0.0.1:6060"},
+		{name: "host is given", args: []string{"--pprof-address=0.0.0.0:6060"}, wantAddr: "0.0.0.0:6060"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			o := gatherOptions(flag.NewFlagSet("test", flag.ContinueOnError), tc.args...)
+
+			s := newPprofServer(o.pprofAddress)
+			if tc.wantAddr == "" {
+				if s != nil {
+					t.Fatalf("expect no pprof server, got the one on %s", s.Addr)
+				}
+
+				return
+			}
+
+			if s == nil || s.Addr != tc.wantAddr {
+				t.Fatalf("expect the pprof server on %s, got %v", tc.wantAddr, s)
+			}
+
+			for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline"} {
+				w := httptest.NewRecorder()
+				s.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+				if w.Code != http.StatusOK {
+					t.Fatalf("expect %s to be served, got status %d", path, w.Code)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	langEN   = "en"
+	langZhCN = "zh_CN"
+)
+
+// The keys of messages in the catalog.
+const (
+	msgSignGuideTitle          = "sign-guide-title"
+	msgSignGuideTitleCommitter = "sign-guide-title-committer"
+	msgSignGuide               = "sign-guide"
+	msgSignGuideAgreements     = "sign-guide-agreements"
+	msgSignGuideAgreement      = "sign-guide-agreement"
+	msgCommitsNotShown         = "commits-not-shown"
+	msgUnsignedCommit          = "unsigned-commit"
+	msgRoleAuthor              = "role-author"
+	msgRoleCommitter           = "role-committer"
+	msgMinimalSignGuideTitle   = "minimal-sign-guide-title"
+	msgMinimalSignGuideItem    = "minimal-sign-guide-item"
+	msgMinimalSignGuideCheck   = "minimal-sign-guide-check"
+	msgApprovalsReset          = "approvals-reset"
+	msgAlreadySigned           = "already-signed"
+	msgVerifiedEmails          = "verified-emails"
+	msgVerifiedEmailsExempted  = "verified-emails-exempted"
+	msgCoveredByCorporation    = "covered-by-corporation"
+	msgExemptedOnly            = "exempted-only"
+	msgStillSigned             = "still-signed"
+	msgCheckErrorTitle         = "check-error-title"
+	msgCheckErrorRetry         = "check-error-retry"
+	msgCheckErrorUnavailable   = "check-error-unavailable"
+	msgCheckErrorUnknown       = "check-error-unknown"
+	msgNoCommits               = "no-commits"
+	msgFAQLink                 = "faq-link"
+	msgFAQLinkOfRole           = "faq-link-of-role"
+	msgInvalidEmail            = "invalid-email"
+	msgStaleResults            = "stale-results"
+	msgChecking                = "checking"
+	msgClosedPR                = "closed-pr"
+	msgMergedPR                = "merged-pr"
+	msgCheckInCooldown         = "check-in-cooldown"
+	msgCheckRefused            = "check-refused"
+	msgLegacyPRTitle           = "legacy-pr-title"
+	msgLegacyPR                = "legacy-pr"
+	msgManualOverride          = "manual-override"
+	msgAMaintainer             = "a-maintainer"
+	msgEmailSigned             = "email-signed"
+	msgEmailCovered            = "email-covered"
+	msgEmailUnsigned           = "email-unsigned"
+	msgEmailExempted           = "email-exempted"
+	msgExemptEmailAdded        = "exempt-email-added"
+	msgExemptEmailRemoved      = "exempt-email-removed"
+	msgExemptEmailNotFound     = "exempt-email-not-found"
+	msgExemptEmailNone         = "exempt-email-none"
+	msgExemptEmailList         = "exempt-email-list"
+	msgExemptEmailUsage        = "exempt-email-usage"
+	msgExemptEmailRefused      = "exempt-email-refused"
+	msgExemptEmailDisabled     = "exempt-email-disabled"
+	msgLabelsManaged           = "labels-managed"
+	msgCLAYesReverted          = "cla-yes-reverted"
+	msgCLAHelp                 = "cla-help"
+	msgCLAHelpByAuthor         = "cla-help-by-author"
+	msgCLAHelpByCommitter      = "cla-help-by-committer"
+	msgCmdCheckAliases         = "cmd-check-aliases"
+	msgCmdCheck                = "cmd-check"
+	msgCmdCheckAll             = "cmd-check-all"
+	msgCmdCheckEmail           = "cmd-check-email"
+	msgCmdHelp                 = "cmd-help"
+	msgCmdStatus               = "cmd-status"
+	msgCmdExemptEmail          = "cmd-exempt-email"
+	msgCmdExempt               = "cmd-exempt"
+	msgExempted                = "exempted"
+	msgExemptRevoked           = "exempt-revoked"
+	msgExemptRefused           = "exempt-refused"
+	msgExemptUsage             = "exempt-usage"
+	msgCheckAllResult          = "check-all-result"
+	msgCheckAllFailed          = "check-all-failed"
+	msgCheckAllRefused         = "check-all-refused"
+	msgStatusRepoConfig        = "status-repo-config"
+	msgStatusBranchConfig      = "status-branch-config"
+	msgStatusByAuthor          = "status-by-author"
+	msgStatusByCommitter       = "status-by-committer"
+	msgStatusSummary           = "status-summary"
+	msgStatusTableHeader       = "status-table-header"
+	msgStatusCorporate         = "status-corporate"
+	msgEmailStatusSigned       = "email-status-signed"
+	msgEmailStatusUnsigned     = "email-status-unsigned"
+	msgEmailStatusExempt       = "email-status-exempt"
+	msgEmailStatusInvalid      = "email-status-invalid"
+	msgEmailStatusUnknown      = "email-status-unknown"
+	msgPermissionFailure       = "permission-failure"
+	msgMinimizedComment        = "minimized-comment"
+	msgUnsignedReminder        = "unsigned-reminder"
+	msgCloseWarning            = "close-warning"
+	msgAutoClosed              = "auto-closed"
+	msgOutdatedComment         = "outdated-comment"
+	msgQuotedComment           = "quoted-comment"
+	msgQuotedCommentAt         = "quoted-comment-at"
+	msgEscalation              = "escalation"
+)
+
+// messageCatalog is the user-facing messages keyed by the language. The
+// messages of en are the fallback of the ones missing in other languages.
+var messageCatalog = map[string]map[string]string{
+	langEN: {
+		msgSignGuideTitle:          "Thanks for your pull request.\n\nThe authors of the following commits have not signed the Contributor License Agreement (CLA):",
+		msgSignGuideTitleCommitter: "Thanks for your pull request.\n\nThe committers (or the authors, for the commits of lite PR) of the following commits have not signed the Contributor License Agreement (CLA):",
+		msgSignGuide:               "%s\n\n%s\n\nPlease check the %s first.\nYou can click [**here**](%s) to sign the CLA. After signing the CLA, you must comment \"/check-cla\" to check the CLA status again.",
+		msgSignGuideAgreements:     "%s\n\n%s\n\nAfter signing the agreements, you must comment \"/check-cla\" to check the CLA status again.",
+		msgSignGuideAgreement:      "**%s**: please check the %s first and click [**here**](%s) to sign it.\n\n%s",
+		msgCommitsNotShown:         "… and %d more commits not shown",
+		msgUnsignedCommit:          "%d. **%s** | %s | (%s: %s)",
+		msgRoleAuthor:              "author",
+		msgRoleCommitter:           "committer",
+		msgMinimalSignGuideTitle:   "Thanks for your pull request. The authors of %d commits have not signed the CLA.",
+		msgMinimalSignGuideItem:    "**%s**: %d commits, %s, [**sign**](%s).",
+		msgMinimalSignGuideCheck:   "After signing the CLA, you must comment \"/check-cla\" to check the CLA status again.",
+		msgApprovalsReset:          "The labels: **%s** were removed because the CLA is not signed. The reviewers need to approve it again after the CLA is signed.",
+		msgAlreadySigned:           "***@%s***, thanks for your pull request. All authors of the commits have signed the CLA. :wave: ",
+		msgVerifiedEmails:          "Verified addresses: %s.",
+		msgVerifiedEmailsExempted:  "Verified addresses: %s. The others are exempted by the maintainers.",
+		msgCoveredByCorporation:    "%s (covered by the %s corporate CLA)",
+		msgExemptedOnly:            "The check is satisfied through the exemptions by the maintainers.",
+		msgStillSigned:             "***@%s***, the CLA status has not changed. All authors of the commits have signed the CLA, nothing to do.",
+		msgCheckErrorTitle:         "Thanks for your pull request. The CLA status can't be checked at the moment.",
+		msgCheckErrorRetry:         "The check will be retried on the next update of this pull request, and you can also comment \"/check-cla\" to check the CLA status again.",
+		msgCheckErrorUnavailable:   "%s\n\nThe CLA verification system is temporarily unavailable.\n\n%s",
+		msgCheckErrorUnknown:       "%s\n\nThe authors of the following commits can't be verified because the CLA service is temporarily unavailable:\n\n%s\n\n%s",
+		msgNoCommits:               "%s\n\nThe commits of this pull request can't be retrieved yet, which may happen right after a force-push.\n\nPlease comment \"/check-cla\" to re-run the check later.",
+		msgFAQLink:                 "[**FAQs**](%s)",
+		msgFAQLinkOfRole:           "[**FAQs for the %s**](%s)",
+		msgInvalidEmail:            "***@%s***, **%s** is not a valid email. Please comment \"/check-cla someone@example.com\" to check whether an email has signed the CLA.",
+		msgStaleResults:            "The CLA service is temporarily unavailable, so the results of some authors are the last known ones and may be slightly stale.",
+		msgChecking:                "Checking the CLA status, please wait a moment...",
+		msgClosedPR:                "This pull request has been closed. The CLA check only applies to the open pull requests, so nothing is changed. Please reopen it if you intend to continue.",
+		msgMergedPR:                "This pull request has been merged. The CLA check only applies to the open pull requests, so nothing is changed. Please open a new pull request if you want to contribute more.",
+		msgCheckInCooldown:         "A check ran %d seconds ago, the results are above. Please wait a moment before checking again.",
+		msgCheckRefused:            "***@%s***, sorry, only the author of this pull request and the users of roles: **%s** can trigger the CLA check.",
+		msgLegacyPRTitle:           "Thanks for your pull request. The CLA is not enforced for it.",
+		msgLegacyPR:                "%s\n\nThe Contributor License Agreement (CLA) is only required for the pull requests created after %s. You can comment \"/check-cla\" to check the CLA status anyway.",
+		msgManualOverride:          "The CLA check is overridden by %s with the **%s** label, so the automated check is skipped for this pull request.\n\nThe CLA will be checked again once the label is removed.",
+		msgAMaintainer:             "a maintainer",
+		msgEmailSigned:             "***@%s***, the email **%s** has signed the CLA.",
+		msgEmailCovered:            "***@%s***, the email **%s** is covered by the %s corporate CLA.",
+		msgEmailUnsigned:           "***@%s***, the email **%s** has not signed the CLA. You can click [**here**](%s) to sign it.",
+		msgEmailExempted:           "***@%s***, the email **%s** is exempted from signing the CLA by the maintainers.",
+		msgExemptEmailAdded:        "The email **%s** is exempted from the CLA check in this repository by ***@%s***.\n\nReason: %s",
+		msgExemptEmailRemoved:      "The exemption of email **%s** is removed by ***@%s***.",
+		msgExemptEmailNotFound:     "***@%s***, the email **%s** is not exempted in this repository.",
+		msgExemptEmailNone:         "No email is exempted from the CLA check in this repository.",
+		msgExemptEmailList:         "The emails exempted from the CLA check in this repository:\n\n| email | reason | exempted by | exempted at |\n| --- | --- | --- | --- |",
+		msgExemptEmailUsage:        "***@%s***, the usage is:\n\n- \"/cla-exempt-email <email> <reason>\": exempt the email from the CLA check in this repository.\n- \"/cla-exempt-email remove <email>\": remove the exemption.\n- \"/cla-exempt-email list\": list the emails exempted.",
+		msgExemptEmailRefused:      "***@%s***, sorry, only the maintainers of this repository can exempt the emails from the CLA check.",
+		msgExemptEmailDisabled:     "***@%s***, sorry, exempting the emails is not enabled for the CLA robot.",
+		msgLabelsManaged:           "***@%s***, the CLA labels are managed by the CLA robot automatically, please don't change them manually. The label has been restored according to the CLA status.",
+		msgCLAYesReverted:          "***@%s***, the **%s** label can only be applied by the CLA robot when all the authors of the commits have signed the CLA. It has been reverted because the CLA check failed.\n\nIf an exception is approved, please ask the maintainers of this repository to apply the sanctioned override.",
+		msgCLAHelp:                 "The CLA robot checks whether the authors of all the commits in a pull request have signed the Contributor License Agreement (CLA), and labels the pull request with **%s** or **%s** accordingly.\n\n- To sign the CLA, click [**here**](%s).\n- Please check the [**FAQs**](%s) if you have any questions.\n- %s\n\nThe commands the robot understands:\n\n%s",
+		msgCLAHelpByAuthor:         "The CLA is checked by the email of author of each commit. If the email is not the one you signed the CLA with, please amend the commits, such as `git commit --amend --reset-author`, and push them again.",
+		msgCLAHelpByCommitter:      "The CLA is checked by the email of committer of each commit. If the email is not the one you signed the CLA with, please amend the commits, such as `git config user.email` and `git commit --amend --no-edit`, and push them again.",
+		msgCmdCheckAliases:         "%s (or %s)",
+		msgCmdCheck:                "- `%s`: check the CLA status again.",
+		msgCmdCheckAll:             "- `%s all`: re-check all the open pull requests of this repository, only for the maintainers.",
+		msgCmdCheckEmail:           "- `%s <email>`: check whether the email has signed the CLA, only for the users who can trigger the check.",
+		msgCmdHelp:                 "- `%s`: show this help message.",
+		msgCmdStatus:               "- `%s`: show the CLA status of each email of the commits.",
+		msgCmdExemptEmail:          "- `%[1]s <email> <reason>`: exempt the email from the CLA check in this repository, only for the maintainers. `%[1]s remove <email>` removes it and `%[1]s list` lists them.",
+		msgCmdExempt:               "- `%[1]s <reason>`: exempt this pull request from the CLA check, only for the maintainers. `%[1]s cancel` revokes it.",
+		msgExempted:                "The pull request is exempted from the CLA check by ***@%s***.\n\nReason: %s\n\nThe CLA will not be checked until the exemption is revoked by commenting \"/cla-exempt cancel\".",
+		msgExemptRevoked:           "The exemption from the CLA check is revoked by ***@%s***. The CLA will be checked again.",
+		msgExemptRefused:           "***@%s***, sorry, only the maintainers of this repository can exempt the pull request from the CLA check.",
+		msgExemptUsage:             "***@%s***, please state the reason, such as \"/cla-exempt approved by legal for the upstream cherry-pick\".",
+		msgCheckAllResult:          "***@%s***, all the open pull requests have been re-checked: %d pull requests re-checked, %d flipped to signed.",
+		msgCheckAllFailed:          "***@%s***, sorry, the open pull requests can't be listed at the moment, please try again later.",
+		msgCheckAllRefused:         "***@%s***, sorry, only the maintainers of this repository can re-check all the open pull requests.",
+		msgStatusRepoConfig:        "the repo-level config",
+		msgStatusBranchConfig:      "the config of branch `%s`",
+		msgStatusByAuthor:          "the email of author",
+		msgStatusByCommitter:       "the email of committer",
+		msgStatusSummary:           "The CLA status is evaluated with %s, and checked by %s.",
+		msgStatusTableHeader:       "| email | commits | status |",
+		msgStatusCorporate:         "%s (%s corporate CLA)",
+		msgEmailStatusSigned:       "signed",
+		msgEmailStatusUnsigned:     "unsigned",
+		msgEmailStatusExempt:       "exempt",
+		msgEmailStatusInvalid:      "invalid",
+		msgEmailStatusUnknown:      "unknown",
+		msgPermissionFailure:       "The CLA robot lacks the permissions to manage the labels of this repository, so the CLA is not enforced. Please grant it the push access.",
+		msgMinimizedComment:        "This comment of CLA robot is outdated because the pull request is closed.",
+		msgUnsignedReminder:        "***@%s***, this pull request has been waiting for the CLA to be signed for more than %d days. Please click [**here**](%s) to sign the CLA and comment \"/check-cla\" to check the CLA status again.",
+		msgCloseWarning:            "***@%s***, this pull request will be closed in %d days unless the CLA is signed. Please click [**here**](%s) to sign the CLA and comment \"/check-cla\" to check the CLA status again.",
+		msgAutoClosed:              "***@%s***, this pull request is closed because the CLA has not been signed for %d days. Please reopen it after signing the CLA.",
+		msgOutdatedComment:         "This comment of CLA robot is outdated, see the latest status below.",
+		msgQuotedComment:           "> ***@%s*** commented%s: %s",
+		msgQuotedCommentAt:         " at %s",
+		msgEscalation:              "%s, the CLA of this pull request has not been signed for more than %d days since the contributor was notified. The following emails remain unsigned:\n\n- %s\n\nPlease follow up with the contributor.",
+	},
+
+	langZhCN: {
+		msgSignGuideTitle:          "感谢您提交的 Pull Request。\n\n以下提交的作者尚未签署贡献者许可协议（CLA）：",
+		msgSignGuideTitleCommitter: "感谢您提交的 Pull Request。\n\n以下提交的提交者（轻量级 PR 的提交则为作者）尚未签署贡献者许可协议（CLA）：",
+		msgSignGuide:               "%s\n\n%s\n\n请先查阅%s。\n您可以点击[**这里**](%s)签署 CLA。签署完成后，请评论 \"/check-cla\" 重新检查 CLA 状态。",
+		msgSignGuideAgreements:     "%s\n\n%s\n\n签署协议后，请评论 \"/check-cla\" 重新检查 CLA 状态。",
+		msgSignGuideAgreement:      "**%s**：请先查阅%s，然后点击[**这里**](%s)签署。\n\n%s",
+		msgCommitsNotShown:         "…… 另有 %d 个提交未显示",
+		msgUnsignedCommit:          "%d. **%s** | %s | （%s：%s）",
+		msgRoleAuthor:              "作者",
+		msgRoleCommitter:           "提交者",
+		msgMinimalSignGuideTitle:   "感谢您提交的 Pull Request。共有 %d 个提交的作者尚未签署 CLA。",
+		msgMinimalSignGuideItem:    "**%s**：%d 个提交，%s，[**签署**](%s)。",
+		msgMinimalSignGuideCheck:   "签署 CLA 后，请评论 \"/check-cla\" 重新检查 CLA 状态。",
+		msgApprovalsReset:          "由于 CLA 未签署，标签 **%s** 已被移除。签署 CLA 后需要评审人重新批准。",
+		msgAlreadySigned:           "***@%s***，感谢您提交的 Pull Request。所有提交的作者均已签署 CLA。:wave: ",
+		msgVerifiedEmails:          "已验证的邮箱：%s。",
+		msgVerifiedEmailsExempted:  "已验证的邮箱：%s。其他邮箱已被维护者豁免。",
+		msgCoveredByCorporation:    "%s（由 %s 的企业 CLA 覆盖）",
+		msgExemptedOnly:            "本次检查通过维护者的豁免而满足。",
+		msgStillSigned:             "***@%s***，CLA 状态没有变化。所有提交的作者均已签署 CLA，无需任何操作。",
+		msgCheckErrorTitle:         "感谢您提交的 Pull Request。暂时无法检查 CLA 状态。",
+		msgCheckErrorRetry:         "下次更新此 Pull Request 时将重新检查，您也可以评论 \"/check-cla\" 重新检查 CLA 状态。",
+		msgCheckErrorUnavailable:   "%s\n\nCLA 验证系统暂时不可用。\n\n%s",
+		msgCheckErrorUnknown:       "%s\n\n由于 CLA 服务暂时不可用，无法验证以下提交的作者：\n\n%s\n\n%s",
+		msgNoCommits:               "%s\n\n暂时无法获取此 Pull Request 的提交，这可能发生在强制推送之后。\n\n请稍后评论 \"/check-cla\" 重新检查。",
+		msgFAQLink:                 "[**常见问题**](%s)",
+		msgFAQLinkOfRole:           "[**常见问题（%s）**](%s)",
+		msgInvalidEmail:            "***@%s***，**%s** 不是有效的邮箱。请评论 \"/check-cla someone@example.com\" 检查某个邮箱是否已签署 CLA。",
+		msgStaleResults:            "由于 CLA 服务暂时不可用，部分作者的结果为最近一次的检查结果，可能略有过时。",
+		msgChecking:                "正在检查 CLA 状态，请稍候……",
+		msgClosedPR:                "此 Pull Request 已关闭。CLA 检查仅适用于打开的 Pull Request，因此未做任何更改。如需继续，请重新打开它。",
+		msgMergedPR:                "此 Pull Request 已合入。CLA 检查仅适用于打开的 Pull Request，因此未做任何更改。如需继续贡献，请提交新的 Pull Request。",
+		msgCheckInCooldown:         "%d 秒前刚进行过检查，结果见上方。请稍后再重新检查。",
+		msgCheckRefused:            "***@%s***，抱歉，只有此 Pull Request 的作者和以下角色的用户可以触发 CLA 检查：**%s**。",
+		msgLegacyPRTitle:           "感谢您提交的 Pull Request。此 Pull Request 无需签署 CLA。",
+		msgLegacyPR:                "%s\n\n只有在 %s 之后创建的 Pull Request 才需要签署贡献者许可协议（CLA）。您仍然可以评论 \"/check-cla\" 检查 CLA 状态。",
+		msgManualOverride:          "%s 通过 **%s** 标签豁免了 CLA 检查，因此此 Pull Request 将跳过自动检查。\n\n移除该标签后将重新检查 CLA。",
+		msgAMaintainer:             "维护者",
+		msgEmailSigned:             "***@%s***，邮箱 **%s** 已签署 CLA。",
+		msgEmailCovered:            "***@%s***，邮箱 **%s** 由 %s 的企业 CLA 覆盖。",
+		msgEmailUnsigned:           "***@%s***，邮箱 **%s** 尚未签署 CLA。您可以点击[**这里**](%s)签署。",
+		msgEmailExempted:           "***@%s***，邮箱 **%s** 已被维护者豁免签署 CLA。",
+		msgExemptEmailAdded:        "邮箱 **%s** 已被 ***@%s*** 豁免此仓库的 CLA 检查。\n\n原因：%s",
+		msgExemptEmailRemoved:      "邮箱 **%s** 的豁免已被 ***@%s*** 移除。",
+		msgExemptEmailNotFound:     "***@%s***，邮箱 **%s** 在此仓库中未被豁免。",
+		msgExemptEmailNone:         "此仓库中没有被豁免 CLA 检查的邮箱。",
+		msgExemptEmailList:         "此仓库中被豁免 CLA 检查的邮箱：\n\n| 邮箱 | 原因 | 豁免人 | 豁免时间 |\n| --- | --- | --- | --- |",
+		msgExemptEmailUsage:        "***@%s***，用法如下：\n\n- \"/cla-exempt-email <email> <reason>\"：豁免该邮箱在此仓库的 CLA 检查。\n- \"/cla-exempt-email remove <email>\"：移除豁免。\n- \"/cla-exempt-email list\"：列出被豁免的邮箱。",
+		msgExemptEmailRefused:      "***@%s***，抱歉，只有此仓库的维护者可以豁免邮箱的 CLA 检查。",
+		msgExemptEmailDisabled:     "***@%s***，抱歉，CLA 机器人未启用邮箱豁免功能。",
+		msgLabelsManaged:           "***@%s***，CLA 标签由 CLA 机器人自动管理，请勿手动修改。该标签已根据 CLA 状态恢复。",
+		msgCLAYesReverted:          "***@%s***，只有当所有提交的作者都签署了 CLA 时，CLA 机器人才会添加 **%s** 标签。由于 CLA 检查未通过，该标签已被撤销。\n\n如果例外已获批准，请联系此仓库的维护者添加认可的豁免标签。",
+		msgCLAHelp:                 "CLA 机器人检查 Pull Request 中所有提交的作者是否已签署贡献者许可协议（CLA），并相应地为 Pull Request 添加 **%s** 或 **%s** 标签。\n\n- 点击[**这里**](%s)签署 CLA。\n- 如有疑问，请查阅[**常见问题**](%s)。\n- %s\n\n机器人支持的命令：\n\n%s",
+		msgCLAHelpByAuthor:         "CLA 按每个提交的作者邮箱检查。如果该邮箱不是您签署 CLA 时使用的邮箱，请修改提交（例如 `git commit --amend --reset-author`）后重新推送。",
+		msgCLAHelpByCommitter:      "CLA 按每个提交的提交者邮箱检查。如果该邮箱不是您签署 CLA 时使用的邮箱，请修改提交（例如 `git config user.email` 和 `git commit --amend --no-edit`）后重新推送。",
+		msgCmdCheckAliases:         "%s（或 %s）",
+		msgCmdCheck:                "- `%s`：重新检查 CLA 状态。",
+		msgCmdCheckAll:             "- `%s all`：重新检查此仓库所有打开的 Pull Request，仅限维护者。",
+		msgCmdCheckEmail:           "- `%s <email>`：检查该邮箱是否已签署 CLA，仅限可以触发 CLA 检查的用户。",
+		msgCmdHelp:                 "- `%s`：显示此帮助信息。",
+		msgCmdStatus:               "- `%s`：显示各提交邮箱的 CLA 状态。",
+		msgCmdExemptEmail:          "- `%[1]s <email> <reason>`：豁免该邮箱在此仓库的 CLA 检查，仅限维护者。`%[1]s remove <email>` 移除豁免，`%[1]s list` 列出豁免。",
+		msgCmdExempt:               "- `%[1]s <reason>`：豁免此 Pull Request 的 CLA 检查，仅限维护者。`%[1]s cancel` 撤销豁免。",
+		msgExempted:                "此 Pull Request 已被 ***@%s*** 豁免 CLA 检查。\n\n原因：%s\n\n在评论 \"/cla-exempt cancel\" 撤销豁免之前，将不再检查 CLA。",
+		msgExemptRevoked:           "CLA 检查的豁免已被 ***@%s*** 撤销，将重新检查 CLA。",
+		msgExemptRefused:           "***@%s***，抱歉，只有此仓库的维护者可以豁免 Pull Request 的 CLA 检查。",
+		msgExemptUsage:             "***@%s***，请说明原因，例如 \"/cla-exempt approved by legal for the upstream cherry-pick\"。",
+		msgCheckAllResult:          "***@%s***，已重新检查所有打开的 Pull Request：共检查 %d 个 Pull Request，其中 %d 个变为已签署。",
+		msgCheckAllFailed:          "***@%s***，抱歉，暂时无法列出打开的 Pull Request，请稍后重试。",
+		msgCheckAllRefused:         "***@%s***，抱歉，只有此仓库的维护者可以重新检查所有打开的 Pull Request。",
+		msgStatusRepoConfig:        "仓库级配置",
+		msgStatusBranchConfig:      "分支 `%s` 的配置",
+		msgStatusByAuthor:          "作者邮箱",
+		msgStatusByCommitter:       "提交者邮箱",
+		msgStatusSummary:           "CLA 状态根据%s评估，并按%s检查。",
+		msgStatusTableHeader:       "| 邮箱 | 提交 | 状态 |",
+		msgStatusCorporate:         "%s（%s 的企业 CLA）",
+		msgEmailStatusSigned:       "已签署",
+		msgEmailStatusUnsigned:     "未签署",
+		msgEmailStatusExempt:       "已豁免",
+		msgEmailStatusInvalid:      "无效",
+		msgEmailStatusUnknown:      "未知",
+		msgPermissionFailure:       "CLA 机器人缺少管理此仓库标签的权限，因此 CLA 未被强制执行。请授予它推送权限。",
+		msgMinimizedComment:        "由于此 Pull Request 已关闭，CLA 机器人的此评论已过时。",
+		msgUnsignedReminder:        "***@%s***，此 Pull Request 等待签署 CLA 已超过 %d 天。请点击[**这里**](%s)签署 CLA，并评论 \"/check-cla\" 重新检查 CLA 状态。",
+		msgCloseWarning:            "***@%s***，除非签署 CLA，此 Pull Request 将在 %d 天后被关闭。请点击[**这里**](%s)签署 CLA，并评论 \"/check-cla\" 重新检查 CLA 状态。",
+		msgAutoClosed:              "***@%s***，由于 CLA 已有 %d 天未签署，此 Pull Request 已被关闭。请在签署 CLA 后重新打开它。",
+		msgOutdatedComment:         "CLA 机器人的此评论已过时，最新状态请见下方。",
+		msgQuotedComment:           "> ***@%s*** %s评论：%s",
+		msgQuotedCommentAt:         "于 %s ",
+		msgEscalation:              "%s，此 Pull Request 在通知贡献者后已超过 %d 天仍未签署 CLA。以下邮箱仍未签署：\n\n- %s\n\n请跟进该贡献者。",
+	},
+}
+
+func isSupportedLanguage(lang string) bool {
+	_, ok := messageCatalog[lang]
+
+	return ok
+}
+
+// message returns the message of key in lang, or the one of en if it is missing.
+func message(lang, key string) string {
+	if v, ok := messageCatalog[lang][key]; ok {
+		return v
+	}
+
+	return messageCatalog[langEN][key]
+}
+
+func signGuideTitle(lang string, byCommitter bool) string {
+	if byCommitter {
+		return message(lang, msgSignGuideTitleCommitter)
+	}
+
+	return message(lang, msgSignGuideTitle)
+}
+
+// signGuide generates the guide of the default agreement. faq is the links of FAQs.
+func signGuide(lang, title, signURL, cInfo, faq string) string {
+	return fmt.Sprintf(message(lang, msgSignGuide), title, cInfo, faq, signURL)
+}
+
+// faqLinks renders the links of FAQs picked for the unsigned commits of r.
+func faqLinks(lang string, r *agreementResult) string {
+	if len(r.faqs) == 0 {
+		return fmt.Sprintf(message(lang, msgFAQLink), r.agreement.FAQURL)
+	}
+
+	links := make([]string, 0, len(r.faqs))
+	for _, item := range r.faqs {
+		if item.role == "" {
+			links = append(links, fmt.Sprintf(message(lang, msgFAQLink), item.url))
+		} else {
+			links = append(links, fmt.Sprintf(message(lang, msgFAQLinkOfRole), roleName(lang, item.role), item.url))
+		}
+	}
+
+	return strings.Join(links, ", ")
+}
+
+// fitSignGuide generates the sign guide within the max bytes of comment. The
+// commits listed are truncated until it fits, while the title and the links are
+// always kept. The minimal guide with only the counts and links is the last resort.
+func fitSignGuide(cfg *botConfig, results []agreementResult, author, welcome string) string {
+	// The room is reserved for the marker, fingerprint and footer of comment.
+	budget := cfg.MaxCommentBytes - commentOverheadBytes
+
+	total := 0
+	for i := range results {
+		total += len(results[i].unsigned)
+	}
+
+	if v := generateSignGuide(cfg, results, author, welcome, total); len(v) <= budget {
+		return v
+	}
+
+	// Find the max number of commits listed by binary search.
+	best := -1
+	for lo, hi := 0, total-1; lo <= hi; {
+		mid := (lo + hi) / 2
+		if len(generateSignGuide(cfg, results, author, welcome, mid)) <= budget {
+			best = mid
+			lo = mid + 1
+		} else {
+			hi = mid - 1
+		}
+	}
+
+	if best >= 0 {
+		return generateSignGuide(cfg, results, author, welcome, best)
+	}
+
+	return minimalSignGuide(cfg.CommentLanguage, results, author, total)
+}
+
+// generateSignGuide generates the sign guide which lists at most limit commits.
+// It will mention the author if it is not empty, and the welcome paragraph
+// will be prepended if it is not empty.
+func generateSignGuide(cfg *botConfig, results []agreementResult, author, welcome string, limit int) string {
+	lang := cfg.CommentLanguage
+
+	title := signGuideTitle(lang, cfg.CheckByCommitter)
+	if welcome != "" {
+		title = welcome + "\n\n" + title
+	}
+	if author != "" {
+		title = fmt.Sprintf("@%s %s", author, title)
+	}
+
+	if len(results) == 1 && results[0].agreement.isDefault() {
+		a := &results[0].agreement
+
+		return signGuide(
+			lang, title, a.SignURL,
+			generateUnSignComment(cfg, results[0].unsigned, limit), faqLinks(lang, &results[0]),
+		)
+	}
+
+	items := make([]string, 0, len(results))
+	for i := range results {
+		a := &results[i].agreement
+		commits := results[i].unsigned
+
+		items = append(items, fmt.Sprintf(
+			message(lang, msgSignGuideAgreement),
+			a.displayName(), faqLinks(lang, &results[i]), a.SignURL, generateUnSignComment(cfg, commits, limit),
+		))
+
+		if limit -= len(commits); limit < 0 {
+			limit = 0
+		}
+	}
+
+	return fmt.Sprintf(message(lang, msgSignGuideAgreements), title, strings.Join(items, "\n\n"))
+}
+
+// minimalSignGuide generates the guide with only the counts and links, which is
+// used when even the guide without commits listed exceeds the budget.
+func minimalSignGuide(lang string, results []agreementResult, author string, total int) string {
+	items := make([]string, 0, len(results)+2)
+
+	title := fmt.Sprintf(message(lang, msgMinimalSignGuideTitle), total)
+	if author != "" {
+		title = fmt.Sprintf("@%s %s", author, title)
+	}
+	items = append(items, title)
+
+	for i := range results {
+		a := &results[i].agreement
+
+		items = append(items, fmt.Sprintf(
+			message(lang, msgMinimalSignGuideItem),
+			a.displayName(), len(results[i].unsigned), faqLinks(lang, &results[i]), a.SignURL,
+		))
+	}
+
+	items = append(items, message(lang, msgMinimalSignGuideCheck))
+
+	return strings.Join(items, "\n\n")
+}
+
+// generateUnSignComment lists at most limit commits with their indexes, and the rest are counted
+// in the language of comment.
+func generateUnSignComment(cfg *botConfig, commits []unsignedCommit, limit int) string {
+	if len(commits) == 0 {
+		return ""
+	}
+
+	lang := cfg.CommentLanguage
+
+	omitted := 0
+	if limit < len(commits) {
+		omitted = len(commits) - limit
+		commits = commits[:limit]
+	}
+
+	cs := make([]string, 0, len(commits)+1)
+	for i, item := range commits {
+		c := item.commit
+
+		msg := ""
+		if c.Commit != nil {
+			msg = c.Commit.Message
+		}
+
+		who := cfg.displayEmail(item.email)
+		if item.login != "" {
+			who += ", @" + item.login
+		}
+
+		cs = append(cs, fmt.Sprintf(
+			message(lang, msgUnsignedCommit), i+1, shortSHA(c.Sha), msg, roleName(lang, item.role), who,
+		))
+	}
+
+	if omitted > 0 {
+		cs = append(cs, fmt.Sprintf(message(lang, msgCommitsNotShown), omitted))
+	}
+
+	return strings.Join(cs, "\n")
+}
+
+// roleName returns the name of role, author or committer, in the language.
+func roleName(lang, role string) string {
+	if role == roleCommitter {
+		return message(lang, msgRoleCommitter)
+	}
+
+	return message(lang, msgRoleAuthor)
+}
+
+// emailStatusName returns the name of status of email in the language.
+func emailStatusName(lang, status string) string {
+	keys := map[string]string{
+		emailSigned:   msgEmailStatusSigned,
+		emailUnsigned: msgEmailStatusUnsigned,
+		emailExempt:   msgEmailStatusExempt,
+		emailInvalid:  msgEmailStatusInvalid,
+		emailUnknown:  msgEmailStatusUnknown,
+	}
+
+	if key, ok := keys[status]; ok {
+		return message(lang, key)
+	}
+
+	return status
+}
+
+func staleResultsNote(lang string) string {
+	return message(lang, msgStaleResults)
+}
+
+func approvalsResetNote(lang string, labels []string) string {
+	return fmt.Sprintf(message(lang, msgApprovalsReset), strings.Join(labels, ", "))
+}
+
+// coveredEmail appends the corporation whose CLA covers the email if it is known.
+// email is the one for display, which is masked if configured.
+func coveredEmail(lang, email string, signing *signingInfo) string {
+	if corp := signing.coveredByCorporation(); corp != "" {
+		return fmt.Sprintf(message(lang, msgCoveredByCorporation), email, corp)
+	}
+
+	return email
+}
+
+// alreadySigned generates the confirmation of signed. emails are the verified
+// addresses, and the ones exempted by the maintainers are not listed.
+func alreadySigned(lang, user string, emails []string, exempted bool) string {
+	r := fmt.Sprintf(message(lang, msgAlreadySigned), user)
+
+	switch {
+	case len(emails) > 0 && exempted:
+		r += "\n\n" + fmt.Sprintf(message(lang, msgVerifiedEmailsExempted), strings.Join(emails, ", "))
+	case len(emails) > 0:
+		r += "\n\n" + fmt.Sprintf(message(lang, msgVerifiedEmails), strings.Join(emails, ", "))
+	case exempted:
+		r += "\n\n" + message(lang, msgExemptedOnly)
+	}
+
+	return r
+}
+
+func stillSigned(lang, user string) string {
+	return fmt.Sprintf(message(lang, msgStillSigned), user)
+}
+
+func noCommitsNotice(lang string) string {
+	return fmt.Sprintf(message(lang, msgNoCommits), checkCLAErrorNoticeTitle(lang))
+}
+
+func checkCLAErrorNoticeTitle(lang string) string {
+	return message(lang, msgCheckErrorTitle)
+}
+
+// checkCLAErrorNotice generates the notice of checking CLA failed.
+// cInfo lists the commits whose authors can't be verified, and it can be empty.
+func checkCLAErrorNotice(lang, cInfo string) string {
+	title, retry := checkCLAErrorNoticeTitle(lang), message(lang, msgCheckErrorRetry)
+
+	if cInfo == "" {
+		return fmt.Sprintf(message(lang, msgCheckErrorUnavailable), title, retry)
+	}
+
+	return fmt.Sprintf(message(lang, msgCheckErrorUnknown), title, cInfo, retry)
+}
+
+func invalidEmailNotice(lang, user, email string) string {
+	return fmt.Sprintf(message(lang, msgInvalidEmail), user, email)
+}
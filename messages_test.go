@@ -0,0 +1,421 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	sdk "github.com/opensourceways/go-gitee/gitee"
+)
+
+func TestGenerateUnSignCommentDisplayEmail(t *testing.T) {
+	commits := []unsignedCommit{{
+		commit: &sdk.PullRequestCommits{Sha: "1234abcd5678"},
+		email:  "alice@example.com",
+		role:   "author",
+	}}
+
+	cases := []struct {
+		name string
+		mask bool
+		want string
+		hide string
+	}{
+		{name: "plain", mask: false, want: "alice@example.com"},
+		{name: "masked", mask: true, want: "a***@example.com", hide: "alice@"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &botConfig{MaskEmails: tc.mask}
+
+			got := generateUnSignComment(cfg, commits, len(commits))
+			if !strings.Contains(got, tc.want) {
+				t.Fatalf("expect %q in %q", tc.want, got)
+			}
+
+			if tc.hide != "" && strings.Contains(got, tc.hide) {
+				t.Fatalf("expect %q hidden in %q", tc.hide, got)
+			}
+		})
+	}
+}
+
+func TestGenerateUnSignCommentLanguage(t *testing.T) {
+	commits := []unsignedCommit{
+		{
+			commit: &sdk.PullRequestCommits{Sha: "1111aaaa", Commit: &sdk.GitCommit{Message: "fix"}},
+			email:  "alice@example.com",
+			role:   roleAuthor,
+		},
+		{commit: &sdk.PullRequestCommits{Sha: "2222bbbb"}, email: "bob@example.com", role: roleCommitter},
+	}
+
+	cases := []struct {
+		name string
+		lang string
+		want string
+	}{
+		{
+			name: "default",
+			want: "1. **1111aaaa** | fix | (author: alice@example.com)\n… and 1 more commits not shown",
+		},
+		{
+			name: "english",
+			lang: langEN,
+			want: "1. **1111aaaa** | fix | (author: alice@example.com)\n… and 1 more commits not shown",
+		},
+		{
+			name: "chinese",
+			lang: langZhCN,
+			want: "1. **1111aaaa** | fix | （作者：alice@example.com）\n…… 另有 1 个提交未显示",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &botConfig{CommentLanguage: tc.lang}
+
+			if got := generateUnSignComment(cfg, commits, 1); got != tc.want {
+				t.Fatalf("expect %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestAlreadySigned(t *testing.T) {
+	title := "***@alice***, thanks for your pull request. All authors of the commits have signed the CLA. :wave: "
+
+	cases := []struct {
+		name     string
+		lang     string
+		emails   []string
+		exempted bool
+		want     string
+	}{
+		{name: "no email", lang: langEN, want: title},
+		{
+			name:   "one address",
+			lang:   langEN,
+			emails: []string{"a***@example.com"},
+			want:   title + "\n\nVerified addresses: a***@example.com.",
+		},
+		{
+			name:   "multiple addresses",
+			lang:   langEN,
+			emails: []string{"a***@example.com", "b***@example.org"},
+			want:   title + "\n\nVerified addresses: a***@example.com, b***@example.org.",
+		},
+		{
+			name:     "addresses and exemptions",
+			lang:     langEN,
+			emails:   []string{"a***@example.com"},
+			exempted: true,
+			want:     title + "\n\nVerified addresses: a***@example.com. The others are exempted by the maintainers.",
+		},
+		{
+			name:     "exemptions only",
+			lang:     langEN,
+			exempted: true,
+			want:     title + "\n\nThe check is satisfied through the exemptions by the maintainers.",
+		},
+		{
+			name:   "multiple addresses in chinese",
+			lang:   langZhCN,
+			emails: []string{"a***@example.com", "b***@example.org"},
+			want: "***@alice***，感谢您提交的 Pull Request。所有提交的作者均已签署 CLA。:wave: " +
+				"\n\n已验证的邮箱：a***@example.com, b***@example.org。",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := alreadySigned(tc.lang, "alice", tc.emails, tc.exempted); got != tc.want {
+				t.Fatalf("expect %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestVerifiedEmails(t *testing.T) {
+	results := []agreementResult{
+		{emails: []emailResult{
+			{email: "alice@example.com", status: emailSigned},
+			{email: "bob@example.org", status: emailExempt},
+			{email: "carol@example.com", status: emailUnsigned},
+		}},
+		{emails: []emailResult{
+			{email: "alice@example.com", status: emailSigned},
+			{email: "dave@example.net", status: emailSigned},
+		}},
+	}
+
+	cases := []struct {
+		name         string
+		mask         bool
+		want         []string
+		wantExempted bool
+	}{
+		{
+			name:         "plain",
+			want:         []string{"alice@example.com", "dave@example.net"},
+			wantExempted: true,
+		},
+		{
+			name:         "masked",
+			mask:         true,
+			want:         []string{"a***@example.com", "d***@example.net"},
+			wantExempted: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, exempted := verifiedEmails(results, &botConfig{MaskEmails: tc.mask})
+			if !reflect.DeepEqual(got, tc.want) || exempted != tc.wantExempted {
+				t.Fatalf("expect %v and %v, got %v and %v", tc.want, tc.wantExempted, got, exempted)
+			}
+		})
+	}
+}
+
+func TestFitSignGuide(t *testing.T) {
+	cfg := newTestConfig("https://example.com/check")
+
+	unsigned := make([]unsignedCommit, 20)
+	for i := range unsigned {
+		unsigned[i] = unsignedCommit{
+			commit: &sdk.PullRequestCommits{
+				Sha:    fmt.Sprintf("%08d", i),
+				Commit: &sdk.GitCommit{Message: strings.Repeat("x", 50)},
+			},
+			email: fmt.Sprintf("user%d@example.com", i),
+			role:  roleAuthor,
+		}
+	}
+
+	results := []agreementResult{{agreement: cfg.defaultAgreement(), unsigned: unsigned}}
+
+	full := generateSignGuide(cfg, results, "alice", "", len(unsigned))
+	truncated := generateSignGuide(cfg, results, "alice", "", len(unsigned)-1)
+
+	cases := []struct {
+		name   string
+		budget int
+		want   string
+	}{
+		{name: "fit exactly", budget: len(full), want: full},
+		{name: "one byte over", budget: len(full) - 1, want: truncated},
+		{name: "fit the truncated exactly", budget: len(truncated), want: truncated},
+		{
+			name:   "no commit listed",
+			budget: len(generateSignGuide(cfg, results, "alice", "", 0)),
+			want:   generateSignGuide(cfg, results, "alice", "", 0),
+		},
+		{name: "minimal", budget: 10, want: minimalSignGuide(cfg.CommentLanguage, results, "alice", len(unsigned))},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := *cfg
+			c.MaxCommentBytes = tc.budget + commentOverheadBytes
+
+			if got := fitSignGuide(&c, results, "alice", ""); got != tc.want {
+				t.Fatalf("expect %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+var messageVerbRe = regexp.MustCompile(`%(?:\[(\d+)\])?([sdv])`)
+
+// messageArgs returns the arguments which fit the verbs of the message.
+func messageArgs(msg string) []interface{} {
+	var args []interface{}
+
+	n := 0
+	for _, m := range messageVerbRe.FindAllStringSubmatch(msg, -1) {
+		i := n
+		if m[1] != "" {
+			i, _ = strconv.Atoi(m[1])
+			i--
+		}
+		n = i + 1
+
+		for len(args) <= i {
+			args = append(args, nil)
+		}
+
+		if m[2] == "d" {
+			args[i] = 1
+		} else {
+			args[i] = "x"
+		}
+	}
+
+	return args
+}
+
+func TestMessageCatalog(t *testing.T) {
+	for lang, messages := range messageCatalog {
+		for key, en := range messageCatalog[langEN] {
+			t.Run(lang+"/"+key, func(t *testing.T) {
+				msg, ok := messages[key]
+				if !ok {
+					t.Fatalf("the message is missing")
+				}
+
+				if strings.TrimSpace(msg) == "" {
+					t.Fatalf("the message is empty")
+				}
+
+				if got := fmt.Sprintf(msg, messageArgs(en)...); strings.Contains(got, "%!") {
+					t.Fatalf("the verbs don't match the ones of %s: %s", langEN, got)
+				}
+			})
+		}
+
+		for key := range messages {
+			if _, ok := messageCatalog[langEN][key]; !ok {
+				t.Errorf("the message %s of %s is not in %s", key, lang, langEN)
+			}
+		}
+	}
+}
+
+func TestLocalizedNotices(t *testing.T) {
+	commit := &sdk.PullRequestCommits{Sha: "1234abcd5678"}
+	results := []agreementResult{{
+		agreement: agreementConfig{Name: "Individual CLA"},
+		emails: []emailResult{{
+			email: "alice@example.com", role: roleAuthor, status: emailUnsigned,
+			commits: []*sdk.PullRequestCommits{commit},
+		}},
+	}}
+
+	cases := []struct {
+		name   string
+		render func(lang string) string
+		en     string
+		zh     string
+	}{
+		{
+			name:   "exempted",
+			render: func(lang string) string { return exemptedNotice(lang, "alice", "approved") },
+			en:     "exempted from the CLA check by ***@alice***.\n\nReason: approved",
+			zh:     "已被 ***@alice*** 豁免 CLA 检查。\n\n原因：approved",
+		},
+		{
+			name:   "exemption revoked",
+			render: func(lang string) string { return exemptRevokedNotice(lang, "alice") },
+			en:     "revoked by ***@alice***",
+			zh:     "已被 ***@alice*** 撤销",
+		},
+		{
+			name:   "exemption refused",
+			render: func(lang string) string { return exemptRefusedNotice(lang, "alice") },
+			en:     "only the maintainers of this repository can exempt the pull request",
+			zh:     "只有此仓库的维护者可以豁免 Pull Request",
+		},
+		{
+			name:   "exemption usage",
+			render: func(lang string) string { return exemptUsageNotice(lang, "alice") },
+			en:     "please state the reason",
+			zh:     "请说明原因",
+		},
+		{
+			name:   "check all result",
+			render: func(lang string) string { return checkAllResult(lang, "alice", 3, 1) },
+			en:     "3 pull requests re-checked, 1 flipped to signed",
+			zh:     "共检查 3 个 Pull Request，其中 1 个变为已签署",
+		},
+		{
+			name:   "check all failed",
+			render: func(lang string) string { return checkAllFailedNotice(lang, "alice") },
+			en:     "can't be listed at the moment",
+			zh:     "暂时无法列出",
+		},
+		{
+			name:   "check all refused",
+			render: func(lang string) string { return checkAllRefusedNotice(lang, "alice") },
+			en:     "only the maintainers of this repository can re-check",
+			zh:     "只有此仓库的维护者可以重新检查",
+		},
+		{
+			name: "status breakdown",
+			render: func(lang string) string {
+				return claStatusBreakdown(results, &botConfig{CommentLanguage: lang}, "master")
+			},
+			en: "evaluated with the repo-level config, and checked by the email of author.",
+			zh: "CLA 状态根据仓库级配置评估，并按作者邮箱检查。",
+		},
+		{
+			name: "status of email",
+			render: func(lang string) string {
+				return claStatusBreakdown(results, &botConfig{CommentLanguage: lang}, "master")
+			},
+			en: "| alice@example.com (author) | 1234abcd | unsigned |",
+			zh: "| alice@example.com (作者) | 1234abcd | 未签署 |",
+		},
+		{
+			name:   "permission failure",
+			render: func(lang string) string { return permissionFailureNotice(lang, "@admins") },
+			en:     "@admins The CLA robot lacks the permissions",
+			zh:     "@admins CLA 机器人缺少管理此仓库标签的权限",
+		},
+		{
+			name:   "minimized comment",
+			render: minimizedComment,
+			en:     "~~This comment of CLA robot is outdated because the pull request is closed.~~",
+			zh:     "~~由于此 Pull Request 已关闭，CLA 机器人的此评论已过时。~~",
+		},
+		{
+			name:   "unsigned reminder",
+			render: func(lang string) string { return unsignedReminder(lang, "alice", 7, "https://example.com/sign") },
+			en:     "waiting for the CLA to be signed for more than 7 days",
+			zh:     "等待签署 CLA 已超过 7 天。请点击[**这里**](https://example.com/sign)签署 CLA",
+		},
+		{
+			name:   "close warning",
+			render: func(lang string) string { return closeWarning(lang, "alice", 3, "https://example.com/sign") },
+			en:     "will be closed in 3 days unless the CLA is signed",
+			zh:     "除非签署 CLA，此 Pull Request 将在 3 天后被关闭",
+		},
+		{
+			name:   "auto closed",
+			render: func(lang string) string { return autoClosedNotice(lang, "alice", 30) },
+			en:     "closed because the CLA has not been signed for 30 days",
+			zh:     "由于 CLA 已有 30 天未签署，此 Pull Request 已被关闭",
+		},
+		{
+			name:   "outdated comment",
+			render: func(lang string) string { return outdatedComment(lang, markerSignGuide, "old guide") },
+			en:     "<details><summary>~~This comment of CLA robot is outdated, see the latest status below.~~</summary>\n\nold guide",
+			zh:     "<details><summary>~~CLA 机器人的此评论已过时，最新状态请见下方。~~</summary>\n\nold guide",
+		},
+		{
+			name: "quoted comment",
+			render: func(lang string) string {
+				note := &triggerNote{author: "alice", body: "/check-cla", createdAt: time.Date(2022, 1, 17, 8, 0, 0, 0, time.UTC)}
+
+				return note.quote(lang)
+			},
+			en: "> ***@alice*** commented at 2022-01-17T08:00:00Z: /check-cla\n\n",
+			zh: "> ***@alice*** 于 2022-01-17T08:00:00Z 评论：/check-cla\n\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for lang, want := range map[string]string{langEN: tc.en, langZhCN: tc.zh} {
+				if got := tc.render(lang); !strings.Contains(got, want) {
+					t.Fatalf("%s: expect %q in %q", lang, want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "cla_robot"
+
+const (
+	outcomeSigned   = "signed"
+	outcomeUnsigned = "unsigned"
+	outcomeError    = "error"
+	outcomeSkipped  = "skipped"
+)
+
+var (
+	stateTransitions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "state_transitions_total",
+			Help:      "The number of transitions of the CLA state of PRs, such as unsigned_to_signed.",
+		},
+		[]string{"org", "repo", "transition"},
+	)
+
+	eventsHandled = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "events_handled_total",
+			Help:      "The number of checks of PRs by the type of event and the outcome.",
+		},
+		[]string{"event", "outcome"},
+	)
+
+	handleDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "handle_duration_seconds",
+			Help:      "The end-to-end duration of checking a PR.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+		},
+		[]string{"event"},
+	)
+
+	commentOperations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "comment_operations_total",
+			Help:      "The number of comments created, updated or deleted successfully.",
+		},
+		[]string{"operation"},
+	)
+
+	labelOperations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "label_operations_total",
+			Help:      "The number of labels added or removed successfully.",
+		},
+		[]string{"operation"},
+	)
+
+	// cla_robot_check_verdicts_total{org, repo, verdict} counts the verdicts of
+	// checks. The repos not listed in the config are folded, see metricRepo.
+	checkVerdicts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "check_verdicts_total",
+			Help:      "The number of verdicts of checks by repo, which is signed, unsigned, exempt or error.",
+		},
+		[]string{"org", "repo", "verdict"},
+	)
+
+	// cla_robot_unsigned_emails{org, repo} is the number of distinct unsigned
+	// emails seen in the repo within unsignedEmailWindow.
+	unsignedEmailsSeen = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "unsigned_emails",
+			Help:      "The number of distinct unsigned emails seen in the repo over the rolling window.",
+		},
+		[]string{"org", "repo"},
+	)
+
+	checkerRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "checker_requests_total",
+			Help:      "The number of requests to the CLA service by the host and the outcome.",
+		},
+		[]string{"host", "outcome"},
+	)
+
+	checkerDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "checker_request_duration_seconds",
+			Help:      "The duration of requests to the CLA service.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"host"},
+	)
+
+	checkerCacheHits = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "checker_cache_hits_total",
+			Help:      "The number of checks of emails answered without requesting the CLA service.",
+		},
+		[]string{"host"},
+	)
+
+	configMisses = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "config_misses_total",
+			Help:      "The number of events of the repos which have no config.",
+		},
+	)
+
+	duplicateEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "duplicate_events_total",
+			Help:      "The number of events skipped because they were redelivered within the dedupe window.",
+		},
+		[]string{"event"},
+	)
+
+	reconcileScanned = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "reconcile_scanned_total",
+			Help:      "The number of open PRs scanned by the reconciliation.",
+		},
+	)
+
+	reconcileRepaired = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "reconcile_repaired_total",
+			Help:      "The number of PRs re-checked by the reconciliation, by the reason which is missing_label or stale.",
+		},
+		[]string{"reason"},
+	)
+
+	queuedEvents = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "queued_events",
+			Help:      "The number of events waiting for a slot of handling.",
+		},
+		[]string{"event"},
+	)
+
+	rejectedEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "rejected_events_total",
+			Help:      "The number of events rejected because the queue of handling is full.",
+		},
+		[]string{"event"},
+	)
+
+	degradedActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "degraded_mode",
+			Help:      "Whether the checks of the CLA service host fall back to the last known results, which is 1 or 0.",
+		},
+		[]string{"host"},
+	)
+
+	degradedSeconds = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "degraded_seconds_total",
+			Help:      "The time spent in the degraded mode by the CLA service host.",
+		},
+		[]string{"host"},
+	)
+
+	// cla_robot_label_drift_total{org, repo} counts the PRs whose CLA labels
+	// are found different from the intended ones after the check, such as
+	// another robot undoing them. The repos are folded as checkVerdicts.
+	labelDrifts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "label_drift_total",
+			Help:      "The number of times the CLA labels of PRs drift from the intended state after the check.",
+		},
+		[]string{"org", "repo"},
+	)
+
+	// cla_robot_permission_failures_total{org, repo} counts the mutations of
+	// PRs failed for the lack of permissions.
+	permissionFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "permission_failures_total",
+			Help:      "The number of mutations of PRs failed because the robot lacks the permissions on the repo.",
+		},
+		[]string{"org", "repo"},
+	)
+
+	// cla_robot_permission_failing{org, repo} is 1 since a mutation fails for
+	// the lack of permissions, and back to 0 once a mutation succeeds.
+	permissionFailing = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "permission_failing",
+			Help:      "Whether the robot lacks the permissions to change the PRs of repo, which is 1 or 0.",
+		},
+		[]string{"org", "repo"},
+	)
+
+	degradedResults = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "degraded_results_total",
+			Help:      "The number of checks of emails which use the last known results because the CLA service fails.",
+		},
+		[]string{"host"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		stateTransitions, eventsHandled, handleDuration,
+		commentOperations, labelOperations, configMisses,
+		checkerRequests, checkerDuration, checkerCacheHits,
+		checkVerdicts, unsignedEmailsSeen, duplicateEvents,
+		reconcileScanned, reconcileRepaired,
+		queuedEvents, rejectedEvents,
+		degradedActive, degradedSeconds, degradedResults,
+		labelDrifts, permissionFailures, permissionFailing,
+	)
+}
+
+// observeCheck records the outcome and the duration of a check. The check
+// triggered by the command is the one of note event.
+func observeCheck(byCommand bool, state string, err error, d time.Duration) {
+	event := "pull_request"
+	if byCommand {
+		event = "note"
+	}
+
+	outcome := outcomeSkipped
+	switch {
+	case err != nil || state == claStateUnknown:
+		outcome = outcomeError
+	case state == claStateSigned:
+		outcome = outcomeSigned
+	case state == claStateUnsigned:
+		outcome = outcomeUnsigned
+	}
+
+	eventsHandled.WithLabelValues(event, outcome).Inc()
+	handleDuration.WithLabelValues(event).Observe(d.Seconds())
+}
+
+const (
+	checkerOutcomeTimeout         = "timeout"
+	checkerOutcome4xx             = "4xx"
+	checkerOutcome5xx             = "5xx"
+	checkerOutcomeNetworkError    = "network_error"
+	checkerOutcomeInvalidResponse = "invalid_response"
+)
+
+// checkerOutcome classifies the result of a request to the CLA service. code
+// is the status code of response, which is 0 if there is no response.
+func checkerOutcome(ctx context.Context, v *signingInfo, code int, err error) string {
+	var ne net.Error
+
+	switch {
+	case err == nil && v.Signed:
+		return outcomeSigned
+	case err == nil:
+		return outcomeUnsigned
+	case code >= 500:
+		return checkerOutcome5xx
+	case code >= 400:
+		return checkerOutcome4xx
+	case code != 0:
+		return checkerOutcomeInvalidResponse
+	case ctx.Err() != nil || (errors.As(err, &ne) && ne.Timeout()):
+		return checkerOutcomeTimeout
+	}
+
+	return checkerOutcomeNetworkError
+}
+
+// checkerHost returns the host of check url, which labels the metrics of CLA service.
+func checkerHost(checkURL string) string {
+	if u, err := url.Parse(checkURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+
+	return "unknown"
+}
+
+func observeCheckerRequest(checkURL, outcome string, d time.Duration) {
+	host := checkerHost(checkURL)
+
+	checkerRequests.WithLabelValues(host, outcome).Inc()
+	checkerDuration.WithLabelValues(host).Observe(d.Seconds())
+}
+
+const (
+	verdictExempt = "exempt"
+
+	// otherMetricLabel is the bucket of the orgs and repos not listed in the config.
+	otherMetricLabel = "other"
+
+	// unsignedEmailWindow is the rolling window of counting the unsigned emails.
+	unsignedEmailWindow = 7 * day
+)
+
+// checkVerdict returns the verdict of check for the metrics. It is empty if
+// the check ends without a verdict, such as the PR is closed.
+func checkVerdict(state string, waived bool, err error) string {
+	switch {
+	case err != nil || state == claStateUnknown:
+		return outcomeError
+	case waived:
+		return verdictExempt
+	}
+
+	return state
+}
+
+func (bot *robot) observeVerdict(org, repo, verdict string) {
+	if verdict == "" {
+		return
+	}
+
+	v := bot.metricRepo(org, repo)
+	checkVerdicts.WithLabelValues(v[0], v[1], verdict).Inc()
+}
+
+// metricRepo returns the org and repo which label the metrics. To bound the
+// cardinality, the repo covered only by the config of its org is folded into
+// org/other, and the one not in the config at all is folded into other/other.
+func (bot *robot) metricRepo(org, repo string) [2]string {
+	c, _ := bot.latestConfig.Load().(*configuration)
+	if c == nil {
+		return [2]string{otherMetricLabel, otherMetricLabel}
+	}
+
+	orgListed := false
+	for i := range c.ConfigItems {
+		for _, v := range c.ConfigItems[i].Repos {
+			if v == org+"/"+repo {
+				return [2]string{org, repo}
+			}
+
+			if v == org {
+				orgListed = true
+			}
+		}
+	}
+
+	if orgListed {
+		return [2]string{org, otherMetricLabel}
+	}
+
+	return [2]string{otherMetricLabel, otherMetricLabel}
+}
+
+// unsignedEmailTracker records when the unsigned emails were seen in each repo,
+// so that the distinct ones within the rolling window can be counted.
+type unsignedEmailTracker struct {
+	mu sync.Mutex
+	// seen maps the repo to the time when the email was seen last time.
+	seen map[[2]string]map[string]time.Time
+}
+
+func newUnsignedEmailTracker() *unsignedEmailTracker {
+	return &unsignedEmailTracker{seen: map[[2]string]map[string]time.Time{}}
+}
+
+// observe records the unsigned emails of results and refreshes the gauge of repo.
+func (t *unsignedEmailTracker) observe(repo [2]string, results []agreementResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	m := t.seen[repo]
+	if m == nil {
+		m = map[string]time.Time{}
+		t.seen[repo] = m
+	}
+
+	now := time.Now()
+	for i := range results {
+		for _, item := range results[i].unsigned {
+			m[strings.ToLower(item.email)] = now
+		}
+	}
+
+	for k, v := range m {
+		if now.Sub(v) > unsignedEmailWindow {
+			delete(m, k)
+		}
+	}
+
+	unsignedEmailsSeen.WithLabelValues(repo[0], repo[1]).Set(float64(len(m)))
+}
+
+// metricsClient counts the mutations of comments and labels which succeed.
+type metricsClient struct {
+	iClient
+}
+
+func newMetricsClient(cli iClient) *metricsClient {
+	return &metricsClient{iClient: cli}
+}
+
+func (c *metricsClient) CreatePRComment(ctx context.Context, org, repo string, number int32, comment string) error {
+	return countOperation(commentOperations, "created", c.iClient.CreatePRComment(ctx, org, repo, number, comment))
+}
+
+func (c *metricsClient) CreatePRCommentWithID(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	comment string,
+) (int32, error) {
+	id, err := c.iClient.CreatePRCommentWithID(ctx, org, repo, number, comment)
+
+	return id, countOperation(commentOperations, "created", err)
+}
+
+func (c *metricsClient) UpdatePRComment(ctx context.Context, org, repo string, commentID int32, comment string) error {
+	return countOperation(commentOperations, "updated", c.iClient.UpdatePRComment(ctx, org, repo, commentID, comment))
+}
+
+func (c *metricsClient) DeletePRComment(ctx context.Context, org, repo string, ID int32) error {
+	return countOperation(commentOperations, "deleted", c.iClient.DeletePRComment(ctx, org, repo, ID))
+}
+
+func (c *metricsClient) AddPRLabel(ctx context.Context, org, repo string, number int32, label string) error {
+	return countOperation(labelOperations, "added", c.iClient.AddPRLabel(ctx, org, repo, number, label))
+}
+
+func (c *metricsClient) RemovePRLabel(ctx context.Context, org, repo string, number int32, label string) error {
+	return countOperation(labelOperations, "removed", c.iClient.RemovePRLabel(ctx, org, repo, number, label))
+}
+
+func countOperation(v *prometheus.CounterVec, operation string, err error) error {
+	if err == nil {
+		v.WithLabelValues(operation).Inc()
+	}
+
+	return err
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	sdk "github.com/opensourceways/go-gitee/gitee"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestCheckerOutcome(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		signing signingInfo
+		code    int
+		err     error
+		want    string
+	}{
+		{name: "signed", signing: signingInfo{Signed: true}, code: 200, want: outcomeSigned},
+		{name: "unsigned", code: 200, want: outcomeUnsigned},
+		{name: "5xx", code: 502, err: errors.New("bad gateway"), want: checkerOutcome5xx},
+		{name: "4xx", code: 404, err: errors.New("not found"), want: checkerOutcome4xx},
+		{name: "invalid response", code: 200, err: errors.New("invalid json"), want: checkerOutcomeInvalidResponse},
+		{name: "context is done", ctx: canceled, err: context.Canceled, want: checkerOutcomeTimeout},
+		{name: "timeout", err: &net.DNSError{IsTimeout: true}, want: checkerOutcomeTimeout},
+		{name: "network error", err: &net.DNSError{Err: "no such host"}, want: checkerOutcomeNetworkError},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := tc.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			if got := checkerOutcome(ctx, &tc.signing, tc.code, tc.err); got != tc.want {
+				t.Fatalf("expect %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckerHost(t *testing.T) {
+	cases := map[string]string{
+		"https://cla.example.com/api/v1/check": "cla.example.com",
+		"http://127.0.0.1:8080/check":          "127.0.0.1:8080",
+		"not a url":                            "unknown",
+		"":                                     "unknown",
+	}
+
+	for checkURL, want := range cases {
+		if got := checkerHost(checkURL); got != want {
+			t.Fatalf("%q: expect %s, got %s", checkURL, want, got)
+		}
+	}
+}
+
+func TestCheckerMetrics(t *testing.T) {
+	s := fakeChecker()
+	defer s.Close()
+
+	host := checkerHost(s.URL)
+
+	counters := func() map[string]float64 {
+		return map[string]float64{
+			outcomeSigned:     testutil.ToFloat64(checkerRequests.WithLabelValues(host, outcomeSigned)),
+			outcomeUnsigned:   testutil.ToFloat64(checkerRequests.WithLabelValues(host, outcomeUnsigned)),
+			checkerOutcome5xx: testutil.ToFloat64(checkerRequests.WithLabelValues(host, checkerOutcome5xx)),
+			"cache_hit":       testutil.ToFloat64(checkerCacheHits.WithLabelValues(host)),
+		}
+	}
+
+	bot := newRobot(
+		&fakeClient{commits: commitsOf("signed@a.com", "signed@a.com", "alice@a.com", "broken@a.com")},
+		"bot", nil, nil, 0, 0,
+	)
+	cfg := &botConfig{CheckURL: s.URL}
+
+	cases := []struct {
+		name string
+		want map[string]float64
+	}{
+		{
+			// The second commit of the same email reuses the result of the
+			// first one, which is not a hit of the cache.
+			name: "first check",
+			want: map[string]float64{outcomeSigned: 1, outcomeUnsigned: 1, checkerOutcome5xx: 1, "cache_hit": 0},
+		},
+		{
+			// Only the signed emails are cached across the checks, and
+			// they hit the cache once for each check.
+			name: "second check",
+			want: map[string]float64{outcomeSigned: 0, outcomeUnsigned: 1, checkerOutcome5xx: 1, "cache_hit": 1},
+		},
+	}
+
+	for _, tc := range cases {
+		before := counters()
+
+		if _, err := bot.getPRCommitsAbout(
+			context.Background(), "org", "repo", 1, cfg, []agreementConfig{{CheckURL: s.URL}},
+		); err != nil {
+			t.Fatalf("%s: expect no error, got %v", tc.name, err)
+		}
+
+		after := counters()
+		for k, want := range tc.want {
+			if got := after[k] - before[k]; got != want {
+				t.Fatalf("%s: expect %s to increase by %v, got %v", tc.name, k, want, got)
+			}
+		}
+	}
+
+	if n := testutil.CollectAndCount(checkerDuration); n == 0 {
+		t.Fatal("expect the duration of requests to be observed")
+	}
+}
+
+func TestObserveCheckerRequest(t *testing.T) {
+	const checkURL = "https://observe.example.com/check"
+
+	counter := checkerRequests.WithLabelValues("observe.example.com", checkerOutcomeTimeout)
+	before := testutil.ToFloat64(counter)
+
+	observeCheckerRequest(checkURL, checkerOutcomeTimeout, time.Second)
+
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Fatalf("expect the counter to increase by 1, got %v", got)
+	}
+}
+
+func TestDetectDrift(t *testing.T) {
+	cfg := newTestConfig("")
+
+	cases := []struct {
+		name     string
+		observed []sdk.Label
+		intended sets.String
+		want     float64
+	}{
+		{name: "no drift", observed: labelsOf("cla/yes", "lgtm"), intended: sets.NewString("cla/yes")},
+		{name: "label undone", observed: labelsOf("cla/no"), intended: sets.NewString("cla/yes"), want: 1},
+		{name: "label missing", observed: labelsOf("lgtm"), intended: sets.NewString("cla/yes"), want: 1},
+		{name: "other labels", observed: labelsOf("cla/yes"), intended: sets.NewString("cla/yes", "lgtm")},
+	}
+
+	// The repo is folded since there is no config delivered.
+	counter := labelDrifts.WithLabelValues(otherMetricLabel, otherMetricLabel)
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			bot := newRobot(&fakeClient{labels: tc.observed}, "bot", nil, nil, 0, 0)
+
+			before := testutil.ToFloat64(counter)
+			bot.detectDrift(context.Background(), "org", "repo", 1, cfg, tc.intended, testLog())
+
+			if got := testutil.ToFloat64(counter) - before; got != tc.want {
+				t.Fatalf("expect the drift counter to increase by %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPermissionFailureMetrics(t *testing.T) {
+	cfg := newTestConfig("")
+	cli := &fakeClient{}
+	bot := newRobot(cli, "bot", nil, nil, 0, 0)
+
+	failures := permissionFailures.WithLabelValues(otherMetricLabel, otherMetricLabel)
+	failing := permissionFailing.WithLabelValues(otherMetricLabel, otherMetricLabel)
+
+	steps := []struct {
+		name         string
+		err          error
+		wantFailures float64
+		wantFailing  float64
+	}{
+		{name: "transient failure", err: giteeStatusError(http.StatusBadGateway)},
+		{name: "permission failure", err: giteeStatusError(http.StatusForbidden), wantFailures: 1, wantFailing: 1},
+		{name: "permission failure again", err: giteeStatusError(http.StatusForbidden), wantFailures: 1, wantFailing: 1},
+		{name: "recovered"},
+	}
+
+	for _, step := range steps {
+		cli.addErr = step.err
+		before := testutil.ToFloat64(failures)
+
+		_ = bot.addLabel(context.Background(), "org", "repo", 1, cfg, sets.NewString(), "cla/yes", testLog())
+
+		if got := testutil.ToFloat64(failures) - before; got != step.wantFailures {
+			t.Fatalf("%s: expect the failures to increase by %v, got %v", step.name, step.wantFailures, got)
+		}
+
+		if got := testutil.ToFloat64(failing); got != step.wantFailing {
+			t.Fatalf("%s: expect the failing gauge to be %v, got %v", step.name, step.wantFailing, got)
+		}
+	}
+
+	// The admins are alerted only once per repo.
+	if n := len(cli.ops); n != 1 {
+		t.Fatalf("expect 1 alert posted, got %v", cli.ops)
+	}
+}
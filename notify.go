@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/opensourceways/community-robot-lib/utils"
+	sdk "github.com/opensourceways/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const unsignedEmailSubject = "Please sign the CLA for your commits in %s/%s"
+
+var unsignedEmailTmpl = template.Must(template.New("unsigned-email").Parse(
+	`Hello,
+
+Your commits in the pull request below were checked by the CLA robot, and the
+Contributor License Agreement ({{.Agreement}}) has not been signed for {{.Email}}.
+
+Pull request: {{.PRURL}}
+Sign the CLA: {{.SignURL}}
+FAQs: {{.FAQURL}}
+
+After signing, please comment "/check-cla" on the pull request to check the CLA status again.
+
+This email is sent at most once every {{.CooldownDays}} days to an address.
+`))
+
+// smtpOptions are the options of notifying the unsigned emails by SMTP.
+type smtpOptions struct {
+	server       string
+	port         int
+	username     string
+	passwordPath string
+	from         string
+	optOutPath   string
+	cooldown     time.Duration
+}
+
+func (o *smtpOptions) addFlags(fs *flag.FlagSet) {
+	fs.StringVar(
+		&o.server, "smtp-server", "",
+		"The SMTP server to notify the unsigned commit authors by email. It is disabled if empty.",
+	)
+
+	fs.IntVar(&o.port, "smtp-port", 587, "The port of SMTP server.")
+
+	fs.StringVar(&o.username, "smtp-username", "", "The username to authenticate with the SMTP server.")
+
+	fs.StringVar(
+		&o.passwordPath, "smtp-password-path", "",
+		"Path to the file containing the password of SMTP. It is required if smtp-username is set.",
+	)
+
+	fs.StringVar(&o.from, "smtp-from", "", "The from-address of the notification emails.")
+
+	fs.StringVar(
+		&o.optOutPath, "email-opt-out-path", "",
+		"Path to the file of the emails which never receive the notifications, one per line.",
+	)
+
+	fs.DurationVar(
+		&o.cooldown, "email-cooldown", 3*24*time.Hour,
+		"The min interval between two notification emails to the same address.",
+	)
+}
+
+func (o *smtpOptions) enabled() bool {
+	return o.server != ""
+}
+
+func (o *smtpOptions) validate() error {
+	if !o.enabled() {
+		return nil
+	}
+
+	if o.port <= 0 {
+		return fmt.Errorf("invalid smtp-port: %d", o.port)
+	}
+
+	if o.from == "" {
+		return errors.New("missing smtp-from")
+	}
+
+	if o.username != "" && o.passwordPath == "" {
+		return errors.New("missing smtp-password-path")
+	}
+
+	if o.cooldown <= 0 {
+		return errors.New("email-cooldown must be positive")
+	}
+
+	return nil
+}
+
+// unsignedEmail is the data of the notification email to an unsigned address.
+type unsignedEmail struct {
+	Email        string
+	Agreement    string
+	PRURL        string
+	SignURL      string
+	FAQURL       string
+	CooldownDays int
+}
+
+// emailNotifier notifies the unsigned commit authors by email, at most once
+// per cooldown for an address. The addresses opted out are never notified.
+type emailNotifier struct {
+	addr        string
+	from        string
+	username    string
+	getPassword func() []byte
+	host        string
+	cooldown    time.Duration
+
+	optOutPath string
+
+	// mu protects optOuts which can be reloaded.
+	mu      sync.RWMutex
+	optOuts sets.String
+
+	// sent records the addresses notified within the cooldown.
+	sent *ttlCache
+}
+
+func newEmailNotifier(o *smtpOptions, getPassword func() []byte) (*emailNotifier, error) {
+	optOuts, err := loadOptOuts(o.optOutPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &emailNotifier{
+		addr:        fmt.Sprintf("%s:%d", o.server, o.port),
+		from:        o.from,
+		username:    o.username,
+		getPassword: getPassword,
+		host:        o.server,
+		cooldown:    o.cooldown,
+		optOutPath:  o.optOutPath,
+		optOuts:     optOuts,
+		sent:        newTTLCache(o.cooldown),
+	}, nil
+}
+
+// loadOptOuts reads the emails opted out, one per line. The blank lines and
+// the ones starting with # are ignored.
+func loadOptOuts(path string) (sets.String, error) {
+	r := sets.NewString()
+	if path == "" {
+		return r, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if v := strings.TrimSpace(s.Text()); v != "" && !strings.HasPrefix(v, "#") {
+			r.Insert(strings.ToLower(v))
+		}
+	}
+
+	return r, s.Err()
+}
+
+// reloadOptOuts re-reads the emails opted out and returns the number of them.
+func (n *emailNotifier) reloadOptOuts() (int, error) {
+	v, err := loadOptOuts(n.optOutPath)
+	if err != nil {
+		return 0, err
+	}
+
+	n.mu.Lock()
+	n.optOuts = v
+	n.mu.Unlock()
+
+	return v.Len(), nil
+}
+
+func (n *emailNotifier) isOptedOut(email string) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.optOuts.Has(email)
+}
+
+// shouldNotify checks whether the address can be notified now, and records
+// it as notified if so.
+func (n *emailNotifier) shouldNotify(email string) bool {
+	key := strings.ToLower(email)
+	if n.isOptedOut(key) || !utils.IsValidEmail(email) {
+		return false
+	}
+
+	if _, ok := n.sent.get(key); ok {
+		return false
+	}
+
+	n.sent.set(key, true)
+
+	return true
+}
+
+func (n *emailNotifier) send(org, repo string, data *unsignedEmail) error {
+	data.CooldownDays = int(n.cooldown / day)
+	if data.CooldownDays < 1 {
+		data.CooldownDays = 1
+	}
+
+	var body bytes.Buffer
+	if err := unsignedEmailTmpl.Execute(&body, data); err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		n.from, data.Email, fmt.Sprintf(unsignedEmailSubject, org, repo),
+		strings.ReplaceAll(body.String(), "\n", "\r\n"),
+	)
+
+	var auth smtp.Auth
+	if n.username != "" {
+		auth = smtp.PlainAuth("", n.username, strings.TrimSpace(string(n.getPassword())), n.host)
+	}
+
+	return smtp.SendMail(n.addr, auth, n.from, []string{data.Email}, []byte(msg))
+}
+
+// notifyUnsigned emails the unsigned addresses of PR asynchronously, so that
+// the check will not be blocked by the SMTP server.
+func (bot *robot) notifyUnsigned(
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	results []agreementResult,
+	log *logrus.Entry,
+) {
+	n := bot.notifier
+	if n == nil {
+		return
+	}
+
+	var items []*unsignedEmail
+	for i := range results {
+		a := &results[i].agreement
+
+		for _, c := range results[i].unsigned {
+			if !n.shouldNotify(c.email) {
+				continue
+			}
+
+			items = append(items, &unsignedEmail{
+				Email:     c.email,
+				Agreement: a.displayName(),
+				PRURL:     pr.GetHtmlUrl(),
+				SignURL:   a.SignURL,
+				FAQURL:    a.FAQURL,
+			})
+		}
+	}
+
+	if len(items) == 0 {
+		return
+	}
+
+	bot.handlers.track()
+	go func() {
+		defer bot.handlers.done()
+
+		for _, item := range items {
+			if err := n.send(org, repo, item); err != nil {
+				log.WithError(err).Warningf("Could not email the unsigned address: %s.", item.Email)
+			}
+		}
+	}()
+}
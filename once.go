@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// The exit codes of checking a PR once.
+const (
+	exitSigned   = 0
+	exitUnsigned = 1
+	exitError    = 2
+)
+
+var onceTargetRe = regexp.MustCompile(`^([^/\s]+)/([^#\s]+)#(\d+)$`)
+
+// parseOnceTarget parses the PR in the format of org/repo#number.
+func parseOnceTarget(v string) (string, string, int32, error) {
+	m := onceTargetRe.FindStringSubmatch(v)
+	if m == nil {
+		return "", "", 0, fmt.Errorf("invalid pr: %s, it should be org/repo#number", v)
+	}
+
+	n, err := strconv.ParseInt(m[3], 10, 32)
+	if err != nil || n <= 0 {
+		return "", "", 0, fmt.Errorf("invalid number of pr: %s", m[3])
+	}
+
+	return m[1], m[2], int32(n), nil
+}
+
+// runOnce checks the PR in dry-run mode, prints the report and returns the
+// exit code of verdict. Nothing of the PR is changed.
+func (bot *robot) runOnce(ctx context.Context, target string, w io.Writer) int {
+	org, repo, number, err := parseOnceTarget(target)
+	if err != nil {
+		fmt.Fprintln(w, err)
+
+		return exitError
+	}
+
+	s, err := bot.prStatusOf(ctx, org, repo, number)
+	if err != nil {
+		fmt.Fprintf(w, "Could not check %s: %v\n", target, err)
+
+		return exitError
+	}
+
+	fmt.Fprintf(w, "PR:      %s/%s#%d\n", org, repo, number)
+	fmt.Fprintf(w, "Config:  config_items[%d] %v", s.Config.Index, s.Config.Repos)
+	if s.Config.Branch != "" {
+		fmt.Fprintf(w, ", branch override: %s", s.Config.Branch)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintf(w, "Verdict: %s\n", s.Verdict)
+	if s.Waiver != "" {
+		fmt.Fprintf(w, "Waiver:  %s, the commits are not checked\n", s.Waiver)
+	}
+
+	if len(s.Emails) > 0 {
+		fmt.Fprintln(w, "\nEmails:")
+		for _, e := range s.Emails {
+			fmt.Fprintf(w, "  %-40s %-9s %-10s %s", e.Email, e.Role, e.Status, e.Agreement)
+			if e.Corporation != "" {
+				fmt.Fprintf(w, " (covered by %s)", e.Corporation)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+
+	bot.printPlannedChanges(ctx, org, repo, number, s, w)
+
+	switch s.Verdict {
+	case outcomeSigned, verdictExempt:
+		return exitSigned
+	case outcomeUnsigned:
+		return exitUnsigned
+	}
+
+	return exitError
+}
+
+// printPlannedChanges prints the changes of labels and comments which a real
+// check would make according to the verdict.
+func (bot *robot) printPlannedChanges(ctx context.Context, org, repo string, number int32, s *prStatus, w io.Writer) {
+	if s.Waiver == waiverLegacyPR {
+		fmt.Fprintln(w, "\nPlanned changes: none, the pr was created before the CLA is enforced")
+
+		return
+	}
+
+	// The config applied is the one of the target branch, the same as the check.
+	v, err := bot.cli.GetGiteePullRequest(ctx, org, repo, number)
+	if err != nil {
+		fmt.Fprintf(w, "\nCould not get the pr: %v\n", err)
+
+		return
+	}
+
+	c, _ := bot.latestConfig.Load().(*configuration)
+	pr := toPullRequestHook(&v)
+	cfg := c.configFor(org, repo).configForBranch(pr.GetBase().GetRef())
+
+	labels, err := bot.getPRLabels(ctx, org, repo, number)
+	if err != nil {
+		fmt.Fprintf(w, "\nCould not get the labels: %v\n", err)
+
+		return
+	}
+
+	var add, remove []string
+	comment := ""
+
+	switch s.Verdict {
+	case outcomeSigned, verdictExempt:
+		add, remove = []string{cfg.CLALabelYes}, []string{cfg.CLALabelNo, cfg.CLALabelError}
+		if !labels.Has(cfg.CLALabelYes) {
+			comment = "post the confirmation of signed"
+		}
+	case outcomeUnsigned:
+		add, remove = []string{cfg.CLALabelNo}, []string{cfg.CLALabelYes, cfg.CLALabelError}
+		comment = "post or update the sign guide"
+	default:
+		if cfg.CLALabelError != "" {
+			add = []string{cfg.CLALabelError}
+		}
+		comment = "post the notice of checking failed"
+	}
+
+	fmt.Fprintln(w, "\nPlanned changes:")
+
+	if cfg.labelEnabled() {
+		toAdd := sets.NewString()
+		for _, l := range add {
+			if l != "" && !labels.Has(l) {
+				toAdd.Insert(l)
+			}
+		}
+
+		toRemove := labels.Intersection(sets.NewString(remove...))
+
+		fmt.Fprintf(w, "  labels to add:    %v\n", toAdd.List())
+		fmt.Fprintf(w, "  labels to remove: %v\n", toRemove.List())
+	}
+
+	if cfg.commentEnabled() && comment != "" {
+		fmt.Fprintf(w, "  comment:          %s\n", comment)
+	}
+}
+
+// checkOnce is the entry of checking a PR from the command line.
+func checkOnce(r *robot, configFile, target string) int {
+	c, err := loadConfigFile(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load the config: %v\n", err)
+
+		return exitError
+	}
+
+	r.recordConfig(c)
+
+	return r.runOnce(context.Background(), target, os.Stdout)
+}
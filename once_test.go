@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opensourceways/community-robot-lib/config"
+	sdk "github.com/opensourceways/go-gitee/gitee"
+)
+
+func TestPrintPlannedChangesOfBranch(t *testing.T) {
+	cases := []struct {
+		name   string
+		branch string
+		want   string
+	}{
+		{name: "repo config", branch: "master", want: "labels to add:    [cla/yes]"},
+		{name: "branch override", branch: "release-1.0", want: "labels to add:    [release/cla-yes]"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := &fakeClient{pr: sdk.PullRequest{Number: 1, Base: &sdk.BranchBasic{Ref: tc.branch}}}
+
+			bot := newRobot(cli, "robot", nil, nil, time.Minute, 0)
+			bot.recordConfig(&configuration{ConfigItems: []botConfig{{
+				RepoFilter:  config.RepoFilter{Repos: []string{"org/repo"}},
+				CLALabelYes: "cla/yes",
+				CLALabelNo:  "cla/no",
+				Branches:    []branchConfig{{Branch: "release-*", CLALabelYes: "release/cla-yes"}},
+			}}})
+
+			var w bytes.Buffer
+			bot.printPlannedChanges(context.Background(), "org", "repo", 1, &prStatus{Verdict: outcomeSigned}, &w)
+
+			if !strings.Contains(w.String(), tc.want) {
+				t.Fatalf("expect %q in the report:\n%s", tc.want, w.String())
+			}
+		})
+	}
+}
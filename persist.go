@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	bucketSignedEmails = "signed_emails"
+	bucketPRStates     = "pr_states"
+	bucketPRCheckedAt  = "pr_checked_at"
+	bucketUnsignedPRs  = "unsigned_prs"
+	bucketLastKnown    = "last_known_results"
+
+	// maxPendingWrites bounds the writes queued for the disk. The writes
+	// beyond it are dropped, since the store is only a warm start of caches.
+	maxPendingWrites = 1024
+)
+
+// storedItem is an item of cache written to the disk.
+type storedItem struct {
+	Value    json.RawMessage `json:"value"`
+	ExpireAt time.Time       `json:"expire_at"`
+}
+
+type storeWrite struct {
+	bucket string
+	key    string
+	value  []byte
+	// clear means removing all the items of bucket.
+	clear bool
+}
+
+// cacheStore persists the caches of signing results, the last known states
+// of PRs, when they were checked and the index of unsigned PRs in a bbolt file, so that a restart will not send all the checks to
+// the CLA service at once. It also keeps the audit history of PRs within the
+// retention. The writes go through asynchronously.
+type cacheStore struct {
+	db     *bolt.DB
+	writes chan storeWrite
+	done   chan struct{}
+
+	// mu protects closed, so that the handlers abandoned on shutdown will
+	// not write to the closed channel.
+	mu     sync.Mutex
+	closed bool
+}
+
+// openCacheStore opens the store at path. It returns nil with a warning if the
+// file can't be opened, such as it is corrupt, and the caches stay in memory only.
+func openCacheStore(path string) *cacheStore {
+	log := logrus.WithField("cache_store", path)
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		log.WithError(err).Warning("Error opening the cache store, the caches are in memory only.")
+
+		return nil
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range []string{
+			bucketSignedEmails, bucketPRStates, bucketPRCheckedAt,
+			bucketUnsignedPRs, bucketAuditRecords, bucketLastKnown,
+		} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(b)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		log.WithError(err).Warning("Error initializing the cache store, the caches are in memory only.")
+
+		return nil
+	}
+
+	s := &cacheStore{
+		db:     db,
+		writes: make(chan storeWrite, maxPendingWrites),
+		done:   make(chan struct{}),
+	}
+
+	go s.run()
+
+	return s
+}
+
+// attach hydrates the cache from the bucket and writes the later changes of
+// cache through to it. decode converts the stored value to the one of cache.
+func (s *cacheStore) attach(bucket string, c *ttlCache, decode func(json.RawMessage) (interface{}, error)) {
+	if s == nil {
+		return
+	}
+
+	log := logrus.WithField("bucket", bucket)
+
+	var expired [][]byte
+	restored := 0
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			var item storedItem
+			if err := json.Unmarshal(v, &item); err != nil {
+				expired = append(expired, append([]byte(nil), k...))
+
+				return nil
+			}
+
+			value, err := decode(item.Value)
+			if err != nil || !c.restore(string(k), value, item.ExpireAt) {
+				expired = append(expired, append([]byte(nil), k...))
+
+				return nil
+			}
+
+			restored++
+
+			return nil
+		})
+	})
+	if err != nil {
+		log.WithError(err).Warning("Error hydrating the cache.")
+	}
+
+	if len(expired) > 0 {
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket([]byte(bucket))
+			for _, k := range expired {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			log.WithError(err).Warning("Error pruning the cache store.")
+		}
+	}
+
+	log.WithFields(logrus.Fields{
+		"restored": restored,
+		"pruned":   len(expired),
+	}).Info("Hydrated the cache.")
+
+	c.persist = func(key string, v interface{}, expireAt time.Time) {
+		s.put(bucket, key, v, expireAt)
+	}
+}
+
+func (s *cacheStore) put(bucket, key string, v interface{}, expireAt time.Time) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	b, err := json.Marshal(storedItem{Value: value, ExpireAt: expireAt})
+	if err != nil {
+		return
+	}
+
+	s.enqueue(storeWrite{bucket: bucket, key: key, value: b})
+}
+
+// clear removes all the items of bucket, such as when the cache is flushed.
+func (s *cacheStore) clear(bucket string) {
+	if s != nil {
+		s.enqueue(storeWrite{bucket: bucket, clear: true})
+	}
+}
+
+func (s *cacheStore) enqueue(w storeWrite) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.writes <- w:
+	default:
+		logrus.WithField("bucket", w.bucket).Warning("Dropped a write of the cache store since too many are pending.")
+	}
+}
+
+func (s *cacheStore) run() {
+	defer close(s.done)
+
+	for w := range s.writes {
+		batch := []storeWrite{w}
+
+		// Write the pending ones in a transaction to save the fsyncs.
+		for n := len(s.writes); n > 0; n-- {
+			batch = append(batch, <-s.writes)
+		}
+
+		if err := s.db.Update(func(tx *bolt.Tx) error {
+			for _, item := range batch {
+				if err := applyStoreWrite(tx, item); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}); err != nil {
+			logrus.WithError(err).Warning("Error writing the cache store.")
+		}
+	}
+}
+
+func applyStoreWrite(tx *bolt.Tx, w storeWrite) error {
+	if w.clear {
+		if err := tx.DeleteBucket([]byte(w.bucket)); err != nil {
+			return err
+		}
+
+		_, err := tx.CreateBucketIfNotExists([]byte(w.bucket))
+
+		return err
+	}
+
+	b, err := tx.CreateBucketIfNotExists([]byte(w.bucket))
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(w.key), w.value)
+}
+
+// close writes the pending items and closes the file. The later changes of
+// caches are not persisted.
+func (s *cacheStore) close() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.closed = true
+	close(s.writes)
+	s.mu.Unlock()
+
+	<-s.done
+
+	if err := s.db.Close(); err != nil {
+		logrus.WithError(err).Warning("Error closing the cache store.")
+	}
+}
+
+// attachCacheStore hydrates the caches of robot from the store, and persists
+// their later changes. It must be called before handling any event.
+func (bot *robot) attachCacheStore(s *cacheStore) {
+	if s == nil {
+		return
+	}
+
+	bot.cacheStore = s
+	if bot.audit != nil {
+		bot.audit.store = s
+	}
+
+	s.attach(bucketSignedEmails, bot.signedEmails, func(b json.RawMessage) (interface{}, error) {
+		var v signingInfo
+		err := json.Unmarshal(b, &v)
+
+		return v, err
+	})
+
+	s.attach(bucketPRStates, bot.transitions.states, func(b json.RawMessage) (interface{}, error) {
+		var v string
+		err := json.Unmarshal(b, &v)
+
+		return v, err
+	})
+
+	s.attach(bucketPRCheckedAt, bot.lastCheckedAt, func(b json.RawMessage) (interface{}, error) {
+		var v time.Time
+		err := json.Unmarshal(b, &v)
+
+		return v, err
+	})
+
+	s.attach(bucketUnsignedPRs, bot.unsignedPRs.items, func(b json.RawMessage) (interface{}, error) {
+		var v []prRef
+		err := json.Unmarshal(b, &v)
+
+		return v, err
+	})
+
+	s.attach(bucketLastKnown, bot.lastKnown, func(b json.RawMessage) (interface{}, error) {
+		var v lastKnownResult
+		err := json.Unmarshal(b, &v)
+
+		return v, err
+	})
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	sdk "github.com/opensourceways/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	waiverExempted       = "exempted"
+	waiverManualOverride = "manual_override"
+	waiverSameOrgSource  = "same_org_source"
+	waiverLitePR         = "lite_pr"
+	waiverLegacyPR       = "legacy_pr"
+
+	// prStatusFreshness is how long the status recorded by the last check is
+	// served. The PR is evaluated live after it expires.
+	prStatusFreshness = 10 * time.Minute
+
+	prStatusSourceCache = "cache"
+	prStatusSourceLive  = "live"
+)
+
+// emailStatus is the status of an email against an agreement.
+type emailStatus struct {
+	Email       string `json:"email"`
+	Role        string `json:"role"`
+	Agreement   string `json:"agreement"`
+	Status      string `json:"status"`
+	Corporation string `json:"corporation,omitempty"`
+}
+
+// configRef tells which config item applies to the PR.
+type configRef struct {
+	// Index is the index of the item in config_items.
+	Index int      `json:"index"`
+	Repos []string `json:"repos"`
+
+	// Branch is the branch override which applies. It is empty if none.
+	Branch string `json:"branch,omitempty"`
+}
+
+// prStatus is the view of robot on the CLA of PR.
+type prStatus struct {
+	Org    string `json:"org"`
+	Repo   string `json:"repo"`
+	Number int32  `json:"number"`
+
+	// Verdict is signed, unsigned, exempt or error.
+	Verdict string        `json:"verdict"`
+	Emails  []emailStatus `json:"emails,omitempty"`
+
+	// Waiver is why the check is skipped, such as exempted or manual_override.
+	Waiver string `json:"waiver,omitempty"`
+
+	CheckedAt time.Time  `json:"checked_at"`
+	Config    *configRef `json:"config,omitempty"`
+
+	// Source is cache if it is recorded by the last check, or live if it is
+	// evaluated for the request.
+	Source string `json:"source"`
+}
+
+func emailStatuses(results []agreementResult) []emailStatus {
+	var r []emailStatus
+	for i := range results {
+		name := results[i].agreement.displayName()
+
+		for _, e := range results[i].emails {
+			r = append(r, emailStatus{
+				Email:       e.email,
+				Role:        e.role,
+				Agreement:   name,
+				Status:      e.status,
+				Corporation: e.signing.coveredByCorporation(),
+			})
+		}
+	}
+
+	return r
+}
+
+// recordPRStatus records the result of check for the status API. The check
+// without verdict, such as the PR is closed, is not recorded.
+func (bot *robot) recordPRStatus(org, repo string, number int32, verdict, waiver string, emails []emailStatus) {
+	if verdict == "" {
+		return
+	}
+
+	bot.prStatuses.set(prKey(org, repo, number), &prStatus{
+		Org:       org,
+		Repo:      repo,
+		Number:    number,
+		Verdict:   verdict,
+		Emails:    emails,
+		Waiver:    waiver,
+		CheckedAt: time.Now(),
+		Source:    prStatusSourceCache,
+	})
+}
+
+// prStatusOf returns the status of PR recorded by the last check if it is
+// fresh, otherwise evaluates it without changing the PR.
+func (bot *robot) prStatusOf(ctx context.Context, org, repo string, number int32) (*prStatus, error) {
+	c, ok := bot.latestConfig.Load().(*configuration)
+	if !ok {
+		return nil, newAdminError(http.StatusServiceUnavailable, errConfigNotLoaded.Error())
+	}
+
+	v, err := bot.cli.GetGiteePullRequest(ctx, org, repo, number)
+	if err != nil {
+		if isClientError(err) {
+			return nil, newAdminError(http.StatusNotFound, "can't get the pr: %v", err)
+		}
+
+		return nil, newAdminError(http.StatusBadGateway, "can't get the pr: %v", err)
+	}
+
+	pr := toPullRequestHook(&v)
+	branch := pr.GetBase().GetRef()
+
+	cfg, err := bot.getConfig(c, org, repo, branch)
+	if err != nil {
+		return nil, newAdminError(http.StatusNotFound, err.Error())
+	}
+
+	item := c.configFor(org, repo)
+	ref := &configRef{Repos: item.Repos, Branch: item.matchedBranch(branch)}
+	for i := range c.ConfigItems {
+		if &c.ConfigItems[i] == item {
+			ref.Index = i
+		}
+	}
+
+	if cached, ok := bot.prStatuses.get(prKey(org, repo, number)); ok {
+		s := *(cached.(*prStatus))
+		s.Emails = append([]emailStatus(nil), s.Emails...)
+		s.Config = ref
+
+		return &s, nil
+	}
+
+	ctx, cancel := bot.newEventContext()
+	defer cancel()
+
+	s, err := bot.evaluatePR(ctx, org, repo, pr, cfg)
+	if err != nil {
+		return nil, newAdminError(http.StatusBadGateway, "can't evaluate the pr: %v", err)
+	}
+
+	s.Config = ref
+
+	return s, nil
+}
+
+// evaluatePR checks the CLA of PR like the events do, but it is read-only and
+// the labels and comments of PR are not touched.
+func (bot *robot) evaluatePR(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	cfg *botConfig,
+) (*prStatus, error) {
+	number := pr.GetNumber()
+
+	s := &prStatus{
+		Org:       org,
+		Repo:      repo,
+		Number:    number,
+		CheckedAt: time.Now(),
+		Source:    prStatusSourceLive,
+	}
+
+	labels, err := bot.getPRLabels(ctx, org, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	// The legacy PRs are skipped by the events, while "/check-cla" still checks them.
+	if cfg.isLegacyPR(pr.CreatedAt) {
+		s.Waiver = waiverLegacyPR
+	} else {
+		log := logrus.WithFields(logrus.Fields{"org": org, "repo": repo, "number": number})
+		s.Waiver = bot.waiverOf(ctx, org, repo, pr, cfg, labels, log)
+	}
+
+	if s.Waiver != "" {
+		s.Verdict = verdictExempt
+
+		return s, nil
+	}
+
+	agreements, err := bot.getApplicableAgreements(ctx, org, repo, number, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := bot.getPRCommitsAbout(ctx, org, repo, number, cfg, agreements)
+	if errors.Is(err, errNoCommits) {
+		s.Verdict = outcomeError
+
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.Emails = emailStatuses(results)
+
+	switch {
+	case len(filterUnsigned(results)) > 0:
+		s.Verdict = outcomeUnsigned
+	case len(filterUnknown(results)) > 0:
+		s.Verdict = outcomeError
+	default:
+		s.Verdict = outcomeSigned
+	}
+
+	return s, nil
+}
+
+// status replies the status of PR. The emails are masked unless the caller
+// presents an admin token.
+func (s *adminAPI) status(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "only GET is allowed")
+
+		return
+	}
+
+	q := req.URL.Query()
+	org, repo := q.Get("org"), q.Get("repo")
+
+	number, err := strconv.Atoi(q.Get("number"))
+	if err != nil || org == "" || repo == "" || number <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "org, repo and number are required")
+
+		return
+	}
+
+	v, err := s.bot.prStatusOf(req.Context(), org, repo, int32(number))
+
+	var ae *adminError
+	if errors.As(err, &ae) {
+		writeJSONError(w, ae.code, ae.reason)
+
+		return
+	}
+
+	if caller := s.auth.caller(req); caller == "" {
+		for i := range v.Emails {
+			v.Emails[i].Email = maskEmail(v.Emails[i].Email)
+		}
+	} else {
+		logrus.WithFields(logrus.Fields{
+			"audit":  "admin",
+			"caller": caller,
+			"remote": req.RemoteAddr,
+			"org":    org,
+			"repo":   repo,
+			"number": number,
+		}).Info("Read the unmasked status of pr.")
+	}
+
+	writeJSON(w, http.StatusOK, v)
+}
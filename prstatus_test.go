@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdk "github.com/opensourceways/go-gitee/gitee"
+)
+
+func TestEvaluatePRWaiver(t *testing.T) {
+	enforceAfter := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	newPR := func(namespace string, lines int32, createdAt time.Time) *sdk.PullRequestHook {
+		return &sdk.PullRequestHook{
+			Number:    1,
+			Additions: lines,
+			CreatedAt: createdAt,
+			Head:      &sdk.BranchHook{Repo: &sdk.ProjectHook{Namespace: namespace}},
+		}
+	}
+
+	cases := []struct {
+		name   string
+		cfg    botConfig
+		labels []sdk.Label
+		pr     *sdk.PullRequestHook
+		want   string
+	}{
+		{
+			name: "legacy pr",
+			cfg:  botConfig{enforceAfter: enforceAfter, ExemptSameOrgSource: true},
+			pr:   newPR("org", 100, enforceAfter.Add(-time.Hour)),
+			want: waiverLegacyPR,
+		},
+		{
+			name:   "manual override",
+			cfg:    botConfig{ManualOverrideLabel: "cla/override"},
+			labels: []sdk.Label{{Name: "cla/override"}},
+			pr:     newPR("fork", 100, enforceAfter),
+			want:   waiverManualOverride,
+		},
+		{
+			name: "same org source",
+			cfg:  botConfig{enforceAfter: enforceAfter, ExemptSameOrgSource: true},
+			pr:   newPR("org", 100, enforceAfter.Add(time.Hour)),
+			want: waiverSameOrgSource,
+		},
+		{
+			name: "lite pr",
+			cfg:  botConfig{ExemptSameOrgSource: true, LitePRMaxLines: 10},
+			pr:   newPR("fork", 3, enforceAfter),
+			want: waiverLitePR,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			bot := newRobot(&fakeClient{labels: tc.labels}, "robot", nil, nil, time.Minute, 0)
+
+			s, err := bot.evaluatePR(context.Background(), "org", "repo", tc.pr, &tc.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if s.Waiver != tc.want || s.Verdict != verdictExempt {
+				t.Fatalf("expect waiver %s with verdict %s, got %s with %s", tc.want, verdictExempt, s.Waiver, s.Verdict)
+			}
+		})
+	}
+}
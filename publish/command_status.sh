@@ -19,6 +19,11 @@ if [ -z "$repository" ]; then
 fi
 repository=${REPOSITORY_OVERRIDE:-$repository}
 
+git_sha=$(git rev-parse HEAD)
+git_sha=${GIT_SHA_OVERRIDE:-$git_sha}
+
+build_date=$(date -u +%FT%TZ)
+
 image_tag="${branch}-${commit_id}"
 image_tag=${IMAGE_TAG_OVERRIDE:-$image_tag}
 
@@ -31,6 +36,9 @@ IMAGE_REPO ${image_repo}
 IMAGE_TAG ${image_tag}
 IMAGE_ID ${image_registry}/${image_repo}:${image_tag}
 CODE_REPOSITORY ${repository}
+STABLE_VERSION ${commit_id}
+STABLE_GIT_SHA ${git_sha}
+BUILD_DATE ${build_date}
 EOF
 
 cd $work_dir
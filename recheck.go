@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opensourceways/community-robot-lib/config"
+	sdk "github.com/opensourceways/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	prsPerPage    = 100
+	maxPagesOfPRs = 50
+
+	// recheckConcurrency and recheckInterval bound the rate of checks
+	// when re-checking all the open PRs of a repo.
+	recheckConcurrency = 4
+	recheckInterval    = 500 * time.Millisecond
+)
+
+// handleCheckAllCommand re-checks all the open PRs of repo asynchronously, so
+// that the webhook handler will not be blocked. Only the maintainers can do it.
+func (bot *robot) handleCheckAllCommand(
+	ctx context.Context,
+	org, repo string,
+	prNumber int32,
+	c config.Config,
+	lang, commenter string,
+	log *logrus.Entry,
+) error {
+	v, err := bot.cli.GetUserPermissionsOfRepo(ctx, org, repo, commenter)
+	if err != nil {
+		return err
+	}
+
+	if !isMaintainerPermission(v.Permission) {
+		return bot.cli.CreatePRComment(
+			ctx, org, repo, prNumber,
+			withMarker(markerCheckAll, checkAllRefusedNotice(lang, commenter)),
+		)
+	}
+
+	bot.handlers.track()
+	go func() {
+		defer bot.handlers.done()
+
+		// The re-check outlives the event, so it is not bound by the context of it.
+		bot.recheckAllPRs(context.Background(), org, repo, prNumber, c, lang, commenter, log)
+	}()
+
+	return nil
+}
+
+func (bot *robot) recheckAllPRs(
+	ctx context.Context,
+	org, repo string,
+	prNumber int32,
+	c config.Config,
+	lang, commenter string,
+	log *logrus.Entry,
+) {
+	reply := func(content string) {
+		err := bot.cli.CreatePRComment(ctx, org, repo, prNumber, withMarker(markerCheckAll, content))
+		if err != nil {
+			log.WithError(err).Warning("Could not reply the result of re-checking all the prs.")
+		}
+	}
+
+	prs, err := listAllOpenPRs(ctx, org, repo, bot.cli)
+	if err != nil {
+		log.WithError(err).Error("Could not list the open prs.")
+		reply(checkAllFailedNotice(lang, commenter))
+
+		return
+	}
+
+	log.Infof("Start re-checking %d open prs.", len(prs))
+
+	v := bot.recheckPRs(org, repo, prs, c, log)
+
+	log.Infof("Finished re-checking open prs, %d checked and %d flipped to signed.", v.checked, v.flipped)
+
+	reply(checkAllResult(lang, commenter, v.checked, v.flipped))
+}
+
+// orgRepos returns the repos of org which are covered by the config.
+func (bot *robot) orgRepos(ctx context.Context, c *configuration, org string, log *logrus.Entry) []string {
+	seen := map[string]bool{}
+	var r []string
+
+	for i := range c.ConfigItems {
+		item := c.ConfigItems[i]
+
+		var repos []string
+		for _, v := range item.Repos {
+			if v == org || strings.HasPrefix(v, org+"/") {
+				repos = append(repos, v)
+			}
+		}
+
+		if len(repos) == 0 {
+			continue
+		}
+		item.Repos = repos
+
+		for _, v := range bot.sweptRepos(ctx, &item, log) {
+			// The repo is checked by the first config item covering it.
+			if repo := v[1]; !seen[repo] && c.configFor(org, repo) != nil {
+				seen[repo] = true
+				r = append(r, repo)
+			}
+		}
+	}
+
+	return r
+}
+
+// recheckOrg re-checks all the open PRs of the repos of org which are covered
+// by the config, such as after a corporation signs the CLA. It is idempotent,
+// so it can be run again if it is interrupted.
+func (bot *robot) recheckOrg(
+	ctx context.Context,
+	org string,
+	repos []string,
+	c config.Config,
+	log *logrus.Entry,
+) recheckSummary {
+	var total recheckSummary
+
+	for i, repo := range repos {
+		l := log.WithField("repo", repo)
+
+		prs, err := listAllOpenPRs(ctx, org, repo, bot.cli)
+		if err != nil {
+			l.WithError(err).Error("Could not list the open prs.")
+			total.errored++
+
+			continue
+		}
+
+		v := bot.recheckPRs(org, repo, prs, c, l)
+		total.merge(v)
+
+		l.WithFields(logrus.Fields{
+			"progress": fmt.Sprintf("%d/%d", i+1, len(repos)),
+			"checked":  v.checked,
+			"flipped":  v.flipped,
+			"errored":  v.errored,
+		}).Info("Re-checked the open prs of repo.")
+	}
+
+	return total
+}
+
+// recheckSummary is the outcome of re-checking a batch of PRs.
+type recheckSummary struct {
+	checked int
+	flipped int
+	errored int
+}
+
+func (s *recheckSummary) merge(v recheckSummary) {
+	s.checked += v.checked
+	s.flipped += v.flipped
+	s.errored += v.errored
+}
+
+// recheckPRs re-checks the PRs of repo with bounded concurrency and rate.
+func (bot *robot) recheckPRs(
+	org, repo string,
+	prs []sdk.PullRequest,
+	c config.Config,
+	log *logrus.Entry,
+) recheckSummary {
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+		r  recheckSummary
+	)
+
+	sem := make(chan struct{}, recheckConcurrency)
+	ticker := time.NewTicker(recheckInterval)
+	defer ticker.Stop()
+
+	for i := range prs {
+		<-ticker.C
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func(pr *sdk.PullRequestHook) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			l := log.WithField("recheck_pr", pr.GetNumber())
+
+			ctx, cancel := bot.newEventContext()
+			defer cancel()
+
+			ok, b, err := bot.recheckPR(withAuditTrigger(ctx, auditTriggerRecheck), org, repo, pr, c, l)
+			if err != nil {
+				l.WithError(err).Warning("Could not re-check the pr.")
+			}
+
+			mu.Lock()
+			if ok {
+				r.checked++
+			}
+			if b {
+				r.flipped++
+			}
+			if err != nil {
+				r.errored++
+			}
+			mu.Unlock()
+		}(toPullRequestHook(&prs[i]))
+
+		if n := i + 1; n%10 == 0 {
+			log.Infof("Re-checking open prs: %d/%d submitted.", n, len(prs))
+		}
+	}
+
+	wg.Wait()
+
+	return r
+}
+
+// recheckPR checks the PR and returns whether it is checked and whether it flips to signed.
+func (bot *robot) recheckPR(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	c config.Config,
+	log *logrus.Entry,
+) (bool, bool, error) {
+	branch := pr.GetBase().GetRef()
+
+	cfg, err := bot.getConfig(c, org, repo, branch)
+	if err != nil {
+		return false, false, err
+	}
+
+	if !cfg.TargetBranches.match(branch) {
+		return false, false, nil
+	}
+
+	prNumber := pr.GetNumber()
+	defer bot.prLocks.lock(prKey(org, repo, prNumber))()
+
+	before, err := bot.getPRLabels(ctx, org, repo, prNumber)
+	if err != nil {
+		return false, false, err
+	}
+
+	if err := bot.handle(ctx, org, repo, pr, cfg, nil, log); err != nil {
+		return true, false, err
+	}
+
+	after, err := bot.getPRLabels(ctx, org, repo, prNumber)
+	if err != nil {
+		return true, false, err
+	}
+
+	return true, !before.Has(cfg.CLALabelYes) && after.Has(cfg.CLALabelYes), nil
+}
+
+// listAllOpenPRs walks all the pages of open PRs of repo. The number of pages
+// is bounded by maxPagesOfPRs to avoid unbounded loops.
+func listAllOpenPRs(ctx context.Context, org, repo string, c iClient) ([]sdk.PullRequest, error) {
+	var r []sdk.PullRequest
+
+	for page := 1; page <= maxPagesOfPRs; page++ {
+		v, err := c.ListOpenPRsByPage(ctx, org, repo, page, prsPerPage)
+		if err != nil {
+			return nil, err
+		}
+
+		r = append(r, v...)
+
+		if len(v) < prsPerPage {
+			break
+		}
+	}
+
+	return r, nil
+}
+
+// toPullRequestHook converts the PR to the one of webhook which the checks are based on.
+func toPullRequestHook(pr *sdk.PullRequest) *sdk.PullRequestHook {
+	branch := func(b *sdk.BranchBasic) *sdk.BranchHook {
+		if b == nil {
+			return nil
+		}
+
+		r := &sdk.BranchHook{Label: b.Label, Ref: b.Ref, Sha: b.Sha}
+		if b.Repo != nil && b.Repo.Namespace != nil {
+			r.Repo = &sdk.ProjectHook{Namespace: b.Repo.Namespace.Path}
+		}
+
+		return r
+	}
+
+	r := &sdk.PullRequestHook{
+		Id:      pr.Id,
+		Number:  pr.Number,
+		State:   pr.State,
+		HtmlUrl: pr.HtmlUrl,
+		Title:   pr.Title,
+		Head:    branch(pr.Head),
+		Base:    branch(pr.Base),
+	}
+
+	if pr.User != nil {
+		r.User = &sdk.UserHook{Login: pr.User.Login}
+	}
+
+	if t, err := time.Parse(time.RFC3339, pr.CreatedAt); err == nil {
+		r.CreatedAt = t
+	}
+
+	return r
+}
+
+func checkAllResult(lang, user string, checked, flipped int) string {
+	return fmt.Sprintf(message(lang, msgCheckAllResult), user, checked, flipped)
+}
+
+func checkAllFailedNotice(lang, user string) string {
+	return fmt.Sprintf(message(lang, msgCheckAllFailed), user)
+}
+
+func checkAllRefusedNotice(lang, user string) string {
+	return fmt.Sprintf(message(lang, msgCheckAllRefused), user)
+}
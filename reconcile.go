@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	sdk "github.com/opensourceways/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// reconcilePace is the min interval between two checks of the
+	// reconciliation, so that it will not exhaust the rate limits of APIs.
+	reconcilePace = 2 * time.Second
+
+	repairMissingLabel = "missing_label"
+	repairStale        = "stale"
+)
+
+// runReconciler re-checks the open PRs which missed the events periodically
+// until stop is closed.
+func (bot *robot) runReconciler(interval, staleAfter time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !bot.handlers.accept() {
+				return
+			}
+
+			bot.reconcile(context.Background(), staleAfter, stop)
+			bot.handlers.done()
+		}
+	}
+}
+
+// reconcile walks the open PRs of all the repos in the config, and re-checks
+// the ones without the cla labels or not checked within staleAfter.
+func (bot *robot) reconcile(ctx context.Context, staleAfter time.Duration, stop <-chan struct{}) {
+	c, ok := bot.latestConfig.Load().(*configuration)
+	if !ok {
+		return
+	}
+
+	log := logrus.WithField("reconcile", true)
+
+	pace := time.NewTicker(reconcilePace)
+	defer pace.Stop()
+
+	scanned, repaired := 0, 0
+	seen := sets.NewString()
+
+	for i := range c.ConfigItems {
+		for _, orgRepo := range bot.sweptRepos(ctx, &c.ConfigItems[i], log) {
+			org, repo := orgRepo[0], orgRepo[1]
+
+			// The repo is checked by the first config item covering it.
+			if seen.Has(org+"/"+repo) || c.configFor(org, repo) != &c.ConfigItems[i] {
+				continue
+			}
+			seen.Insert(org + "/" + repo)
+
+			prs, err := listAllOpenPRs(ctx, org, repo, bot.cli)
+			if err != nil {
+				log.WithError(err).Warningf("Could not list the open prs of %s/%s.", org, repo)
+
+				continue
+			}
+
+			for j := range prs {
+				scanned++
+				reconcileScanned.Inc()
+
+				reason := bot.needRepair(ctx, org, repo, &prs[j], &c.ConfigItems[i], staleAfter, log)
+				if reason == "" {
+					continue
+				}
+
+				select {
+				case <-stop:
+					return
+				case <-pace.C:
+				}
+
+				l := log.WithFields(logrus.Fields{
+					"org":    org,
+					"repo":   repo,
+					"number": prs[j].Number,
+					"repair": reason,
+				})
+
+				ctx, cancel := bot.newEventContext()
+				_, _, err := bot.recheckPR(
+					withAuditTrigger(ctx, auditTriggerReconcile), org, repo, toPullRequestHook(&prs[j]), c, l,
+				)
+				cancel()
+
+				if err != nil {
+					l.WithError(err).Warning("Could not repair the pr.")
+
+					continue
+				}
+
+				repaired++
+				reconcileRepaired.WithLabelValues(reason).Inc()
+				l.Info("Repaired the CLA state of pr.")
+			}
+		}
+	}
+
+	log.WithFields(logrus.Fields{
+		"scanned":  scanned,
+		"repaired": repaired,
+	}).Info("Finished reconciling the open prs.")
+}
+
+// needRepair returns why the PR needs re-checking, or empty if it does not.
+func (bot *robot) needRepair(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequest,
+	c *botConfig,
+	staleAfter time.Duration,
+	log *logrus.Entry,
+) string {
+	branch := ""
+	if pr.Base != nil {
+		branch = pr.Base.Ref
+	}
+
+	cfg := c.configForBranch(branch)
+	if !cfg.TargetBranches.match(branch) || (cfg.SkipDraft && pr.Draft) {
+		return ""
+	}
+
+	labels := sets.NewString()
+	for i := range pr.Labels {
+		labels.Insert(pr.Labels[i].Name)
+	}
+
+	if l := cfg.ManualOverrideLabel; l != "" && labels.Has(l) {
+		return ""
+	}
+
+	reason := ""
+
+	key := prKey(org, repo, pr.Number)
+	if v, ok := bot.lastCheckedAt.get(key); !ok {
+		// The PR checked before the time was tracked gets a baseline, so
+		// that the PRs are not re-checked all at once.
+		bot.lastCheckedAt.set(key, time.Now())
+	} else if staleAfter > 0 && time.Since(v.(time.Time)) > staleAfter {
+		reason = repairStale
+	}
+
+	if cfg.labelEnabled() && !labels.HasAny(cfg.CLALabelYes, cfg.CLALabelNo) {
+		reason = repairMissingLabel
+	}
+
+	if reason == "" {
+		return ""
+	}
+
+	if exempted, err := bot.isExempted(ctx, org, repo, pr.Number); err != nil {
+		log.WithError(err).Warning("Could not check whether the pr is exempted.")
+
+		return ""
+	} else if exempted {
+		return ""
+	}
+
+	return reason
+}
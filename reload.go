@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+// watchHangup flushes the caches of robot on SIGHUP until stop is closed, so
+// that the operators can apply the fixed data without restarting.
+func (bot *robot) watchHangup(ready *readiness, stop <-chan struct{}) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(sig)
+
+		bot.handleHangups(sig, ready, stop)
+	}()
+}
+
+// handleHangups flushes the caches once a signal is received from sig.
+func (bot *robot) handleHangups(sig <-chan os.Signal, ready *readiness, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-sig:
+			bot.flushCaches(ready)
+		}
+	}
+}
+
+// flushCaches drops the cached results and reloads the files which can be
+// edited out of the robot. The caches are flushed under their own locks, so
+// the checks running concurrently just miss the cache.
+func (bot *robot) flushCaches(ready *readiness) {
+	fields := logrus.Fields{
+		"email_logins":  bot.emailLogins.flush(),
+		"signed_emails": bot.signedEmails.flush(),
+		"first_timers":  bot.firstTimers.flush(),
+		"last_checks":   bot.lastChecks.flush(),
+	}
+
+	bot.cacheStore.clear(bucketSignedEmails)
+
+	if ready != nil {
+		fields["checker_probes"] = ready.probes.flush()
+	}
+
+	log := logrus.WithField("signal", "SIGHUP")
+
+	if bot.exemptions != nil {
+		if n, err := bot.exemptions.reload(); err != nil {
+			log.WithError(err).Error("Error reloading the exemption store.")
+		} else {
+			fields["exemption_repos"] = n
+		}
+	}
+
+	if bot.notifier != nil {
+		if n, err := bot.notifier.reloadOptOuts(); err != nil {
+			log.WithError(err).Error("Error reloading the emails opted out.")
+		} else {
+			fields["email_opt_outs"] = n
+		}
+	}
+
+	log.WithFields(fields).Info("Flushed the caches.")
+}
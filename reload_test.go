@@ -0,0 +1,112 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+func TestHandleHangups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "exemptions.json")
+
+	bot := newRobot(&fakeClient{}, "bot", nil, nil, 0, 0)
+	if bot.exemptions, err = newExemptionStore(path); err != nil {
+		t.Fatal(err)
+	}
+	ready := newReadiness(bot, true)
+
+	caches := []struct {
+		name  string
+		cache *ttlCache
+	}{
+		{name: "email logins", cache: bot.emailLogins},
+		{name: "signed emails", cache: bot.signedEmails},
+		{name: "first timers", cache: bot.firstTimers},
+		{name: "last checks", cache: bot.lastChecks},
+		{name: "checker probes", cache: ready.probes},
+	}
+
+	for _, c := range caches {
+		c.cache.set("key", "value")
+	}
+
+	// The store is edited by hand after the robot started.
+	exemptions := `{"org/repo": {"bob@example.com": {"email": "bob@example.com"}}}`
+	if err := ioutil.WriteFile(path, []byte(exemptions), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sig := make(chan os.Signal)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		bot.handleHangups(sig, ready, stop)
+		close(done)
+	}()
+
+	// The channel is unbuffered, so the second signal is received only
+	// after the caches have been flushed for the first one.
+	sig <- syscall.SIGHUP
+	sig <- syscall.SIGHUP
+
+	close(stop)
+	<-done
+
+	for _, c := range caches {
+		if _, ok := c.cache.get("key"); ok {
+			t.Fatalf("expect the cache of %s to be flushed", c.name)
+		}
+	}
+
+	if !bot.exemptions.has("org", "repo", "bob@example.com") {
+		t.Fatal("expect the exemption store to be reloaded")
+	}
+}
+
+func TestFlushCachesWithConcurrentChecks(t *testing.T) {
+	bot := newRobot(&fakeClient{}, "bot", nil, nil, 0, 0)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					bot.signedEmails.set("key", signingInfo{Signed: true})
+					bot.signedEmails.get("key")
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		bot.flushCaches(nil)
+	}
+
+	close(stop)
+	wg.Wait()
+
+	// The cache keeps working after being flushed.
+	bot.signedEmails.set("key", signingInfo{Signed: true})
+	if v, ok := bot.signedEmails.get("key"); !ok || !v.(signingInfo).Signed {
+		t.Fatalf("expect the cached result, got %v", v)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+const (
+	maxAttempts  = 3
+	retryBackoff = time.Second
+)
+
+// retryClient retries the mutations of PR on the transient failures, such as
+// 429, 5xx and network errors. The other failures are returned at once.
+type retryClient struct {
+	iClient
+
+	backoff time.Duration
+}
+
+func newRetryClient(cli iClient) *retryClient {
+	return &retryClient{iClient: cli, backoff: retryBackoff}
+}
+
+func (c *retryClient) AddPRLabel(ctx context.Context, org, repo string, number int32, label string) error {
+	return c.retry(ctx, func() error {
+		return c.iClient.AddPRLabel(ctx, org, repo, number, label)
+	})
+}
+
+func (c *retryClient) RemovePRLabel(ctx context.Context, org, repo string, number int32, label string) error {
+	return c.retry(ctx, func() error {
+		return c.iClient.RemovePRLabel(ctx, org, repo, number, label)
+	})
+}
+
+func (c *retryClient) CreatePRComment(ctx context.Context, org, repo string, number int32, comment string) error {
+	return c.retry(ctx, func() error {
+		return c.iClient.CreatePRComment(ctx, org, repo, number, comment)
+	})
+}
+
+func (c *retryClient) CreatePRCommentWithID(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	comment string,
+) (v int32, err error) {
+	err = c.retry(ctx, func() (err error) {
+		v, err = c.iClient.CreatePRCommentWithID(ctx, org, repo, number, comment)
+		return
+	})
+
+	return
+}
+
+func (c *retryClient) UpdatePRComment(ctx context.Context, org, repo string, commentID int32, comment string) error {
+	return c.retry(ctx, func() error {
+		return c.iClient.UpdatePRComment(ctx, org, repo, commentID, comment)
+	})
+}
+
+func (c *retryClient) DeletePRComment(ctx context.Context, org, repo string, ID int32) error {
+	return c.retry(ctx, func() error {
+		return c.iClient.DeletePRComment(ctx, org, repo, ID)
+	})
+}
+
+func (c *retryClient) ClosePR(ctx context.Context, org, repo string, number int32) error {
+	return c.retry(ctx, func() error {
+		return c.iClient.ClosePR(ctx, org, repo, number)
+	})
+}
+
+// retry runs f at most maxAttempts times with the exponential backoff. It
+// stops waiting once ctx is done.
+func (c *retryClient) retry(ctx context.Context, f func() error) error {
+	backoff := c.backoff
+
+	var err error
+	for i := 0; i < maxAttempts; i++ {
+		if i > 0 {
+			t := time.NewTimer(backoff)
+
+			select {
+			case <-ctx.Done():
+				t.Stop()
+
+				return fmt.Errorf("%w, the last failure: %v", ctx.Err(), err)
+			case <-t.C:
+			}
+
+			backoff *= 2
+		}
+
+		if err = f(); err == nil || !isRetryableError(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// isRetryableError reports whether err is transient. The failure caused by
+// the context of caller is not, since it will fail again.
+func isRetryableError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return true
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+
+	code := statusCode(err)
+
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
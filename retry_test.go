@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func giteeStatusError(code int) error {
+	return &giteeError{code: code, status: fmt.Sprintf("%d %s", code, http.StatusText(code))}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "rate limited", err: giteeStatusError(http.StatusTooManyRequests), want: true},
+		{name: "server error", err: giteeStatusError(http.StatusInternalServerError), want: true},
+		{name: "bad gateway", err: giteeStatusError(http.StatusBadGateway), want: true},
+		{name: "wrapped server error", err: fmt.Errorf("add label: %w", giteeStatusError(http.StatusServiceUnavailable)), want: true},
+		{name: "not found", err: giteeStatusError(http.StatusNotFound), want: false},
+		{name: "bad request", err: giteeStatusError(http.StatusBadRequest), want: false},
+		{name: "status in text only", err: errors.New("the body mentions 503 and timeout"), want: false},
+		{name: "eof", err: &url.Error{Op: "Get", URL: "u", Err: io.EOF}, want: true},
+		{name: "unexpected eof", err: fmt.Errorf("read: %w", io.ErrUnexpectedEOF), want: true},
+		{name: "connection reset", err: fmt.Errorf("read: %w", syscall.ECONNRESET), want: true},
+		{name: "network error", err: &net.OpError{Op: "dial", Err: errors.New("refused")}, want: true},
+		{name: "cancelled", err: &url.Error{Op: "Get", URL: "u", Err: context.Canceled}, want: false},
+		{name: "deadline exceeded", err: &url.Error{Op: "Get", URL: "u", Err: context.DeadlineExceeded}, want: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isRetryableError(tc.err); got != tc.want {
+				t.Fatalf("expect %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+// flakyClient fails AddPRLabel with errs in turn, then succeeds.
+type flakyClient struct {
+	iClient
+
+	errs  []error
+	calls int
+}
+
+func (c *flakyClient) AddPRLabel(ctx context.Context, org, repo string, number int32, label string) error {
+	c.calls++
+	if c.calls <= len(c.errs) {
+		return c.errs[c.calls-1]
+	}
+
+	return nil
+}
+
+func TestRetryClientBackoff(t *testing.T) {
+	transient := giteeStatusError(http.StatusBadGateway)
+	permanent := giteeStatusError(http.StatusForbidden)
+
+	cases := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+		wantSlept time.Duration
+	}{
+		{name: "succeed at once", wantCalls: 1},
+		{name: "recover from transient failures", errs: []error{transient, transient}, wantCalls: 3, wantSlept: 3 * time.Millisecond},
+		{name: "give up after max attempts", errs: []error{transient, transient, transient}, wantCalls: maxAttempts, wantErr: transient, wantSlept: 3 * time.Millisecond},
+		{name: "not retry permanent failure", errs: []error{permanent}, wantCalls: 1, wantErr: permanent},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &flakyClient{errs: tc.errs}
+			c := &retryClient{iClient: fake, backoff: time.Millisecond}
+
+			start := time.Now()
+			err := c.AddPRLabel(context.Background(), "org", "repo", 1, "cla/yes")
+			slept := time.Since(start)
+
+			if err != tc.wantErr {
+				t.Fatalf("expect error %v, got %v", tc.wantErr, err)
+			}
+
+			if fake.calls != tc.wantCalls {
+				t.Fatalf("expect %d calls, got %d", tc.wantCalls, fake.calls)
+			}
+
+			// The backoff doubles, so the waits are 1ms and 2ms.
+			if slept < tc.wantSlept {
+				t.Fatalf("expect backoff of at least %s, got %s", tc.wantSlept, slept)
+			}
+		})
+	}
+}
+
+func TestRetryClientStopsOnCancel(t *testing.T) {
+	fake := &flakyClient{errs: []error{giteeStatusError(http.StatusBadGateway)}}
+	c := &retryClient{iClient: fake, backoff: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- c.AddPRLabel(ctx, "org", "repo", 1, "cla/yes") }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expect context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the backoff is not stopped by the cancelled context")
+	}
+
+	if fake.calls != 1 {
+		t.Fatalf("expect 1 call, got %d", fake.calls)
+	}
+}
@@ -1,222 +1,2465 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/opensourceways/community-robot-lib/config"
 	"github.com/opensourceways/community-robot-lib/robot-gitee-framework"
 	"github.com/opensourceways/community-robot-lib/utils"
 	sdk "github.com/opensourceways/go-gitee/gitee"
 	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 const (
 	botName        = "cla"
 	maxLengthOfSHA = 8
+
+	legacyPRIgnore  = "ignore"
+	legacyPRComment = "comment"
+
+	roleAuthor    = "author"
+	roleCommitter = "committer"
+
+	firstTimerCacheTTL = 24 * time.Hour
+
+	// emailLoginCacheTTL is how long the login resolved from the email of commit is cached.
+	emailLoginCacheTTL = 24 * time.Hour
+
+	// signedEmailCacheTTL is how long the email found signed is trusted without
+	// asking the CLA service again. The unsigned ones are never cached, so that
+	// signing takes effect at once.
+	signedEmailCacheTTL = time.Hour
+
+	checkRoleAuthor       = "author"
+	checkRoleCollaborator = "collaborator"
+	checkRoleAnyone       = "anyone"
+
+	// permissionAlertTTL limits the alerts of permission failures to one per repo per day.
+	permissionAlertTTL = 24 * time.Hour
+
+	// commentOverheadBytes is the room reserved for the marker, fingerprint and
+	// footer when fitting the comment in the budget.
+	commentOverheadBytes = 1024
+
+	// checkRecordTTL must not be less than the max of check_cooldown_seconds.
+	checkRecordTTL = time.Hour
+
+	behaviorBoth        = "both"
+	behaviorLabelOnly   = "label_only"
+	behaviorCommentOnly = "comment_only"
+
+	cleanupKeep     = "keep"
+	cleanupDelete   = "delete"
+	cleanupMinimize = "minimize"
+
+	// prActionReopened is the action of reopening PR which is not
+	// recognized by sdk.GetPullRequestAction.
+	prActionReopened = "reopened"
 )
 
-var checkCLARe = regexp.MustCompile(`(?mi)^/check-cla\s*$`)
+// emptyCommitsRetryDelay is the delay before fetching the commits of PR again
+// when they are empty. It is shortened by the tests.
+var emptyCommitsRetryDelay = 3 * time.Second
+
+type iClient interface {
+	AddPRLabel(ctx context.Context, owner, repo string, number int32, label string) error
+	RemovePRLabel(ctx context.Context, org, repo string, number int32, label string) error
+	CreatePRComment(ctx context.Context, org, repo string, number int32, comment string) error
+	CreatePRCommentWithID(ctx context.Context, org, repo string, number int32, comment string) (int32, error)
+	UpdatePRComment(ctx context.Context, org, repo string, commentID int32, comment string) error
+	DeletePRComment(ctx context.Context, org, repo string, ID int32) error
+	GetPRCommits(ctx context.Context, org, repo string, number int32) ([]sdk.PullRequestCommits, error)
+	GetPullRequestChanges(ctx context.Context, org, repo string, number int32) ([]sdk.PullRequestFiles, error)
+	GetGiteePullRequest(ctx context.Context, org, repo string, number int32) (sdk.PullRequest, error)
+	GetPRLabels(ctx context.Context, org, repo string, number int32) ([]sdk.Label, error)
+	HasMergedPR(ctx context.Context, org, repo, author string) (bool, error)
+	FindUserByEmail(ctx context.Context, email string) (string, error)
+	CreateCommitStatus(ctx context.Context, org, repo, sha string, status commitStatus) error
+	CreateRepoLabel(ctx context.Context, org, repo, label, color string) error
+	GetUserPermissionsOfRepo(ctx context.Context, org, repo, login string) (sdk.ProjectMemberPermission, error)
+	IsCollaborator(ctx context.Context, owner, repo, login string) (bool, error)
+	ListPRCommentsByPage(ctx context.Context, org, repo string, number int32, page, perPage int) ([]sdk.PullRequestComments, error)
+	ListPROperationLogs(ctx context.Context, org, repo string, number int32) ([]sdk.OperateLog, error)
+	ListOpenPRsByPage(ctx context.Context, org, repo string, page, perPage int) ([]sdk.PullRequest, error)
+	GetRepos(ctx context.Context, org string) ([]sdk.Project, error)
+	GetRepoLabels(ctx context.Context, owner, repo string) ([]sdk.Label, error)
+	ClosePR(ctx context.Context, org, repo string, number int32) error
+}
+
+func newRobot(
+	cli iClient,
+	botLogin string,
+	exemptions *exemptionStore,
+	notifier *emailNotifier,
+	eventTimeout time.Duration,
+	dedupeWindow time.Duration,
+) *robot {
+	return &robot{
+		cli:          cli,
+		botLogin:     botLogin,
+		exemptions:   exemptions,
+		notifier:     notifier,
+		eventTimeout: eventTimeout,
+		firstTimers:  newTTLCache(firstTimerCacheTTL),
+		emailLogins:  newTTLCache(emailLoginCacheTTL),
+		signedEmails: newTTLCache(signedEmailCacheTTL),
+		lastKnown:    newTTLCache(lastKnownResultTTL),
+		degraded:     newDegradedTracker(),
+		prLocks:      newKeyedMutex(),
+		debouncer:    newDebouncer(),
+		lastChecks:   newTTLCache(checkRecordTTL),
+
+		permissionAlerts: newTTLCache(permissionAlertTTL),
+		errorAlerts:      newTTLCache(permissionAlertTTL),
+		transitions:      newTransitionTracker(),
+		unsignedSeen:     newUnsignedEmailTracker(),
+		lastCheckedAt:    newTTLCache(claStateTTL),
+		unsignedPRs:      newEmailPRIndex(),
+		prStatuses:       newTTLCache(prStatusFreshness),
+		deduper:          newEventDeduper(dedupeWindow),
+	}
+}
+
+type robot struct {
+	cli iClient
+
+	// botLogin is the login of robot itself.
+	botLogin string
+
+	// exemptions is the store of emails exempted by the maintainers.
+	// It is nil if the store is not configured.
+	exemptions *exemptionStore
+
+	// notifier emails the unsigned commit authors. It is nil if SMTP is not configured.
+	notifier *emailNotifier
+
+	// eventTimeout is the deadline of handling an event. It is unlimited if not positive.
+	eventTimeout time.Duration
+
+	// firstTimers caches whether the author is a first-time contributor.
+	// The key is org/author.
+	firstTimers *ttlCache
+
+	// emailLogins caches the logins resolved from the emails of commits. The
+	// key is the email and the value is the login, which is empty if unknown.
+	emailLogins *ttlCache
+
+	// signedEmails caches the emails found signed. The key is the check url
+	// and the email, and the value is signingInfo.
+	signedEmails *ttlCache
+
+	// lastKnown records the last results of checking the emails for the
+	// degraded mode. The key is the same as signedEmails, and the value is
+	// lastKnownResult.
+	lastKnown *ttlCache
+
+	// degraded tracks the CLA services in the degraded mode.
+	degraded *degradedTracker
+
+	// cacheStore persists the caches across the restarts. It is nil if disabled.
+	cacheStore *cacheStore
+
+	// audit records the mutations of PRs. It is nil if disabled, such as in once mode.
+	audit *auditLog
+
+	// createdLabels records the outcome of creating the missing labels.
+	createdLabels sync.Map
+
+	// prLocks serializes the handling of events for the same PR,
+	// so that they will not race on the labels and comments.
+	prLocks *keyedMutex
+
+	// debouncer coalesces the rapid updates of source branch of same PR.
+	debouncer *debouncer
+
+	// handlers tracks the in-flight handlers to drain them on shutdown.
+	handlers handlerTracker
+
+	// lastChecks records the last check triggered by "/check-cla" of each PR.
+	// The key is org/repo/number and the value is *checkRecord.
+	lastChecks *ttlCache
+
+	// permissionAlerts records the repos which have been alerted of the
+	// permission failures. The key is org/repo.
+	permissionAlerts *ttlCache
+
+	// permissionFailing records the repos whose mutations fail for the lack
+	// of permissions until one succeeds. The key is org/repo.
+	permissionFailing sync.Map
+
+	// errorAlerts records the permanent errors which have been reported.
+	// The key is org/repo/kind.
+	errorAlerts *ttlCache
+
+	// latestConfig is the latest *configuration delivered with the events.
+	latestConfig atomic.Value
+
+	// transitions tracks the transitions of the CLA state of PRs.
+	transitions *transitionTracker
+
+	// unsignedSeen tracks the distinct unsigned emails of each repo.
+	unsignedSeen *unsignedEmailTracker
+
+	// lastCheckedAt records when the CLA of PR was checked last time. The
+	// key is org/repo/number and the value is time.Time.
+	lastCheckedAt *ttlCache
+
+	// unsignedPRs indexes the PRs by the emails which have not signed.
+	unsignedPRs *emailPRIndex
+
+	// prStatuses records the status of PR found by the last check. The key
+	// is org/repo/number and the value is *prStatus.
+	prStatuses *ttlCache
+
+	// deduper skips the events redelivered by Gitee. It is nil if disabled.
+	deduper *eventDeduper
+
+	// limiter bounds the events handled at the same time. It is nil if unlimited.
+	limiter *eventLimiter
+}
+
+type checkRecord struct {
+	at time.Time
+
+	// replied means the user has been told that the check ran recently.
+	replied bool
+}
+
+func (bot *robot) NewConfig() config.Config {
+	return &configuration{}
+}
+
+func (bot *robot) getConfig(cfg config.Config, org, repo, branch string) (*botConfig, error) {
+	c, ok := cfg.(*configuration)
+	if !ok {
+		return nil, fmt.Errorf("%w: can't convert to configuration", ErrBadConfig)
+	}
+
+	if bc := c.configFor(org, repo); bc != nil {
+		return bc.configForBranch(branch), nil
+	}
+
+	configMisses.Inc()
+
+	return nil, fmt.Errorf("%w: no config for this repo:%s/%s", ErrBadConfig, org, repo)
+}
+
+// plainMessages checks whether the repo wants the plain messages according to
+// the latest config.
+func (bot *robot) plainMessages(org, repo string) bool {
+	c, ok := bot.latestConfig.Load().(*configuration)
+	if !ok {
+		return false
+	}
+
+	cfg := c.configFor(org, repo)
+
+	return cfg != nil && cfg.PlainMessages
+}
+
+func (bot *robot) RegisterEventHandler(f framework.HandlerRegitster) {
+	f.RegisterPullRequestHandler(func(e *sdk.PullRequestEvent, c config.Config, log *logrus.Entry) error {
+		if !bot.handlers.accept() {
+			return errShuttingDown
+		}
+		defer bot.handlers.done()
+
+		bot.recordConfig(c)
+
+		key := prEventKey(e)
+		if bot.deduper.isDuplicate(key) {
+			duplicateEvents.WithLabelValues("pull_request").Inc()
+			log.Info("Skip the duplicate event.")
+
+			return nil
+		}
+
+		release, err := bot.limiter.acquire("pull_request", false)
+		if err != nil {
+			log.WithError(err).Warning("Rejected the event.")
+			bot.deduper.forget(key)
+
+			return err
+		}
+		defer release()
+
+		org, repo := e.GetOrgRepo()
+
+		err = bot.settleError(org, repo, bot.handlePREvent(e, c, log), log)
+		if err != nil {
+			bot.deduper.forget(key)
+		}
+
+		return err
+	})
+
+	f.RegisterNoteEventHandler(func(e *sdk.NoteEvent, c config.Config, log *logrus.Entry) error {
+		if !bot.handlers.accept() {
+			return errShuttingDown
+		}
+		defer bot.handlers.done()
+
+		bot.recordConfig(c)
+
+		key := noteEventKey(e)
+		if bot.deduper.isDuplicate(key) {
+			duplicateEvents.WithLabelValues("note").Inc()
+			log.Info("Skip the duplicate event.")
+
+			return nil
+		}
+
+		// Only the commands are limited, since the other comments are
+		// skipped at once.
+		if commandRe.MatchString(e.GetComment().GetBody()) {
+			release, err := bot.limiter.acquire("note", true)
+			if err != nil {
+				log.WithError(err).Warning("Rejected the event.")
+				bot.deduper.forget(key)
+
+				return err
+			}
+			defer release()
+		}
+
+		org, repo := e.GetOrgRepo()
+
+		err := bot.settleError(org, repo, bot.handleNoteEvent(e, c, log), log)
+		if err != nil {
+			bot.deduper.forget(key)
+		}
+
+		return err
+	})
+}
+
+// stop stops accepting the new events, flushes the pending debounced checks and
+// waits for the in-flight handlers at most timeout. It should be called when the process stops.
+func (bot *robot) stop(timeout time.Duration) {
+	inflight := bot.handlers.close()
+
+	// The flushing is tracked as a handler, so that waiting will not end
+	// before the pending checks start.
+	bot.handlers.track()
+	go func() {
+		defer bot.handlers.done()
+
+		bot.debouncer.stop()
+	}()
+
+	abandoned := bot.handlers.wait(timeout)
+
+	logrus.WithFields(logrus.Fields{
+		"inflight":  inflight,
+		"abandoned": abandoned,
+	}).Info("Finished draining the in-flight handlers.")
+}
+
+// newEventContext returns the context bounding the handling of an event.
+func (bot *robot) newEventContext() (context.Context, context.CancelFunc) {
+	return bot.withEventTimeout(bot.handlers.context())
+}
+
+// withEventTimeout bounds ctx by the deadline of handling an event, so that
+// the work started by the sweeps still stops when ctx is canceled.
+func (bot *robot) withEventTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if bot.eventTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, bot.eventTimeout)
+}
+
+func (bot *robot) handlePREvent(e *sdk.PullRequestEvent, c config.Config, log *logrus.Entry) error {
+	if sdk.GetPullRequestAction(e) != sdk.PRActionChangedSourceBranch {
+		ctx, cancel := bot.newEventContext()
+		defer cancel()
+
+		return bot.handlePRAction(ctx, e, c, log)
+	}
+
+	org, repo := e.GetOrgRepo()
+	pr := e.GetPullRequest()
+
+	cfg, err := bot.getConfig(c, org, repo, pr.GetBase().GetRef())
+	if err != nil {
+		return err
+	}
+
+	delay := cfg.debounceDelay()
+	if delay <= 0 {
+		ctx, cancel := bot.newEventContext()
+		defer cancel()
+
+		return bot.handlePRAction(ctx, e, c, log)
+	}
+
+	// Only the latest event within the delay is checked, which carries the latest head.
+	// The deadline starts when the check runs rather than when the event arrives.
+	bot.debouncer.submit(prKey(org, repo, pr.GetNumber()), delay, func() {
+		bot.handlers.track()
+		defer bot.handlers.done()
+
+		ctx, cancel := bot.newEventContext()
+		defer cancel()
+
+		if err := bot.handlePRAction(ctx, e, c, log); err != nil {
+			log.WithError(err).Error("Failed to handle the debounced event.")
+		}
+	})
+
+	return nil
+}
+
+func (bot *robot) handlePRAction(
+	ctx context.Context,
+	e *sdk.PullRequestEvent,
+	c config.Config,
+	log *logrus.Entry,
+) error {
+	org, repo := e.GetOrgRepo()
+	defer bot.prLocks.lock(prKey(org, repo, e.GetPullRequest().GetNumber()))()
+
+	if !bot.isTargetBranchChecked(c, org, repo, e.GetPullRequest()) {
+		return nil
+	}
+
+	action := getPRAction(e)
+	if action == sdk.PRActionClosed {
+		return bot.handlePRClosed(ctx, e, c, log)
+	}
+
+	if e.GetPullRequest().GetState() != "open" {
+		return nil
+	}
+
+	// The reopened PR is checked like a new one, so that the labels and
+	// comments changed during closure will be restored. The PR retargeted is
+	// checked against the config of its new target branch.
+	switch action {
+	case sdk.PRActionOpened, prActionReopened, sdk.PRActionChangedSourceBranch,
+		sdk.PRActionChangedTargetBranch:
+	case sdk.PRActionUpdatedLabel:
+		return bot.handlePRLabelUpdated(ctx, e, c, log)
+	default:
+		if strings.ToLower(e.GetAction()) == "update" {
+			return bot.handleDraftReady(ctx, e, c, log)
+		}
+
+		return nil
+	}
+
+	pr := e.GetPullRequest()
+
+	cfg, err := bot.getConfig(c, org, repo, pr.GetBase().GetRef())
+	if err != nil {
+		return err
+	}
+
+	if action == prActionReopened {
+		bot.resetCloseClock(ctx, org, repo, pr.GetNumber(), cfg, log)
+	}
+
+	if cfg.isLegacyPR(pr.CreatedAt) {
+		return bot.handleLegacyPR(ctx, org, repo, pr, cfg, log)
+	}
+
+	if cfg.SkipDraft {
+		draft, err := bot.isDraftPR(ctx, org, repo, pr.GetNumber())
+		if err != nil {
+			log.WithError(err).Warning("Could not check whether the pr is a draft.")
+		} else if draft {
+			return bot.handleDraftPR(ctx, org, repo, pr.GetNumber(), cfg, log)
+		}
+	}
+
+	return bot.handle(ctx, org, repo, pr, cfg, nil, log)
+}
+
+// handleDraftPR skips checking the draft PR and posts the note once if it is set.
+func (bot *robot) handleDraftPR(
+	ctx context.Context,
+	org, repo string,
+	prNumber int32,
+	cfg *botConfig,
+	log *logrus.Entry,
+) error {
+	log.Info("The pr is a draft, skip checking CLA.")
+
+	if cfg.DraftNote == "" || !cfg.commentEnabled() {
+		return nil
+	}
+
+	comments, err := listAllPRComments(ctx, org, repo, prNumber, bot.cli)
+	if err != nil {
+		return err
+	}
+
+	if len(findBotComments(comments, markerDraft)) > 0 {
+		return nil
+	}
+
+	return bot.cli.CreatePRComment(ctx, org, repo, prNumber, withMarker(markerDraft, cfg.DraftNote))
+}
+
+// handleDraftReady checks the PR which was skipped as a draft once it is ready for review.
+// Gitee has no dedicated action for it, so the PR which is not a draft and
+// has not been checked is regarded as the one ready for review.
+func (bot *robot) handleDraftReady(
+	ctx context.Context,
+	e *sdk.PullRequestEvent,
+	c config.Config,
+	log *logrus.Entry,
+) error {
+	org, repo := e.GetOrgRepo()
+	pr := e.GetPullRequest()
+
+	cfg, err := bot.getConfig(c, org, repo, pr.GetBase().GetRef())
+	if err != nil {
+		return err
+	}
+
+	if !cfg.SkipDraft || cfg.isLegacyPR(pr.CreatedAt) {
+		return nil
+	}
+
+	prNumber := pr.GetNumber()
+
+	draft, err := bot.isDraftPR(ctx, org, repo, prNumber)
+	if err != nil || draft {
+		return err
+	}
+
+	checked, err := bot.isChecked(ctx, org, repo, prNumber, cfg)
+	if err != nil || checked {
+		return err
+	}
+
+	log.Info("The draft pr is ready for review, check CLA.")
+
+	return bot.handle(ctx, org, repo, pr, cfg, nil, log)
+}
+
+func (bot *robot) isDraftPR(ctx context.Context, org, repo string, prNumber int32) (bool, error) {
+	v, err := bot.cli.GetGiteePullRequest(ctx, org, repo, prNumber)
+	if err != nil {
+		return false, err
+	}
+
+	return v.Draft, nil
+}
+
+// isChecked checks whether the CLA of PR has been checked by the labels or comments left.
+func (bot *robot) isChecked(ctx context.Context, org, repo string, prNumber int32, cfg *botConfig) (bool, error) {
+	if cfg.labelEnabled() {
+		labels, err := bot.getPRLabels(ctx, org, repo, prNumber)
+		if err != nil {
+			return false, err
+		}
+
+		return labels.HasAny(cfg.CLALabelYes, cfg.CLALabelNo, cfg.CLALabelError), nil
+	}
+
+	comments, err := listAllPRComments(ctx, org, repo, prNumber, bot.cli)
+	if err != nil {
+		return false, err
+	}
+
+	for _, kind := range []string{markerSignGuide, markerAlreadySigned, markerCheckFailed} {
+		if len(findBotComments(comments, kind)) > 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// isTargetBranchChecked checks whether the PR targets the branch which requires the CLA.
+func (bot *robot) isTargetBranchChecked(c config.Config, org, repo string, pr *sdk.PullRequestHook) bool {
+	branch := pr.GetBase().GetRef()
+
+	cfg, err := bot.getConfig(c, org, repo, branch)
+	if err != nil {
+		// Let the handlers report the error.
+		return true
+	}
+
+	return cfg.TargetBranches.match(branch)
+}
+
+func getPRAction(e *sdk.PullRequestEvent) string {
+	if strings.ToLower(e.GetAction()) == "reopen" {
+		return prActionReopened
+	}
+
+	return sdk.GetPullRequestAction(e)
+}
+
+// handlePRLabelUpdated restores the CLA labels when someone else than the robot
+// removed them manually. The events caused by the robot itself are ignored to avoid loops.
+func (bot *robot) handlePRLabelUpdated(
+	ctx context.Context,
+	e *sdk.PullRequestEvent,
+	c config.Config,
+	log *logrus.Entry,
+) error {
+	actor := prEventActor(e)
+	if actor == "" || actor == bot.botLogin {
+		return nil
+	}
+
+	org, repo := e.GetOrgRepo()
+	pr := e.GetPullRequest()
+
+	cfg, err := bot.getConfig(c, org, repo, pr.GetBase().GetRef())
+	if err != nil {
+		return err
+	}
+
+	if cfg.isLegacyPR(pr.CreatedAt) {
+		return nil
+	}
+
+	if cfg.ManualOverrideLabel != "" {
+		changed, err := bot.isManualOverrideChanged(ctx, org, repo, pr, cfg)
+		if err != nil {
+			return err
+		}
+
+		if changed {
+			return bot.handle(ctx, org, repo, pr, cfg, nil, log)
+		}
+	}
+
+	if !cfg.labelEnabled() {
+		return nil
+	}
+
+	labels := pr.LabelsToSet()
+	if labels.Has(cfg.CLALabelYes) {
+		return bot.handleCLAYesAddedManually(ctx, org, repo, pr, cfg, actor, log)
+	}
+
+	if labels.HasAny(cfg.CLALabelNo, cfg.CLALabelError) {
+		return nil
+	}
+
+	log.Infof("The cla label was removed by %s, restore it.", actor)
+
+	if err := bot.handle(ctx, org, repo, pr, cfg, nil, log); err != nil {
+		return err
+	}
+
+	if !cfg.commentEnabled() {
+		return nil
+	}
+
+	return updateBotComment(
+		ctx, org, repo, pr.GetNumber(), markerLabelManaged, labelsManagedNotice(cfg.CommentLanguage, actor), bot.cli,
+	)
+}
+
+// isManualOverrideChanged checks whether the manual override label was applied
+// or removed since the last check, by comparing it with the override notice.
+func (bot *robot) isManualOverrideChanged(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	cfg *botConfig,
+) (bool, error) {
+	overridden := pr.LabelsToSet().Has(cfg.ManualOverrideLabel)
+	if !cfg.commentEnabled() {
+		return overridden, nil
+	}
+
+	v, err := listAllPRComments(ctx, org, repo, pr.GetNumber(), bot.cli)
+	if err != nil {
+		return false, err
+	}
+
+	return overridden != (len(findBotComments(v, markerOverridden)) > 0), nil
+}
+
+// handleCLAYesAddedManually re-runs the real check when the cla/yes label may be
+// added by someone else than the robot. The label will be reverted if the check fails,
+// unless the actor has the permission to override it.
+func (bot *robot) handleCLAYesAddedManually(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	cfg *botConfig,
+	actor string,
+	log *logrus.Entry,
+) error {
+	if len(cfg.LabelOverridePermissions) > 0 {
+		v, err := bot.cli.GetUserPermissionsOfRepo(ctx, org, repo, actor)
+		if err != nil {
+			log.WithError(err).Warningf("Could not get the permission of %s.", actor)
+		} else if cfg.canOverrideLabel(v.Permission) {
+			return nil
+		}
+	}
+
+	if err := bot.handle(ctx, org, repo, pr, cfg, nil, log); err != nil {
+		return err
+	}
+
+	prNumber := pr.GetNumber()
+
+	labels, err := bot.getPRLabels(ctx, org, repo, prNumber)
+	if err != nil || labels.Has(cfg.CLALabelYes) {
+		return err
+	}
+
+	log.Infof("The %s label added by %s was reverted.", cfg.CLALabelYes, actor)
+
+	if !cfg.commentEnabled() {
+		return nil
+	}
+
+	return updateBotComment(
+		ctx, org, repo, prNumber, markerLabelManaged,
+		claYesRevertedNotice(cfg.CommentLanguage, actor, cfg.CLALabelYes), bot.cli,
+	)
+}
+
+// prEventActor returns the login of user who triggered the PR event.
+func prEventActor(e *sdk.PullRequestEvent) string {
+	if u := e.GetUpdatedBy(); u != nil && u.Login != "" {
+		return u.Login
+	}
+
+	return e.GetSender().GetLogin()
+}
+
+// handlePRClosed cleans up the comments of robot when the PR is merged or closed.
+// The labels are left as-is for audit purposes.
+func (bot *robot) handlePRClosed(
+	ctx context.Context,
+	e *sdk.PullRequestEvent,
+	c config.Config,
+	log *logrus.Entry,
+) error {
+	org, repo := e.GetOrgRepo()
+	pr := e.GetPullRequest()
+
+	cfg, err := bot.getConfig(c, org, repo, pr.GetBase().GetRef())
+	if err != nil {
+		return err
+	}
+
+	switch cfg.CleanupOnClose {
+	case cleanupDelete:
+		return deleteBotCommentsOfPR(ctx, org, repo, pr.GetNumber(), bot.cli)
+
+	case cleanupMinimize:
+		return minimizeBotCommentsOfPR(ctx, org, repo, pr.GetNumber(), cfg.CommentLanguage, bot.cli)
+	}
+
+	return nil
+}
+
+// handleLegacyPR deals with the PR created before the CLA is enforced.
+func (bot *robot) handleLegacyPR(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	cfg *botConfig,
+	log *logrus.Entry,
+) error {
+	log.Infof("The pr was created before %s, skip checking CLA.", cfg.EnforceAfter)
+
+	if cfg.LegacyPRBehavior != legacyPRComment {
+		return nil
+	}
+
+	prNumber := pr.GetNumber()
+
+	comments, err := listAllPRComments(ctx, org, repo, prNumber, bot.cli)
+	if err != nil {
+		return err
+	}
+
+	// The notices posted before the marker was introduced are in English.
+	title := legacyPRNoticeTitle(langEN)
+	for i := range comments {
+		if b := comments[i].Body; hasMarker(b, markerLegacyPR) || strings.HasPrefix(b, title) {
+			return nil
+		}
+	}
+
+	return bot.cli.CreatePRComment(
+		ctx, org, repo, prNumber,
+		withMarker(markerLegacyPR, legacyPRNotice(cfg.CommentLanguage, cfg.EnforceAfter)),
+	)
+}
+
+func (bot *robot) handleNoteEvent(e *sdk.NoteEvent, c config.Config, log *logrus.Entry) error {
+	if !e.IsCreatingCommentEvent() || !e.IsPullRequest() {
+		return nil
+	}
+
+	body := e.GetComment().GetBody()
+	if !commandRe.MatchString(body) {
+		return nil
+	}
+
+	// The comments of bots are ignored to avoid the loops of comments,
+	// such as another robot quoting the instructions of this one.
+	commenter := e.GetCommenter()
+	if commenter == bot.botLogin || isBotUser(e.GetComment().GetUser()) {
+		return nil
+	}
+
+	ctx, cancel := bot.newEventContext()
+	defer cancel()
+
+	org, repo := e.GetOrgRepo()
+
+	pr, err := bot.getNotePR(ctx, e, org, repo)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := bot.getConfig(c, org, repo, pr.GetBase().GetRef())
+	if err != nil {
+		return err
+	}
+
+	if cfg.isIgnoredCommenter(commenter) {
+		return nil
+	}
+
+	cmd, ok := parseCommand(body, cfg.commandAliases())
+	if !ok {
+		return nil
+	}
+
+	if !cfg.TargetBranches.match(pr.GetBase().GetRef()) {
+		return nil
+	}
+
+	key := prKey(org, repo, pr.GetNumber())
+	defer bot.prLocks.lock(key)()
+
+	switch cmd.name {
+	case cmdCheckCLA:
+		if len(cmd.args) > 0 && strings.ToLower(cmd.args[0]) == "all" {
+			return bot.handleCheckAllCommand(ctx, org, repo, pr.GetNumber(), c, cfg.CommentLanguage, commenter, log)
+		}
+
+		if len(cmd.args) > 0 && strings.Contains(cmd.args[0], "@") {
+			return bot.handleCheckEmailCommand(ctx, org, repo, pr, cfg, commenter, cmd.args[0], log)
+		}
+
+		return bot.handleCheckCLACommand(ctx, org, repo, pr, cfg, commenter, newTriggerNote(e.GetComment()), log)
+
+	case cmdCLAHelp:
+		return bot.cli.CreatePRComment(ctx, org, repo, pr.GetNumber(), withMarker(markerHelp, cfg.render(tmplHelp, templateData{}, claHelp(cfg))))
+
+	case cmdCLAStatus:
+		return bot.handleStatusCommand(ctx, org, repo, pr, cfg, log)
+
+	case cmdCLAExemptEmail:
+		return bot.handleExemptEmailCommand(ctx, org, repo, pr, cfg, commenter, cmd.args, log)
+
+	case cmdCLAExempt:
+		return bot.handleExemptCommand(ctx, org, repo, pr, cfg, commenter, cmd.args, log)
+	}
+
+	return nil
+}
+
+// handleStatusCommand replies the breakdown of CLA status by the emails of commits.
+// It is read-only, and the labels and comments of PR are not touched.
+func (bot *robot) handleStatusCommand(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	cfg *botConfig,
+	log *logrus.Entry,
+) error {
+	prNumber := pr.GetNumber()
+
+	reply := func(content string) error {
+		return bot.cli.CreatePRComment(ctx, org, repo, prNumber, withMarker(markerStatus, content))
+	}
+
+	agreements, err := bot.getApplicableAgreements(ctx, org, repo, prNumber, cfg)
+	if err != nil {
+		log.WithError(err).Warning("Could not get the applicable agreements.")
+
+		return reply(checkCLAErrorNotice(cfg.CommentLanguage, ""))
+	}
+
+	results, err := bot.getPRCommitsAbout(ctx, org, repo, prNumber, cfg, agreements)
+	if err != nil {
+		log.WithError(err).Warning("Could not check the commits.")
+
+		return reply(checkCLAErrorNotice(cfg.CommentLanguage, ""))
+	}
+
+	return reply(claStatusBreakdown(results, cfg, pr.GetBase().GetRef()))
+}
+
+// handleCheckEmailCommand checks whether the email has signed the CLA of repo
+// and replies the result. The labels and comments of PR are not touched.
+// Only the users who can trigger the check of PR can do it.
+func (bot *robot) handleCheckEmailCommand(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	cfg *botConfig,
+	commenter, email string,
+	log *logrus.Entry,
+) error {
+	prNumber := pr.GetNumber()
+
+	if b, err := bot.canTriggerCheck(ctx, org, repo, pr, commenter, cfg); err != nil || !b {
+		if err != nil {
+			return err
+		}
+
+		return bot.refuseCheck(ctx, org, repo, prNumber, commenter, cfg, log)
+	}
+
+	reply := func(content string) error {
+		return bot.cli.CreatePRComment(ctx, org, repo, prNumber, withMarker(markerEmailCheck, content))
+	}
+
+	if !utils.IsValidEmail(email) {
+		return reply(invalidEmailNotice(cfg.CommentLanguage, commenter, cfg.displayEmail(email)))
+	}
+
+	v := bot.checkEmail(ctx, org, repo, cfg, cfg.CheckURL, email)
+	switch {
+	case v.err != nil:
+		log.WithError(v.err).Warningf("Could not check the cla of %s.", email)
+
+		return reply(checkCLAErrorNotice(cfg.CommentLanguage, ""))
+
+	case v.exempt:
+		return reply(emailExemptedNotice(cfg.CommentLanguage, commenter, cfg.displayEmail(email)))
+	}
+
+	return reply(emailCheckResult(cfg.CommentLanguage, commenter, cfg.displayEmail(email), &v.signing, cfg.SignURL))
+}
+
+// handleExemptCommand exempts the PR from checking CLA or revokes the exemption.
+// Only the maintainers of repo can do it. The exemption is recorded by the audit
+// comment which will be honored by the subsequent checks until it is revoked.
+func (bot *robot) handleExemptCommand(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	cfg *botConfig,
+	commenter string,
+	args []string,
+	log *logrus.Entry,
+) error {
+	prNumber := pr.GetNumber()
+
+	v, err := bot.cli.GetUserPermissionsOfRepo(ctx, org, repo, commenter)
+	if err != nil {
+		return err
+	}
+
+	if !isMaintainerPermission(v.Permission) {
+		return bot.cli.CreatePRComment(
+			ctx, org, repo, prNumber, withMarker(markerExemptRefused, exemptRefusedNotice(cfg.CommentLanguage, commenter)),
+		)
+	}
+
+	if len(args) == 0 {
+		return bot.cli.CreatePRComment(
+			ctx, org, repo, prNumber, withMarker(markerExemptRefused, exemptUsageNotice(cfg.CommentLanguage, commenter)),
+		)
+	}
+
+	if len(args) == 1 && strings.ToLower(args[0]) == "cancel" {
+		log.Infof("The exemption of pr is revoked by %s.", commenter)
+
+		err := bot.cli.CreatePRComment(
+			ctx, org, repo, prNumber, withMarker(markerExemptRevoked, exemptRevokedNotice(cfg.CommentLanguage, commenter)),
+		)
+		if err != nil {
+			return err
+		}
+
+		return bot.handle(ctx, org, repo, pr, cfg, nil, log)
+	}
+
+	reason := strings.Join(args, " ")
+
+	log.Infof("The pr is exempted by %s, reason: %s.", commenter, reason)
+
+	err = bot.cli.CreatePRComment(
+		ctx, org, repo, prNumber, withMarker(markerExempted, exemptedNotice(cfg.CommentLanguage, commenter, reason)),
+	)
+	if err != nil {
+		return err
+	}
+
+	return bot.handle(ctx, org, repo, pr, cfg, nil, log)
+}
+
+// handleExemptEmailCommand manages the emails exempted from the CLA check in the repo.
+// Only the maintainers can do it, and every change is audited by a comment.
+func (bot *robot) handleExemptEmailCommand(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	cfg *botConfig,
+	commenter string,
+	args []string,
+	log *logrus.Entry,
+) error {
+	prNumber := pr.GetNumber()
+
+	reply := func(content string) error {
+		return bot.cli.CreatePRComment(ctx, org, repo, prNumber, withMarker(markerEmailExemption, content))
+	}
+
+	if bot.exemptions == nil {
+		return reply(exemptEmailDisabledNotice(cfg.CommentLanguage, commenter))
+	}
+
+	v, err := bot.cli.GetUserPermissionsOfRepo(ctx, org, repo, commenter)
+	if err != nil {
+		return err
+	}
+
+	if !isMaintainerPermission(v.Permission) {
+		return reply(exemptEmailRefusedNotice(cfg.CommentLanguage, commenter))
+	}
+
+	switch {
+	case len(args) == 1 && strings.ToLower(args[0]) == "list":
+		return reply(exemptEmailList(bot.exemptions.list(org, repo), cfg))
+
+	case len(args) == 2 && strings.ToLower(args[0]) == "remove":
+		email := args[1]
+
+		b, err := bot.exemptions.remove(org, repo, email)
+		if err != nil {
+			return err
+		}
+
+		if !b {
+			return reply(exemptEmailNotFoundNotice(cfg.CommentLanguage, commenter, cfg.displayEmail(email)))
+		}
+
+		log.Infof("The exemption of %s is removed by %s.", email, commenter)
+
+		if err := reply(exemptEmailRemovedNotice(cfg.CommentLanguage, commenter, cfg.displayEmail(email))); err != nil {
+			return err
+		}
+
+	case len(args) >= 2 && utils.IsValidEmail(args[0]):
+		email, reason := args[0], strings.Join(args[1:], " ")
+
+		err := bot.exemptions.add(org, repo, emailExemption{
+			Email:     email,
+			Reason:    reason,
+			CreatedBy: commenter,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+
+		log.Infof("%s is exempted by %s, reason: %s.", email, commenter, reason)
+
+		if err := reply(exemptEmailAddedNotice(cfg.CommentLanguage, commenter, cfg.displayEmail(email), reason)); err != nil {
+			return err
+		}
+
+	default:
+		return reply(exemptEmailUsageNotice(cfg.CommentLanguage, commenter))
+	}
+
+	// The PR is checked again to apply the change.
+	if pr.GetState() != "open" {
+		return nil
+	}
+
+	return bot.handle(ctx, org, repo, pr, cfg, nil, log)
+}
+
+// isExempted checks whether the PR is exempted by the maintainers, that is
+// the newest audit comment of exemption is not revoked.
+func (bot *robot) isExempted(ctx context.Context, org, repo string, prNumber int32) (bool, error) {
+	comments, err := listAllPRComments(ctx, org, repo, prNumber, bot.cli)
+	if err != nil {
+		return false, err
+	}
+
+	exempted := findBotComments(comments, markerExempted)
+	if len(exempted) == 0 {
+		return false, nil
+	}
+
+	revoked := findBotComments(comments, markerExemptRevoked)
+	if len(revoked) == 0 {
+		return true, nil
+	}
+
+	return exempted[len(exempted)-1].Id > revoked[len(revoked)-1].Id, nil
+}
+
+func isMaintainerPermission(permission string) bool {
+	return permission == "admin" || permission == "maintain"
+}
+
+// isBotUser checks whether the user is marked as a bot by Gitee.
+func isBotUser(u *sdk.UserHook) bool {
+	return u != nil && strings.EqualFold(u.Type_, "bot")
+}
+
+// handleCheckCLACommand runs a full check even if the PR was created before
+// the CLA is enforced, because it is an explicit opt-in.
+func (bot *robot) handleCheckCLACommand(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	cfg *botConfig,
+	commenter string,
+	trigger *triggerNote,
+	log *logrus.Entry,
+) error {
+	key := prKey(org, repo, pr.GetNumber())
+
+	// The CLA only applies to the open PRs, and nothing is changed on the others.
+	if pr.GetState() != "open" {
+		return bot.replyCheckOnClosedPR(ctx, org, repo, pr, cfg)
+	}
+
+	if b, err := bot.canTriggerCheck(ctx, org, repo, pr, commenter, cfg); err != nil || !b {
+		if err != nil {
+			return err
+		}
+
+		return bot.refuseCheck(ctx, org, repo, pr.GetNumber(), commenter, cfg, log)
+	}
+
+	if cooldown := cfg.checkCooldown(); cooldown > 0 {
+		if v, ok := bot.lastChecks.get(key); ok {
+			if r := v.(*checkRecord); time.Since(r.at) < cooldown {
+				return bot.handleCheckInCooldown(ctx, org, repo, pr.GetNumber(), cfg, r, log)
+			}
+		}
+
+		bot.lastChecks.set(key, &checkRecord{at: time.Now()})
+	}
+
+	if !cfg.commentEnabled() {
+		return bot.handle(ctx, org, repo, pr, cfg, trigger, log)
+	}
+
+	// Acknowledge the command at once, because the check may take a while.
+	prNumber := pr.GetNumber()
+
+	id, err := postPlaceholder(ctx, org, repo, prNumber, checkingNotice(cfg.CommentLanguage), bot.cli)
+	if err != nil {
+		log.WithError(err).Warning("Could not post the placeholder.")
+
+		return bot.handle(ctx, org, repo, pr, cfg, trigger, log)
+	}
+
+	err = bot.handle(ctx, org, repo, pr, cfg, trigger, log)
+
+	fallback := ""
+	if err != nil {
+		fallback = withMarker(markerCheckFailed, withFingerprint(cfg.checkCLAErrorNotice(), fingerprint(nil)))
+	}
+
+	if err1 := resolvePlaceholder(ctx, org, repo, prNumber, id, fallback, cfg, bot.cli); err1 != nil {
+		log.WithError(err1).Warning("Could not resolve the placeholder.")
+	}
+
+	return err
+}
+
+// replyCheckOnClosedPR replies once to "/check-cla" on the PR which is closed or merged.
+func (bot *robot) replyCheckOnClosedPR(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	cfg *botConfig,
+) error {
+	if !cfg.commentEnabled() {
+		return nil
+	}
+
+	prNumber := pr.GetNumber()
+
+	comments, err := listAllPRComments(ctx, org, repo, prNumber, bot.cli)
+	if err != nil {
+		return err
+	}
+
+	if len(findBotComments(comments, markerClosedPR)) > 0 {
+		return nil
+	}
+
+	merged := pr.Merged || pr.GetState() == "merged"
+
+	return bot.cli.CreatePRComment(ctx, org, repo, prNumber, withMarker(markerClosedPR, closedPRNotice(cfg.CommentLanguage, merged)))
+}
+
+// canTriggerCheck checks whether the commenter has one of the roles allowed to
+// trigger the check. The author of PR is always allowed.
+func (bot *robot) canTriggerCheck(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	commenter string,
+	cfg *botConfig,
+) (bool, error) {
+	roles := sets.NewString(cfg.AllowedCheckRoles...)
+	if roles.Has(checkRoleAnyone) || commenter == pr.GetUser().GetLogin() {
+		return true, nil
+	}
+
+	if roles.Has(checkRoleCollaborator) {
+		return bot.cli.IsCollaborator(ctx, org, repo, commenter)
+	}
+
+	return false, nil
+}
+
+// refuseCheck replies to the commenter who is not allowed to trigger the check.
+// It replies only once for each commenter on a PR.
+func (bot *robot) refuseCheck(
+	ctx context.Context,
+	org, repo string,
+	prNumber int32,
+	commenter string,
+	cfg *botConfig,
+	log *logrus.Entry,
+) error {
+	log.Infof("%s is not allowed to trigger the check.", commenter)
+
+	if !cfg.commentEnabled() {
+		return nil
+	}
+
+	comments, err := listAllPRComments(ctx, org, repo, prNumber, bot.cli)
+	if err != nil {
+		return err
+	}
+
+	content := checkRefusedNotice(cfg.CommentLanguage, commenter, cfg.AllowedCheckRoles)
+	for _, item := range findBotComments(comments, markerCheckRefused) {
+		if stripFooter(item.Body) == withMarker(markerCheckRefused, content) {
+			return nil
+		}
+	}
+
+	return bot.cli.CreatePRComment(ctx, org, repo, prNumber, withMarker(markerCheckRefused, content))
+}
+
+// handleCheckInCooldown replies once to the "/check-cla" comments within the
+// cooldown instead of re-running the check. The later ones are ignored.
+func (bot *robot) handleCheckInCooldown(
+	ctx context.Context,
+	org, repo string,
+	prNumber int32,
+	cfg *botConfig,
+	r *checkRecord,
+	log *logrus.Entry,
+) error {
+	log.Info("The check is in cooldown, skip it.")
+
+	if r.replied || !cfg.commentEnabled() {
+		return nil
+	}
+
+	r.replied = true
+
+	return bot.cli.CreatePRComment(
+		ctx, org, repo, prNumber,
+		withMarker(markerCooldown, checkInCooldownNotice(cfg.CommentLanguage, int(time.Since(r.at).Seconds()))),
+	)
+}
+
+// waiverOf returns why the check of PR is skipped, such as the exemptions or
+// the override. It is empty if the PR should be checked. The waiver which
+// can't be looked up is skipped, so that the PR is checked as usual.
+func (bot *robot) waiverOf(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	cfg *botConfig,
+	labels sets.String,
+	log *logrus.Entry,
+) string {
+	prNumber := pr.GetNumber()
+
+	if exempted, err := bot.isExempted(ctx, org, repo, prNumber); err != nil {
+		log.WithError(err).Warning("Could not check whether the pr is exempted.")
+	} else if exempted {
+		log.Info("The pr is exempted by the maintainers, skip checking CLA.")
+
+		return waiverExempted
+	}
+
+	if l := cfg.ManualOverrideLabel; l != "" && labels.Has(l) {
+		log.Infof("The pr has the manual override label: %s, skip checking CLA.", l)
+
+		return waiverManualOverride
+	}
+
+	if cfg.ExemptSameOrgSource {
+		ns, err := bot.getPRSourceNamespace(ctx, org, repo, pr)
+		if err != nil {
+			log.WithError(err).Warning("Could not get the namespace of source repo.")
+		} else if ns == org {
+			log.Infof("The source branch of pr is in the same org: %s, exempt it from checking CLA.", org)
+
+			return waiverSameOrgSource
+		}
+	}
+
+	if cfg.LitePRMaxLines > 0 {
+		n, err := bot.getPRChangedLines(ctx, org, repo, pr)
+		if err != nil {
+			log.WithError(err).Warning("Could not get the changed lines of pr.")
+		} else if n < cfg.LitePRMaxLines {
+			log.Infof("The pr changes %d lines, exempt it from checking CLA.", n)
+
+			return waiverLitePR
+		}
+	}
+
+	return ""
+}
+
+func (bot *robot) handle(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	cfg *botConfig,
+	trigger *triggerNote,
+	log *logrus.Entry,
+) (err error) {
+	prNumber := pr.GetNumber()
+
+	// state is the CLA state found by the check, which is compared with the
+	// one before it to count the transitions.
+	var state string
+
+	// waiver is why the check is skipped, such as the exemptions or the
+	// override. It is empty if the check is not skipped.
+	waiver := ""
+
+	// emails is the breakdown of the check by the emails.
+	var emails []emailStatus
+
+	// decision carries the reason of the mutations to the audit log.
+	decision, endDecision := bot.audit.begin(org, repo, prNumber, auditTriggerOf(ctx, trigger != nil))
+	defer endDecision()
+	decision.setReason("checking")
+
+	start := time.Now()
+	defer func() {
+		observeCheck(trigger != nil, state, err, time.Since(start))
+		bot.observeVerdict(org, repo, checkVerdict(state, waiver != "", err))
+		bot.recordPRStatus(org, repo, prNumber, checkVerdict(state, waiver != "", err), waiver, emails)
+
+		if r := checkReportOf(ctx); r != nil {
+			r.state, r.waived = state, waiver != ""
+		}
+	}()
+
+	// The author is notified even if signed when the check is triggered by the command.
+	notifyAuthorIfSigned := trigger != nil
+
+	// degradedNote tells that the results may be stale in the degraded mode.
+	degradedNote := ""
+
+	// checkInfo appends the info of check and quotes the triggering comment if it is set.
+	checkInfo := func(content string) string {
+		if degradedNote != "" {
+			content += "\n\n" + degradedNote
+		}
+
+		content = withCheckInfo(content, checkTrigger(notifyAuthorIfSigned))
+
+		if cfg.QuoteTriggerComment && trigger != nil {
+			content = trigger.quote(cfg.CommentLanguage) + content
+		}
+
+		return content
+	}
+
+	pr, open, err := bot.refreshPR(ctx, org, repo, pr, log)
+	if err != nil {
+		return err
+	}
+
+	if !open {
+		log.Info("The pr is not open any more, skip checking CLA.")
+
+		return nil
+	}
+
+	// The labels of webhook is a snapshot taken when the event fired,
+	// so the live labels are used to make decisions.
+	labels, err := bot.getPRLabels(ctx, org, repo, prNumber)
+	if err != nil {
+		return err
+	}
+
+	// Both labels may be present because the failures of changing labels are
+	// only logged. The contradictory one will be removed according to the result.
+	if labels.HasAll(cfg.CLALabelYes, cfg.CLALabelNo) {
+		log.WithFields(logrus.Fields{
+			"conflict": "dual-labels",
+			"labels":   []string{cfg.CLALabelYes, cfg.CLALabelNo},
+		}).Warning("The pr has both the cla labels.")
+	}
+
+	// labels is updated along with the mutations, so it is the intended state at the end.
+	if cfg.DetectDrift && cfg.labelEnabled() {
+		defer bot.detectDrift(ctx, org, repo, prNumber, cfg, labels, log)
+	}
+
+	// The checking label is removed at the end regardless of the outcome. It
+	// is deferred after detectDrift, so that it runs before it.
+	if l := cfg.CLALabelChecking; l != "" {
+		if err := bot.addLabel(ctx, org, repo, prNumber, cfg, labels, l, log); err != nil {
+			log.WithError(err).Warning("Could not add the checking label.")
+		}
+
+		defer func() {
+			if err := bot.removeLabel(ctx, org, repo, prNumber, cfg, labels, l, log); err != nil {
+				log.WithError(err).Warning("Could not remove the checking label.")
+			}
+		}()
+	}
+
+	prevState := claStateOfLabels(cfg, labels)
+	defer func() {
+		if state != "" {
+			bot.transitions.observe(org, repo, prNumber, prevState, state)
+			bot.lastCheckedAt.set(prKey(org, repo, prNumber), time.Now())
+		}
+	}()
+
+	// status is the CLA status which will be set on the head commit of PR.
+	var status commitStatus
+	if cfg.SetCommitStatus {
+		defer func() {
+			bot.setCommitStatus(ctx, org, repo, pr.GetHead().GetSha(), cfg, status, log)
+		}()
+	}
+
+	switch waiver = bot.waiverOf(ctx, org, repo, pr, cfg, labels, log); waiver {
+	case waiverExempted, waiverSameOrgSource, waiverLitePR:
+		status = newCommitStatus(statusSuccess, "The pull request is exempted from CLA")
+		decision.setReason(waiver)
+
+		note := ""
+		if waiver == waiverLitePR {
+			note = cfg.LitePRNote
+		}
+
+		return bot.handleExemptPR(ctx, org, repo, prNumber, cfg, labels, note, log)
+
+	case waiverManualOverride:
+		status = newCommitStatus(statusSuccess, "The CLA check is overridden manually")
+		decision.setReason(waiver)
+
+		return bot.handleManualOverride(ctx, org, repo, prNumber, cfg, labels, log)
+	}
+
+	agreements, err := bot.getApplicableAgreements(ctx, org, repo, prNumber, cfg)
+	if err != nil {
+		decision.setReason("check_failed")
+		bot.notifyCheckCLAFailed(ctx, org, repo, prNumber, cfg, log)
+
+		status = newCommitStatus(statusError, "The CLA can't be checked")
+
+		return err
+	}
+
+	results, err := bot.getPRCommitsAbout(ctx, org, repo, prNumber, cfg, agreements)
+	if errors.Is(err, errNoCommits) {
+		decision.setReason("no_commits")
+
+		return bot.handleNoCommits(ctx, org, repo, prNumber, cfg, log)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			log.WithError(err).Warning("The deadline of event is exceeded, leave the labels untouched.")
+		}
+
+		decision.setReason("check_failed")
+		bot.notifyCheckCLAFailed(ctx, org, repo, prNumber, cfg, log)
+
+		status = newCommitStatus(statusError, "The CLA can't be checked")
+
+		return err
+	}
+
+	emails = emailStatuses(results)
+
+	for i := range results {
+		if results[i].stale {
+			log.Warning("The CLA service fails, some emails use the last known results.")
+			degradedNote = staleResultsNote(cfg.CommentLanguage)
+
+			break
+		}
+	}
+
+	unsigned := filterUnsigned(results)
+	sortUnsignedCommits(unsigned)
+	if len(unsigned) == 0 {
+		if unknown := filterUnknown(results); len(unknown) > 0 {
+			state = claStateUnknown
+			decision.setReason("unknown:%d emails", countUnknownAuthors(unknown))
+			status = newCommitStatus(
+				statusError,
+				fmt.Sprintf("%d authors can't be verified", countUnknownAuthors(unknown)),
+			)
+
+			var errs mutationErrors
+			errs.add(bot.handleCheckCLAError(
+				ctx, org, repo, prNumber, cfg, labels, unknown, notifyAuthorIfSigned, log,
+			))
+
+			// The unavailability of CLA service is transient, so it is
+			// returned to give the upstream a chance to retry.
+			return errs.join(checkerUnavailableError(unknown))
+		}
+	}
+
+	// All the steps are attempted even if some of them fail, and the
+	// failures are returned together.
+	var errs mutationErrors
+
+	errs.add(bot.removeLabel(ctx, org, repo, prNumber, cfg, labels, cfg.CLALabelError, log))
+
+	if len(unsigned) == 0 {
+		state = claStateSigned
+		decision.setReason("signed")
+		status = newCommitStatus(statusSuccess, "All authors have signed the CLA")
+
+		errs.add(bot.removeContradictoryLabel(ctx, org, repo, prNumber, cfg, labels, cfg.CLALabelNo, log))
+
+		// The labels are not changed in comment_only mode, so the explicit
+		// "/check-cla" always gets a reply of still signed.
+		transition := !labels.Has(cfg.CLALabelYes) && (cfg.labelEnabled() || !notifyAuthorIfSigned)
+
+		if transition {
+			errs.add(bot.addLabel(ctx, org, repo, prNumber, cfg, labels, cfg.CLALabelYes, log))
+
+			if !cfg.commentEnabled() {
+				return errs.join(nil)
+			}
+
+			emails, exempted := verifiedEmails(results, cfg)
+
+			return errs.join(updateAlreadySigned(
+				ctx, org, repo, prNumber,
+				checkInfo(cfg.render(
+					tmplConfirmation,
+					templateData{Author: pr.GetUser().GetLogin(), VerifiedEmails: emails},
+					alreadySigned(cfg.CommentLanguage, pr.GetUser().GetLogin(), emails, exempted),
+				)),
+				cfg, bot.cli,
+			))
+		}
+
+		if !cfg.commentEnabled() {
+			return errs.join(nil)
+		}
+
+		deleteSignGuide(ctx, org, repo, prNumber, cfg, bot.cli)
+
+		if notifyAuthorIfSigned {
+			return errs.join(replaceBotComment(
+				ctx, org, repo, prNumber, markerStillSigned,
+				checkInfo(stillSigned(cfg.CommentLanguage, pr.GetUser().GetLogin())),
+				bot.cli,
+			))
+		}
+
+		return errs.join(nil)
+	}
+
+	state = claStateUnsigned
+	decision.setReason("unsigned:%d emails", countUnsignedAuthors(unsigned))
+	bot.unsignedSeen.observe(bot.metricRepo(org, repo), unsigned)
+	reportUnsigned(ctx, unsigned)
+	bot.indexUnsigned(org, repo, prNumber, unsigned)
+	status = newCommitStatus(
+		statusFailure,
+		fmt.Sprintf("%d authors unsigned", countUnsignedAuthors(unsigned)),
+	)
+
+	bot.notifyUnsigned(org, repo, pr, unsigned, log)
+
+	// The labels and the sign guide are reconciled separately, so the guide
+	// deleted by someone will be reposted even if the labels are already correct.
+	errs.add(bot.removeContradictoryLabel(ctx, org, repo, prNumber, cfg, labels, cfg.CLALabelYes, log))
+	errs.add(bot.addLabel(ctx, org, repo, prNumber, cfg, labels, cfg.CLALabelNo, log))
+
+	// The approvals are not restored when the PR becomes signed, that's for humans.
+	removed := make([]string, 0, len(cfg.LabelsToRemoveWhenUnsigned))
+	for _, l := range cfg.LabelsToRemoveWhenUnsigned {
+		if !labels.Has(l) {
+			log.Debugf("The label %s is absent, no need to remove it.", l)
+			continue
+		}
+
+		err := bot.removeLabel(ctx, org, repo, prNumber, cfg, labels, l, log)
+		if errs.add(err); err == nil && !labels.Has(l) {
+			removed = append(removed, l)
+		}
+	}
+
+	if !cfg.commentEnabled() {
+		return errs.join(nil)
+	}
+
+	login := pr.GetUser().GetLogin()
+
+	author := ""
+	if cfg.MentionAuthor {
+		author = login
+	}
+
+	welcome := ""
+	if cfg.WelcomeFirstTimer && bot.isFirstTimer(ctx, org, repo, login, log) {
+		welcome = cfg.FirstTimerWelcome
+	}
+
+	if cfg.MentionCommitAuthors {
+		bot.resolveCommitLogins(ctx, unsigned, log)
+	}
+
+	cfg.pickFAQs(unsigned)
+
+	content := cfg.render(
+		tmplSignGuide, unsignedTemplateData(cfg, login, unsigned),
+		fitSignGuide(cfg, unsigned, author, welcome),
+	)
+	if len(removed) > 0 {
+		content += "\n\n" + approvalsResetNote(cfg.CommentLanguage, removed)
+	}
+
+	content = checkInfo(content)
+	fp := unsignedFingerprint(unsigned)
+
+	err = updateSignGuide(ctx, org, repo, prNumber, content, fp, notifyAuthorIfSigned, cfg, bot.cli)
+	if isClientError(err) {
+		// The guide may be rejected because of the content, such as the encoding
+		// of commit messages, so the minimal one is posted instead to explain the label.
+		log.WithError(err).WithField("response", responseBody(err)).Warning(
+			"The sign guide was rejected, post the minimal one instead.",
+		)
+
+		total := 0
+		for i := range unsigned {
+			total += len(unsigned[i].unsigned)
+		}
+
+		err = updateSignGuide(
+			ctx, org, repo, prNumber,
+			checkInfo(minimalSignGuide(cfg.CommentLanguage, unsigned, author, total)),
+			fp, notifyAuthorIfSigned, cfg, bot.cli,
+		)
+	}
+
+	return errs.join(err)
+}
+
+// detectDrift compares the CLA labels of PR with the intended ones, in case
+// someone else, such as another robot, undoes the changes of this robot.
+func (bot *robot) detectDrift(
+	ctx context.Context,
+	org, repo string,
+	prNumber int32,
+	cfg *botConfig,
+	intended sets.String,
+	log *logrus.Entry,
+) {
+	observed, err := bot.getPRLabels(ctx, org, repo, prNumber)
+	if err != nil {
+		log.WithError(err).Warning("Could not get the labels to detect the drift.")
+
+		return
+	}
+
+	claLabels := sets.NewString(cfg.CLALabelYes, cfg.CLALabelNo)
+	if cfg.CLALabelError != "" {
+		claLabels.Insert(cfg.CLALabelError)
+	}
+
+	want := intended.Intersection(claLabels)
+	got := observed.Intersection(claLabels)
+	if want.Equal(got) {
+		return
+	}
+
+	v := bot.metricRepo(org, repo)
+	labelDrifts.WithLabelValues(v[0], v[1]).Inc()
+
+	log.WithFields(logrus.Fields{
+		"cla_state_drift": true,
+		"org":             org,
+		"repo":            repo,
+		"pr":              prNumber,
+		"intended":        want.List(),
+		"observed":        got.List(),
+	}).Warning("cla state drift")
+}
+
+// handleCheckCLAError deals with the case that there is no unsigned commit but
+// some authors of commits can't be verified. The existing labels are left alone
+// unless the error label is configured, and the notice listing those authors is refreshed.
+func (bot *robot) handleCheckCLAError(
+	ctx context.Context,
+	org, repo string,
+	prNumber int32,
+	cfg *botConfig,
+	labels sets.String,
+	unknown []agreementResult,
+	force bool,
+	log *logrus.Entry,
+) error {
+	log.Warning("Some authors of commits can't be verified.")
+
+	var errs mutationErrors
+
+	if cfg.CLALabelError != "" {
+		errs.add(bot.removeLabel(ctx, org, repo, prNumber, cfg, labels, cfg.CLALabelYes, log))
+		errs.add(bot.removeLabel(ctx, org, repo, prNumber, cfg, labels, cfg.CLALabelNo, log))
+		errs.add(bot.addLabel(ctx, org, repo, prNumber, cfg, labels, cfg.CLALabelError, log))
+	}
+
+	if !cfg.commentEnabled() {
+		return errs.join(nil)
+	}
+
+	return errs.join(updateCheckCLAFailedNotice(
+		ctx, org, repo, prNumber,
+		cfg.render(
+			tmplErrorNotice, templateData{UnknownTable: generateUnknownComment(cfg, unknown)},
+			checkCLAErrorNotice(cfg.CommentLanguage, generateUnknownComment(cfg, unknown)),
+		),
+		unknownFingerprint(unknown), force, bot.cli,
+	))
+}
+
+// handleNoCommits leaves the labels untouched and tells the contributor that
+// the check can be re-run, because the commits of PR can't be retrieved yet.
+func (bot *robot) handleNoCommits(
+	ctx context.Context,
+	org, repo string,
+	prNumber int32,
+	cfg *botConfig,
+	log *logrus.Entry,
+) error {
+	log.Warning("The commits of pr can't be retrieved yet, leave the labels untouched.")
+
+	if !cfg.commentEnabled() {
+		return nil
+	}
+
+	return updateCheckCLAFailedNotice(
+		ctx, org, repo, prNumber, noCommitsNotice(cfg.CommentLanguage),
+		fingerprint([]string{"no-commits"}), false, bot.cli,
+	)
+}
+
+// notifyCheckCLAFailed posts the notice when the CLA can't be checked for
+// infrastructure reasons. The labels are left untouched.
+func (bot *robot) notifyCheckCLAFailed(
+	ctx context.Context,
+	org, repo string,
+	prNumber int32,
+	cfg *botConfig,
+	log *logrus.Entry,
+) {
+	if !cfg.commentEnabled() {
+		return
+	}
+
+	err := updateCheckCLAFailedNotice(
+		ctx, org, repo, prNumber, cfg.checkCLAErrorNotice(), fingerprint(nil), false, bot.cli,
+	)
+	if err != nil {
+		log.WithError(err).Warning("Could not post the notice of checking CLA failed.")
+	}
+}
+
+// handleExemptPR labels the pr which is exempted from checking CLA as cla/yes
+// without checking the cla of its commits. The note will be posted if it is not empty,
+// and it is reposted if it was deleted even though the labels are correct.
+func (bot *robot) handleExemptPR(
+	ctx context.Context,
+	org, repo string,
+	prNumber int32,
+	cfg *botConfig,
+	labels sets.String,
+	note string,
+	log *logrus.Entry,
+) error {
+	if cfg.commentEnabled() {
+		deleteSignGuide(ctx, org, repo, prNumber, cfg, bot.cli)
+	}
+
+	var errs mutationErrors
+
+	errs.add(bot.removeLabel(ctx, org, repo, prNumber, cfg, labels, cfg.CLALabelError, log))
+	errs.add(bot.removeLabel(ctx, org, repo, prNumber, cfg, labels, cfg.CLALabelNo, log))
+	errs.add(bot.addLabel(ctx, org, repo, prNumber, cfg, labels, cfg.CLALabelYes, log))
+
+	if note == "" || !cfg.commentEnabled() {
+		return errs.join(nil)
+	}
+
+	return errs.join(updateBotComment(ctx, org, repo, prNumber, markerExempt, note, bot.cli))
+}
+
+// handleManualOverride labels the pr which has the manual override label as cla/yes
+// without checking the cla of its commits, and notes who applied the override label.
+func (bot *robot) handleManualOverride(
+	ctx context.Context,
+	org, repo string,
+	prNumber int32,
+	cfg *botConfig,
+	labels sets.String,
+	log *logrus.Entry,
+) error {
+	var errs mutationErrors
+
+	errs.add(bot.removeLabel(ctx, org, repo, prNumber, cfg, labels, cfg.CLALabelError, log))
+	errs.add(bot.removeLabel(ctx, org, repo, prNumber, cfg, labels, cfg.CLALabelNo, log))
+	errs.add(bot.addLabel(ctx, org, repo, prNumber, cfg, labels, cfg.CLALabelYes, log))
+
+	if !cfg.commentEnabled() {
+		return errs.join(nil)
+	}
+
+	label := cfg.ManualOverrideLabel
+
+	user, err := bot.getLabelApplier(ctx, org, repo, prNumber, label)
+	if err != nil {
+		log.WithError(err).Warningf("Could not find who applied the %s label.", label)
+	}
+
+	return errs.join(updateOverriddenNotice(
+		ctx, org, repo, prNumber, manualOverrideNotice(cfg.CommentLanguage, label, user), cfg, bot.cli,
+	))
+}
+
+// getLabelApplier returns the login of user who applied the label latest
+// by looking up the operation logs of PR. It returns empty if not found.
+func (bot *robot) getLabelApplier(ctx context.Context, org, repo string, prNumber int32, label string) (string, error) {
+	logs, err := bot.cli.ListPROperationLogs(ctx, org, repo, prNumber)
+	if err != nil {
+		return "", err
+	}
+
+	var r *sdk.OperateLog
+	for i := range logs {
+		item := &logs[i]
+		if item.User == nil || !strings.Contains(item.Content, label) {
+			continue
+		}
+
+		if r == nil || item.Id > r.Id {
+			r = item
+		}
+	}
+
+	if r == nil {
+		return "", nil
+	}
+
+	return r.User.Login, nil
+}
+
+var prNumberInURLRe = regexp.MustCompile(`/pulls/(\d+)`)
+
+// getNotePR returns the PR of note event. It is fetched via the API if the
+// embedded one is missing or incomplete, which happens on some Gitee enterprise instances.
+func (bot *robot) getNotePR(ctx context.Context, e *sdk.NoteEvent, org, repo string) (*sdk.PullRequestHook, error) {
+	pr := e.GetPullRequest()
+	if pr != nil && pr.Number > 0 && pr.GetBase().GetRef() != "" && pr.GetHead().GetSha() != "" {
+		return pr, nil
+	}
+
+	var number int32
+	if pr != nil {
+		number = pr.Number
+	}
+
+	if number <= 0 {
+		var url string
+		if c := e.GetComment(); c != nil {
+			url = c.HtmlUrl
+		}
+
+		m := prNumberInURLRe.FindStringSubmatch(url)
+		if len(m) != 2 {
+			return nil, fmt.Errorf("the note event lacks the pull request and its number")
+		}
+
+		v, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid number of pull request: %s", m[1])
+		}
+
+		number = int32(v)
+	}
+
+	v, err := bot.cli.GetGiteePullRequest(ctx, org, repo, number)
+	if err != nil {
+		return nil, fmt.Errorf("fetch the pull request %d of note event: %w", number, err)
+	}
+
+	return toPullRequestHook(&v), nil
+}
+
+// refreshPR fetches the live PR, because the payload may describe an older
+// head during the rapid force-pushes. The fresh head and base are used when
+// the payload is stale. It also returns whether the PR is still open.
+func (bot *robot) refreshPR(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	log *logrus.Entry,
+) (*sdk.PullRequestHook, bool, error) {
+	v, err := bot.cli.GetGiteePullRequest(ctx, org, repo, pr.GetNumber())
+	if err != nil {
+		return nil, false, err
+	}
+
+	if v.State != "open" {
+		return pr, false, nil
+	}
+
+	live := toPullRequestHook(&v)
+	if sha := live.GetHead().GetSha(); sha == "" || sha == pr.GetHead().GetSha() {
+		return pr, true, nil
+	}
+
+	log.WithFields(logrus.Fields{
+		"stale_payload": true,
+		"payload_head":  pr.GetHead().GetSha(),
+		"live_head":     live.GetHead().GetSha(),
+	}).Info("The head of pr in the payload is stale, use the live one.")
+
+	fresh := *pr
+	fresh.Head = live.Head
+	fresh.Base = live.Base
+
+	// The statistics of changes belong to the stale head.
+	fresh.Additions = 0
+	fresh.Deletions = 0
+
+	return &fresh, true, nil
+}
+
+// getPRSourceNamespace returns the namespace of repo which the source branch of pr lives in.
+func (bot *robot) getPRSourceNamespace(ctx context.Context, org, repo string, pr *sdk.PullRequestHook) (string, error) {
+	if ns := pr.GetHead().GetRepo().GetNamespace(); ns != "" {
+		return ns, nil
+	}
+
+	v, err := bot.cli.GetGiteePullRequest(ctx, org, repo, pr.GetNumber())
+	if err != nil {
+		return "", err
+	}
+
+	if v.Head == nil || v.Head.Repo == nil || v.Head.Repo.Namespace == nil {
+		return "", fmt.Errorf("no namespace of source repo")
+	}
+
+	return v.Head.Repo.Namespace.Path, nil
+}
+
+// isFirstTimer checks whether it is the first PR of author. It returns false
+// silently when the lookup fails.
+func (bot *robot) isFirstTimer(ctx context.Context, org, repo, author string, log *logrus.Entry) bool {
+	key := org + "/" + author
+	if v, ok := bot.firstTimers.get(key); ok {
+		return v.(bool)
+	}
+
+	b, err := bot.cli.HasMergedPR(ctx, org, repo, author)
+	if err != nil {
+		log.WithError(err).Warning("Could not check whether the author is a first-time contributor.")
+
+		return false
+	}
+
+	bot.firstTimers.set(key, !b)
 
-type iClient interface {
-	AddPRLabel(owner, repo string, number int32, label string) error
-	RemovePRLabel(org, repo string, number int32, label string) error
-	CreatePRComment(org, repo string, number int32, comment string) error
-	DeletePRComment(org, repo string, ID int32) error
-	GetPRCommits(org, repo string, number int32) ([]sdk.PullRequestCommits, error)
-	ListPRComments(org, repo string, number int32) ([]sdk.PullRequestComments, error)
+	return !b
 }
 
-func newRobot(cli iClient) *robot {
-	return &robot{cli: cli}
-}
+// resolveCommitLogins resolves the Gitee accounts of unsigned commits, so that
+// they can be mentioned. The login of commit is preferred, and the email is
+// searched otherwise. The ones which can't be resolved are left empty.
+func (bot *robot) resolveCommitLogins(ctx context.Context, results []agreementResult, log *logrus.Entry) {
+	for i := range results {
+		items := results[i].unsigned
 
-type robot struct {
-	cli iClient
-}
+		for j := range items {
+			item := &items[j]
 
-func (bot *robot) NewConfig() config.Config {
-	return &configuration{}
+			user := item.commit.Author
+			if item.role == roleCommitter {
+				user = item.commit.Committer
+			}
+
+			if user != nil && user.Login != "" {
+				item.login = user.Login
+			} else {
+				item.login = bot.findLoginByEmail(ctx, item.email, log)
+			}
+
+			if item.login == bot.botLogin {
+				item.login = ""
+			}
+		}
+	}
 }
 
-func (bot *robot) getConfig(cfg config.Config, org, repo string) (*botConfig, error) {
-	c, ok := cfg.(*configuration)
-	if !ok {
-		return nil, fmt.Errorf("can't convert to configuration")
+func (bot *robot) findLoginByEmail(ctx context.Context, email string, log *logrus.Entry) string {
+	if v, ok := bot.emailLogins.get(email); ok {
+		return v.(string)
 	}
 
-	if bc := c.configFor(org, repo); bc != nil {
-		return bc, nil
+	login, err := bot.cli.FindUserByEmail(ctx, email)
+	if err != nil {
+		log.WithError(err).Warningf("Could not find the user of email: %s.", email)
+
+		return ""
 	}
 
-	return nil, fmt.Errorf("no config for this repo:%s/%s", org, repo)
+	bot.emailLogins.set(email, login)
+
+	return login
 }
 
-func (bot *robot) RegisterEventHandler(f framework.HandlerRegitster) {
-	f.RegisterPullRequestHandler(bot.handlePREvent)
-	f.RegisterNoteEventHandler(bot.handleNoteEvent)
+func (bot *robot) getPRLabels(ctx context.Context, org, repo string, number int32) (sets.String, error) {
+	v, err := bot.cli.GetPRLabels(ctx, org, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	r := sets.NewString()
+	for i := range v {
+		r.Insert(v[i].Name)
+	}
+
+	return r, nil
 }
 
-func (bot *robot) handlePREvent(e *sdk.PullRequestEvent, c config.Config, log *logrus.Entry) error {
-	if e.GetPullRequest().GetState() != "open" {
+// addLabel adds the label to PR if it is not empty and not present on the PR.
+func (bot *robot) addLabel(
+	ctx context.Context,
+	org, repo string,
+	prNumber int32,
+	cfg *botConfig,
+	labels sets.String,
+	label string,
+	log *logrus.Entry,
+) error {
+	if !cfg.labelEnabled() || label == "" || labels.Has(label) {
 		return nil
 	}
 
-	if v := sdk.GetPullRequestAction(e); v != sdk.PRActionOpened && v != sdk.PRActionChangedSourceBranch {
-		return nil
+	err := bot.cli.AddPRLabel(ctx, org, repo, prNumber, label)
+	if err != nil && isNotFoundError(err) && bot.createRepoLabel(ctx, org, repo, cfg, label, log) {
+		err = bot.cli.AddPRLabel(ctx, org, repo, prNumber, label)
 	}
 
-	org, repo := e.GetOrgRepo()
+	if err != nil {
+		log.WithError(err).Warningf("Could not add %s label.", label)
+		bot.handleMutationError(ctx, org, repo, prNumber, cfg, err, log)
+
+		return fmt.Errorf("add label %s: %w", label, classifyGiteeError(err))
+	}
+
+	bot.recoverPermission(org, repo)
+	labels.Insert(label)
+
+	return nil
+}
+
+// createRepoLabel creates the missing label in the repo. The creation is
+// attempted at most once per repo per process run, in case the real cause
+// of failure is a permission problem.
+func (bot *robot) createRepoLabel(
+	ctx context.Context,
+	org, repo string,
+	cfg *botConfig,
+	label string,
+	log *logrus.Entry,
+) bool {
+	key := fmt.Sprintf("%s/%s:%s", org, repo, label)
+	if v, ok := bot.createdLabels.Load(key); ok {
+		return v.(bool)
+	}
 
-	cfg, err := bot.getConfig(c, org, repo)
+	err := bot.cli.CreateRepoLabel(ctx, org, repo, label, cfg.labelColor(label))
 	if err != nil {
-		return err
+		log.WithError(err).Warningf("Could not create %s label.", label)
 	}
 
-	return bot.handle(org, repo, e.GetPullRequest(), cfg, false, log)
+	bot.createdLabels.Store(key, err == nil)
+
+	return err == nil
 }
 
-func (bot *robot) handleNoteEvent(e *sdk.NoteEvent, c config.Config, log *logrus.Entry) error {
-	if !e.IsCreatingCommentEvent() || !e.IsPullRequest() {
-		return nil
+// handleMutationError alerts the admins on the PR when the robot lacks the
+// permissions to change it. It is limited to once per repo per day, and the
+// transient failures are ignored.
+func (bot *robot) handleMutationError(
+	ctx context.Context,
+	org, repo string,
+	prNumber int32,
+	cfg *botConfig,
+	err error,
+	log *logrus.Entry,
+) {
+	if !isPermissionError(err) {
+		return
 	}
 
-	// Only consider "/check-cla" comments.
-	if !checkCLARe.MatchString(e.GetComment().GetBody()) {
-		return nil
+	log.WithFields(logrus.Fields{
+		"cla_permission_failure": true,
+		"org":                    org,
+		"repo":                   repo,
+	}).Error("The CLA robot lacks the permissions on the repo.")
+
+	key := org + "/" + repo
+
+	v := bot.metricRepo(org, repo)
+	permissionFailures.WithLabelValues(v[0], v[1]).Inc()
+	if _, loaded := bot.permissionFailing.LoadOrStore(key, true); !loaded {
+		permissionFailing.WithLabelValues(v[0], v[1]).Set(1)
 	}
 
-	org, repo := e.GetOrgRepo()
+	if _, ok := bot.permissionAlerts.get(key); ok {
+		return
+	}
 
-	cfg, err := bot.getConfig(c, org, repo)
+	bot.permissionAlerts.set(key, true)
+
+	if err := bot.cli.CreatePRComment(
+		ctx, org, repo, prNumber,
+		withMarker(markerPermission, permissionFailureNotice(cfg.CommentLanguage, cfg.AdminTeam)),
+	); err != nil {
+		log.WithError(err).Warning("Could not post the alert of permission failure.")
+	}
+}
+
+// recoverPermission marks the repo as no longer lacking the permissions since
+// a mutation of its PR succeeds.
+func (bot *robot) recoverPermission(org, repo string) {
+	if _, ok := bot.permissionFailing.LoadAndDelete(org + "/" + repo); ok {
+		v := bot.metricRepo(org, repo)
+		permissionFailing.WithLabelValues(v[0], v[1]).Set(0)
+	}
+}
+
+// mutationErrors collects the failures of mutations on the PR.
+type mutationErrors []error
+
+func (m *mutationErrors) add(err error) {
 	if err != nil {
-		return err
+		*m = append(*m, err)
 	}
+}
 
-	return bot.handle(org, repo, e.GetPullRequest(), cfg, true, log)
+// join returns the collected failures and err together, or nil if there is none.
+func (m mutationErrors) join(err error) error {
+	return utilerrors.NewAggregate(append(m, err))
 }
 
-func (bot *robot) handle(
+func isPermissionError(err error) bool {
+	code := statusCode(err)
+
+	return code == http.StatusUnauthorized || code == http.StatusForbidden
+}
+
+func isNotFoundError(err error) bool {
+	return statusCode(err) == http.StatusNotFound
+}
+
+// removeLabel removes the label from PR if it is present on the PR.
+func (bot *robot) removeLabel(
+	ctx context.Context,
 	org, repo string,
-	pr *sdk.PullRequestHook,
+	prNumber int32,
 	cfg *botConfig,
-	notifyAuthorIfSigned bool,
+	labels sets.String,
+	label string,
 	log *logrus.Entry,
 ) error {
-	prNumber := pr.GetNumber()
+	if !cfg.labelEnabled() || label == "" || !labels.Has(label) {
+		return nil
+	}
+
+	if err := bot.cli.RemovePRLabel(ctx, org, repo, prNumber, label); err != nil {
+		log.WithError(err).Warningf("Could not remove %s label.", label)
+		bot.handleMutationError(ctx, org, repo, prNumber, cfg, err, log)
+
+		return fmt.Errorf("remove label %s: %w", label, classifyGiteeError(err))
+	}
+
+	bot.recoverPermission(org, repo)
+	labels.Delete(label)
+
+	return nil
+}
+
+// removeContradictoryLabel removes the label which contradicts the result of check.
+// The removal is retried once, so that the PR converges to a consistent state.
+func (bot *robot) removeContradictoryLabel(
+	ctx context.Context,
+	org, repo string,
+	prNumber int32,
+	cfg *botConfig,
+	labels sets.String,
+	label string,
+	log *logrus.Entry,
+) error {
+	if err := bot.removeLabel(ctx, org, repo, prNumber, cfg, labels, label, log); err == nil {
+		return nil
+	}
+
+	return bot.removeLabel(ctx, org, repo, prNumber, cfg, labels, label, log)
+}
+
+// getPRChangedLines returns the number of lines added and deleted by the pr.
+// It prefers the statistics carried by the webhook and falls back to
+// summing up the changed files when they are absent.
+func (bot *robot) getPRChangedLines(ctx context.Context, org, repo string, pr *sdk.PullRequestHook) (int, error) {
+	if n := int(pr.Additions + pr.Deletions); n > 0 {
+		return n, nil
+	}
 
-	unsigned, err := bot.getPRCommitsAbout(org, repo, prNumber, cfg)
+	files, err := bot.cli.GetPullRequestChanges(ctx, org, repo, pr.GetNumber())
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	labels := pr.LabelsToSet()
-	hasCLAYes := labels.Has(cfg.CLALabelYes)
-	hasCLANo := labels.Has(cfg.CLALabelNo)
+	toInt := func(s string) int {
+		if v, err := strconv.Atoi(s); err == nil {
+			return v
+		}
+		return 0
+	}
 
-	deleteSignGuide(org, repo, prNumber, bot.cli)
+	n := 0
+	for i := range files {
+		n += toInt(files[i].Additions) + toInt(files[i].Deletions)
+	}
 
-	if len(unsigned) == 0 {
-		if hasCLANo {
-			if err := bot.cli.RemovePRLabel(org, repo, prNumber, cfg.CLALabelNo); err != nil {
-				log.WithError(err).Warningf("Could not remove %s label.", cfg.CLALabelNo)
-			}
+	return n, nil
+}
+
+// getPRCommits fetches the commits of PR. They may be empty right after a
+// force-push while Gitee is catching up, so it is retried once after a delay.
+func (bot *robot) getPRCommits(ctx context.Context, org, repo string, number int32) ([]sdk.PullRequestCommits, error) {
+	for i := 0; ; i++ {
+		commits, err := bot.cli.GetPRCommits(ctx, org, repo, number)
+		if err != nil || len(commits) > 0 {
+			return commits, err
 		}
 
-		if !hasCLAYes {
-			if err := bot.cli.AddPRLabel(org, repo, prNumber, cfg.CLALabelYes); err != nil {
-				log.WithError(err).Warningf("Could not add %s label.", cfg.CLALabelYes)
-			}
+		if i > 0 {
+			return nil, errNoCommits
+		}
 
-			if notifyAuthorIfSigned {
-				return bot.cli.CreatePRComment(
-					org, repo, prNumber,
-					alreadySigned(pr.GetUser().GetLogin()),
-				)
-			}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(emptyCommitsRetryDelay):
 		}
+	}
+}
 
-		return nil
+func signedEmailKey(checkURL, email string) string {
+	return checkURL + " " + strings.ToLower(email)
+}
+
+// emailCheck is the result of checking the CLA of an email.
+type emailCheck struct {
+	signing signingInfo
+	err     error
+
+	// exempt is true if the email is exempted by the maintainers.
+	exempt bool
+
+	// stale is true if it is the last known result of the degraded mode.
+	stale bool
+}
+
+// checkEmail checks the CLA of a valid email against the CLA service of
+// checkURL. It honors the exempted emails, the cache of signed emails and
+// the degraded mode.
+func (bot *robot) checkEmail(
+	ctx context.Context,
+	org, repo string,
+	cfg *botConfig,
+	checkURL, email string,
+) emailCheck {
+	if bot.exemptions != nil && bot.exemptions.has(org, repo, email) {
+		return emailCheck{exempt: true}
 	}
 
-	if hasCLAYes {
-		if err := bot.cli.RemovePRLabel(org, repo, prNumber, cfg.CLALabelYes); err != nil {
-			log.WithError(err).Warningf("Could not remove %s label.", cfg.CLALabelYes)
+	if cached, hit := bot.signedEmails.get(signedEmailKey(checkURL, email)); hit {
+		checkerCacheHits.WithLabelValues(checkerHost(checkURL)).Inc()
+
+		return emailCheck{signing: cached.(signingInfo)}
+	}
+
+	signing, err := isSigned(ctx, email, checkURL)
+	if err == nil {
+		if signing.Signed {
+			bot.signedEmails.set(signedEmailKey(checkURL, email), signing)
 		}
+
+		bot.rememberResult(checkURL, email, signing)
+		bot.degraded.recover(checkURL)
+
+		return emailCheck{signing: signing}
 	}
 
-	if !hasCLANo {
-		if err := bot.cli.AddPRLabel(org, repo, prNumber, cfg.CLALabelNo); err != nil {
-			log.WithError(err).Warningf("Could not add %s label.", cfg.CLALabelNo)
+	if ctx.Err() == nil && cfg.DegradedMode.Enabled {
+		if known, hit := bot.lastKnownResultOf(checkURL, email, cfg.DegradedMode.maxAge()); hit {
+			bot.degraded.degrade(checkURL)
+
+			return emailCheck{signing: known, stale: true}
 		}
 	}
 
-	return bot.cli.CreatePRComment(
-		org, repo, prNumber,
-		signGuide(cfg.SignURL, generateUnSignComment(unsigned), cfg.FAQURL),
-	)
+	return emailCheck{err: err}
 }
 
+// getPRCommitsAbout checks the commits of PR against each agreement and
+// sorts them into signed, unsigned and unknown which means the check failed.
 func (bot *robot) getPRCommitsAbout(
+	ctx context.Context,
 	org, repo string,
 	number int32,
 	cfg *botConfig,
-) ([]*sdk.PullRequestCommits, error) {
-	commits, err := bot.cli.GetPRCommits(org, repo, number)
+	agreements []agreementConfig,
+) ([]agreementResult, error) {
+	commits, err := bot.getPRCommits(ctx, org, repo, number)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(commits) == 0 {
-		return nil, fmt.Errorf("commits is empty, cla cannot be checked")
+	authorEmailOfCommit := func(c *sdk.PullRequestCommits) (string, string) {
+		return getAuthorOfCommit(c, cfg.CheckByCommitter, cfg.LitePRCommitter.isLitePR)
 	}
 
-	authorEmailOfCommit := func(c *sdk.PullRequestCommits) string {
-		return getAuthorOfCommit(c, cfg.CheckByCommitter, cfg.LitePRCommitter.isLitePR)
+	// aborted reports how far the check got when the event is abandoned,
+	// and no partial result is returned to avoid changing labels based on it.
+	aborted := func(i, j int) error {
+		return fmt.Errorf(
+			"check aborted at commit %d/%d of agreement %d/%d: %w",
+			j+1, len(commits), i+1, len(agreements), ctx.Err(),
+		)
 	}
 
-	result := map[string]bool{}
-	unsigned := make([]*sdk.PullRequestCommits, 0, len(commits))
-	for i := range commits {
-		c := &commits[i]
+	r := make([]agreementResult, len(agreements))
+	for i := range agreements {
+		a := &agreements[i]
+		item := &r[i]
+		item.agreement = *a
 
-		email := strings.Trim(authorEmailOfCommit(c), " ")
-		if !utils.IsValidEmail(email) {
-			unsigned = append(unsigned, c)
-			continue
-		}
+		result := map[string]emailCheck{}
+		for j := range commits {
+			if ctx.Err() != nil {
+				return nil, aborted(i, j)
+			}
+
+			c := &commits[j]
 
-		if v, ok := result[email]; ok {
-			if !v {
-				unsigned = append(unsigned, c)
+			email, role := authorEmailOfCommit(c)
+			email = strings.Trim(email, " ")
+			if !utils.IsValidEmail(email) {
+				item.unsigned = append(item.unsigned, unsignedCommit{
+					commit: c, email: email, role: role,
+				})
+				item.addEmailResult(c, email, role, emailInvalid)
+				continue
 			}
-			continue
-		}
 
-		b, err := isSigned(email, cfg.CheckURL)
-		if err != nil {
-			return nil, err
-		}
-		result[email] = b
-		if !b {
-			unsigned = append(unsigned, c)
+			v, ok := result[email]
+			if !ok {
+				v = bot.checkEmail(ctx, org, repo, cfg, a.CheckURL, email)
+				if v.err != nil && ctx.Err() != nil {
+					return nil, aborted(i, j)
+				}
+
+				result[email] = v
+			}
+
+			if v.stale {
+				item.stale = true
+			}
+
+			switch {
+			case v.exempt:
+				item.signed = append(item.signed, c)
+				item.addEmailResult(c, email, role, emailExempt)
+			case v.err != nil:
+				item.unknown = append(item.unknown, unknownCommit{
+					commit: c, email: email, reason: v.err,
+				})
+				item.addEmailResult(c, email, role, emailUnknown)
+			case v.signing.Signed:
+				item.signed = append(item.signed, c)
+				item.addEmailResult(c, email, role, emailSigned).signing = v.signing
+			default:
+				item.unsigned = append(item.unsigned, unsignedCommit{
+					commit: c, email: email, role: role,
+				})
+				item.addEmailResult(c, email, role, emailUnsigned)
+			}
 		}
 	}
 
-	return unsigned, nil
+	return r, nil
 }
 
+// getAuthorOfCommit returns the email of the identity whose CLA should be checked
+// and the role of that identity, which is roleCommitter or roleAuthor.
 func getAuthorOfCommit(
 	c *sdk.PullRequestCommits,
 	byCommitter bool,
 	isLitePR func(email string, name string) bool,
-) string {
+) (string, string) {
 	if c == nil || c.Commit == nil {
-		return ""
+		return "", roleAuthor
 	}
 
 	commit := c.Commit
@@ -224,106 +2467,327 @@ func getAuthorOfCommit(
 	if byCommitter {
 		committer := commit.Committer
 		if committer != nil && !isLitePR(committer.Email, committer.Name) {
-			return committer.Email
+			return committer.Email, roleCommitter
 		}
 	}
 
 	if commit.Author == nil {
-		return ""
+		return "", roleAuthor
 	}
 
-	return commit.Author.Email
+	return commit.Author.Email, roleAuthor
 }
 
-func isSigned(email, url string) (bool, error) {
+// isSigned checks whether the email has signed, and records the metrics of CLA service.
+func isSigned(ctx context.Context, email, url string) (signingInfo, error) {
+	start := time.Now()
+
+	v, code, err := requestSigning(ctx, email, url)
+
+	observeCheckerRequest(url, checkerOutcome(ctx, &v, code, err), time.Since(start))
+
+	return v, err
+}
+
+// requestSigning asks the CLA service whether the email has signed. It also
+// returns the status code of response, which is 0 if there is no response.
+func requestSigning(ctx context.Context, email, url string) (signingInfo, int, error) {
 	endpoint := fmt.Sprintf("%s?email=%s", url, email)
 
-	resp, err := http.Get(endpoint)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
-		return false, err
+		return signingInfo{}, 0, fmt.Errorf("%w: %v", ErrBadConfig, err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return signingInfo{}, 0, err
+		}
+
+		return signingInfo{}, 0, fmt.Errorf("%w: %v", ErrCheckerUnavailable, err)
 	}
 	defer resp.Body.Close()
 
 	rb, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return false, err
+		return signingInfo{}, resp.StatusCode, fmt.Errorf("%w: %v", ErrCheckerUnavailable, err)
 	}
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return false, fmt.Errorf("response has status %q and body %q", resp.Status, string(rb))
+		return signingInfo{}, resp.StatusCode, classifyCheckerStatus(
+			resp.StatusCode,
+			fmt.Errorf("response has status %q and body %q", resp.Status, string(rb)),
+		)
 	}
 
-	type signingInfo struct {
-		Signed bool `json:"signed"`
-	}
 	var v struct {
 		Data signingInfo `json:"data"`
 	}
 
 	if err := json.Unmarshal(rb, &v); err != nil {
-		return false, fmt.Errorf("unmarshal failed: %s", err.Error())
+		return signingInfo{}, resp.StatusCode, fmt.Errorf("unmarshal failed: %s", err.Error())
 	}
 
-	return v.Data.Signed, nil
+	return v.Data, resp.StatusCode, nil
 }
 
-func deleteSignGuide(org string, repo string, number int32, c iClient) {
-	v, err := c.ListPRComments(org, repo, number)
-	if err != nil {
-		return
+// verifiedEmails returns the distinct emails which signed the CLA, masked according
+// to the config, and whether some emails are exempted by the maintainers.
+func verifiedEmails(results []agreementResult, cfg *botConfig) ([]string, bool) {
+	var emails []string
+	exempted := false
+	seen := sets.NewString()
+
+	for i := range results {
+		for _, item := range results[i].emails {
+			switch item.status {
+			case emailExempt:
+				exempted = true
+			case emailSigned:
+				if v := cfg.displayEmail(item.email); !seen.Has(v) {
+					seen.Insert(v)
+					emails = append(emails, coveredEmail(cfg.CommentLanguage, v, &item.signing))
+				}
+			}
+		}
 	}
 
-	prefix := signGuideTitle()
-	prefixOld := "Thanks for your pull request. Before we can look at your pull request, you'll need to sign a Contributor License Agreement (CLA)."
-	f := func(s string) bool {
-		return strings.HasPrefix(s, prefix) || strings.HasPrefix(s, prefixOld)
-	}
+	return emails, exempted
+}
 
-	for i := range v {
-		if item := &v[i]; f(item.Body) {
-			_ = c.DeletePRComment(org, repo, item.Id)
+func generateUnknownComment(cfg *botConfig, results []agreementResult) string {
+	cs := make([]string, 0, len(results))
+	for i := range results {
+		for _, item := range results[i].unknown {
+			cs = append(cs, fmt.Sprintf(
+				"**%s** | %s | %s", shortSHA(item.commit.Sha), cfg.displayEmail(item.email), item.reason.Error(),
+			))
 		}
 	}
+
+	return strings.Join(cs, "\n")
+}
+
+func labelsManagedNotice(lang, user string) string {
+	return fmt.Sprintf(message(lang, msgLabelsManaged), user)
 }
 
-func signGuideTitle() string {
-	return "Thanks for your pull request.\n\nThe authors of the following commits have not signed the Contributor License Agreement (CLA):"
+func claYesRevertedNotice(lang, user, label string) string {
+	return fmt.Sprintf(message(lang, msgCLAYesReverted), user, label)
 }
 
-func signGuide(signURL, cInfo, faq string) string {
-	s := `%s
+func manualOverrideNotice(lang, label, user string) string {
+	by := message(lang, msgAMaintainer)
+	if user != "" {
+		by = fmt.Sprintf("***@%s***", user)
+	}
+
+	return fmt.Sprintf(message(lang, msgManualOverride), by, label)
+}
 
-%s
+// claHelp generates the help message from the config of repo, so that the links are always correct.
+func claHelp(cfg *botConfig) string {
+	lang := cfg.CommentLanguage
 
-Please check the [**FAQs**](%s) first.
-You can click [**here**](%s) to sign the CLA. After signing the CLA, you must comment "/check-cla" to check the CLA status again.`
+	identity := message(lang, msgCLAHelpByAuthor)
+	if cfg.CheckByCommitter {
+		identity = message(lang, msgCLAHelpByCommitter)
+	}
 
-	return fmt.Sprintf(s, signGuideTitle(), cInfo, faq, signURL)
+	return fmt.Sprintf(
+		message(lang, msgCLAHelp), cfg.CLALabelYes, cfg.CLALabelNo, cfg.SignURL, cfg.FAQURL,
+		identity, strings.Join(commandHelps(cfg), "\n"),
+	)
 }
 
-func alreadySigned(user string) string {
-	s := `***@%s***, thanks for your pull request. All authors of the commits have signed the CLA. :wave: `
-	return fmt.Sprintf(s, user)
+func commandHelps(cfg *botConfig) []string {
+	lang := cfg.CommentLanguage
+
+	check := cmdCheckCLA
+	if len(cfg.CheckCLAAliases) > 0 {
+		check = fmt.Sprintf(message(lang, msgCmdCheckAliases), cmdCheckCLA, strings.Join(cfg.CheckCLAAliases, ", "))
+	}
+
+	return []string{
+		fmt.Sprintf(message(lang, msgCmdCheck), check),
+		fmt.Sprintf(message(lang, msgCmdCheckAll), cmdCheckCLA),
+		fmt.Sprintf(message(lang, msgCmdCheckEmail), cmdCheckCLA),
+		fmt.Sprintf(message(lang, msgCmdHelp), cmdCLAHelp),
+		fmt.Sprintf(message(lang, msgCmdStatus), cmdCLAStatus),
+		fmt.Sprintf(message(lang, msgCmdExemptEmail), cmdCLAExemptEmail),
+		fmt.Sprintf(message(lang, msgCmdExempt), cmdCLAExempt),
+	}
 }
 
-func generateUnSignComment(commits []*sdk.PullRequestCommits) string {
-	if len(commits) == 0 {
-		return ""
+func claStatusBreakdown(results []agreementResult, cfg *botConfig, branch string) string {
+	lang := cfg.CommentLanguage
+
+	source := message(lang, msgStatusRepoConfig)
+	if b := cfg.matchedBranch(branch); b != "" {
+		source = fmt.Sprintf(message(lang, msgStatusBranchConfig), b)
+	}
+
+	mode := message(lang, msgStatusByAuthor)
+	if cfg.CheckByCommitter {
+		mode = message(lang, msgStatusByCommitter)
+	}
+
+	parts := []string{
+		fmt.Sprintf(message(lang, msgStatusSummary), source, mode),
 	}
 
-	cs := make([]string, 0, len(commits))
-	for _, c := range commits {
-		msg := ""
-		if c.Commit != nil {
-			msg = c.Commit.Message
+	for i := range results {
+		item := &results[i]
+
+		rows := []string{message(lang, msgStatusTableHeader), "| --- | --- | --- |"}
+		for _, e := range item.emails {
+			shas := make([]string, 0, len(e.commits))
+			for _, c := range e.commits {
+				shas = append(shas, shortSHA(c.Sha))
+			}
+
+			status := emailStatusName(lang, e.status)
+			if corp := e.signing.coveredByCorporation(); corp != "" {
+				status = fmt.Sprintf(message(lang, msgStatusCorporate), status, corp)
+			}
+
+			rows = append(rows, fmt.Sprintf(
+				"| %s (%s) | %s | %s |",
+				cfg.displayEmail(e.email), roleName(lang, e.role), strings.Join(shas, ", "), status,
+			))
 		}
 
-		sha := c.Sha
-		if len(sha) > maxLengthOfSHA {
-			sha = sha[:maxLengthOfSHA]
+		parts = append(parts, fmt.Sprintf(
+			"**%s**:\n\n%s", item.agreement.displayName(), strings.Join(rows, "\n"),
+		))
+	}
+
+	return strings.Join(parts, "\n\n")
+}
+
+func emailCheckResult(lang, user, email string, signing *signingInfo, signURL string) string {
+	if signing.Signed {
+		if corp := signing.coveredByCorporation(); corp != "" {
+			return fmt.Sprintf(message(lang, msgEmailCovered), user, email, corp)
 		}
 
-		cs = append(cs, fmt.Sprintf("**%s** | %s", sha, msg))
+		return fmt.Sprintf(message(lang, msgEmailSigned), user, email)
 	}
 
-	return strings.Join(cs, "\n")
+	return fmt.Sprintf(message(lang, msgEmailUnsigned), user, email, signURL)
+}
+
+func emailExemptedNotice(lang, user, email string) string {
+	return fmt.Sprintf(message(lang, msgEmailExempted), user, email)
+}
+
+func exemptEmailAddedNotice(lang, user, email, reason string) string {
+	return fmt.Sprintf(message(lang, msgExemptEmailAdded), email, user, reason)
+}
+
+func exemptEmailRemovedNotice(lang, user, email string) string {
+	return fmt.Sprintf(message(lang, msgExemptEmailRemoved), email, user)
+}
+
+func exemptEmailNotFoundNotice(lang, user, email string) string {
+	return fmt.Sprintf(message(lang, msgExemptEmailNotFound), user, email)
+}
+
+func exemptEmailList(items []emailExemption, cfg *botConfig) string {
+	lang := cfg.CommentLanguage
+
+	if len(items) == 0 {
+		return message(lang, msgExemptEmailNone)
+	}
+
+	rows := []string{message(lang, msgExemptEmailList)}
+	for _, item := range items {
+		rows = append(rows, fmt.Sprintf(
+			"| %s | %s | %s | %s |",
+			cfg.displayEmail(item.Email), item.Reason, item.CreatedBy, item.CreatedAt.Format(time.RFC3339),
+		))
+	}
+
+	return strings.Join(rows, "\n")
+}
+
+func exemptEmailUsageNotice(lang, user string) string {
+	return fmt.Sprintf(message(lang, msgExemptEmailUsage), user)
+}
+
+func exemptEmailRefusedNotice(lang, user string) string {
+	return fmt.Sprintf(message(lang, msgExemptEmailRefused), user)
+}
+
+func exemptEmailDisabledNotice(lang, user string) string {
+	return fmt.Sprintf(message(lang, msgExemptEmailDisabled), user)
+}
+
+func exemptedNotice(lang, user, reason string) string {
+	return fmt.Sprintf(message(lang, msgExempted), user, reason)
+}
+
+func exemptRevokedNotice(lang, user string) string {
+	return fmt.Sprintf(message(lang, msgExemptRevoked), user)
+}
+
+func exemptRefusedNotice(lang, user string) string {
+	return fmt.Sprintf(message(lang, msgExemptRefused), user)
+}
+
+func exemptUsageNotice(lang, user string) string {
+	return fmt.Sprintf(message(lang, msgExemptUsage), user)
+}
+
+func closedPRNotice(lang string, merged bool) string {
+	if merged {
+		return message(lang, msgMergedPR)
+	}
+
+	return message(lang, msgClosedPR)
+}
+
+func permissionFailureNotice(lang, admins string) string {
+	s := message(lang, msgPermissionFailure)
+	if admins != "" {
+		s = admins + " " + s
+	}
+
+	return s
+}
+
+func checkingNotice(lang string) string {
+	return message(lang, msgChecking)
+}
+
+func checkRefusedNotice(lang, user string, roles []string) string {
+	return fmt.Sprintf(message(lang, msgCheckRefused), user, strings.Join(roles, ", "))
+}
+
+func checkInCooldownNotice(lang string, seconds int) string {
+	return fmt.Sprintf(message(lang, msgCheckInCooldown), seconds)
+}
+
+func minimizedComment(lang string) string {
+	return "~~" + message(lang, msgMinimizedComment) + "~~"
+}
+
+func outdatedCommentSummary(lang string) string {
+	return message(lang, msgOutdatedComment)
+}
+
+func legacyPRNoticeTitle(lang string) string {
+	return message(lang, msgLegacyPRTitle)
+}
+
+func legacyPRNotice(lang, enforceAfter string) string {
+	return fmt.Sprintf(message(lang, msgLegacyPR), legacyPRNoticeTitle(lang), enforceAfter)
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > maxLengthOfSHA {
+		return sha[:maxLengthOfSHA]
+	}
+
+	return sha
 }
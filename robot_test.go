@@ -0,0 +1,985 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	sdk "github.com/opensourceways/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+)
+
+func TestIsPermissionError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "no error", err: nil, want: false},
+		{name: "unauthorized", err: giteeStatusError(http.StatusUnauthorized), want: true},
+		{name: "forbidden", err: giteeStatusError(http.StatusForbidden), want: true},
+		{name: "wrapped forbidden", err: fmt.Errorf("add label: %w", giteeStatusError(http.StatusForbidden)), want: true},
+		{name: "not found", err: giteeStatusError(http.StatusNotFound), want: false},
+		{name: "status in text only", err: errors.New("the PR #403 is forbidden"), want: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isPermissionError(tc.err); got != tc.want {
+				t.Fatalf("expect %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestIsNotFoundError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "no error", err: nil, want: false},
+		{name: "not found", err: giteeStatusError(http.StatusNotFound), want: true},
+		{name: "wrapped not found", err: fmt.Errorf("get label: %w", giteeStatusError(http.StatusNotFound)), want: true},
+		{name: "forbidden", err: giteeStatusError(http.StatusForbidden), want: false},
+		{name: "status in text only", err: errors.New("label 404 not found"), want: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isNotFoundError(tc.err); got != tc.want {
+				t.Fatalf("expect %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestGenerateUnknownCommentDisplayEmail(t *testing.T) {
+	results := []agreementResult{{
+		unknown: []unknownCommit{{
+			commit: &sdk.PullRequestCommits{Sha: "1234abcd5678"},
+			email:  "alice@example.com",
+			reason: errors.New("timeout"),
+		}},
+	}}
+
+	cases := []struct {
+		name string
+		mask bool
+		want string
+	}{
+		{name: "plain", mask: false, want: "**1234abcd** | alice@example.com | timeout"},
+		{name: "masked", mask: true, want: "**1234abcd** | a***@example.com | timeout"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := generateUnknownComment(&botConfig{MaskEmails: tc.mask}, results); got != tc.want {
+				t.Fatalf("expect %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+// fakeClient serves the PR from the fields. The methods which are not
+// overridden panic, so that the unexpected calls are caught.
+type fakeClient struct {
+	iClient
+
+	labels   []sdk.Label
+	comments []sdk.PullRequestComments
+	repos    []sdk.Project
+	commits  []sdk.PullRequestCommits
+	pr       sdk.PullRequest
+
+	// ops records the mutations of comments in order, such as "update 2".
+	ops []string
+	// bodies are the contents of comments created or updated, keyed by id.
+	bodies map[int32]string
+	// newID is the id of comment created.
+	newID int32
+	// listedPages counts the pages of comments listed.
+	listedPages int
+
+	// labelOps records the mutations of labels in order, such as "add cla/yes".
+	labelOps []string
+	// removeFailures is the number of times removing label fails before it succeeds.
+	removeFailures int
+	// addErr is the error of adding label.
+	addErr error
+	// collaborators are the logins of collaborators of the repo.
+	collaborators []string
+	// commitsByCall are the commits returned by the calls in turn. The last
+	// one is repeated, and commits is used if it is empty.
+	commitsByCall [][]sdk.PullRequestCommits
+	commitCalls   int
+	// prErr is the error of getting the PR.
+	prErr error
+	// missingLabels are the labels which don't exist in the repo until created.
+	missingLabels map[string]bool
+	// closed tells whether the PR is closed.
+	closed bool
+}
+
+func (c *fakeClient) GetPRLabels(ctx context.Context, org, repo string, number int32) ([]sdk.Label, error) {
+	return c.labels, nil
+}
+
+func (c *fakeClient) AddPRLabel(ctx context.Context, org, repo string, number int32, label string) error {
+	c.labelOps = append(c.labelOps, "add "+label)
+	if c.addErr != nil {
+		return c.addErr
+	}
+
+	if c.missingLabels[label] {
+		return giteeStatusError(http.StatusNotFound)
+	}
+
+	c.labels = append(c.labels, sdk.Label{Name: label})
+
+	return nil
+}
+
+func (c *fakeClient) CreateRepoLabel(ctx context.Context, org, repo, label, color string) error {
+	c.labelOps = append(c.labelOps, "create "+label)
+	delete(c.missingLabels, label)
+
+	return nil
+}
+
+func (c *fakeClient) ClosePR(ctx context.Context, org, repo string, number int32) error {
+	c.closed = true
+
+	return nil
+}
+
+func (c *fakeClient) RemovePRLabel(ctx context.Context, org, repo string, number int32, label string) error {
+	c.labelOps = append(c.labelOps, "remove "+label)
+
+	if c.removeFailures > 0 {
+		c.removeFailures--
+
+		return giteeStatusError(http.StatusBadGateway)
+	}
+
+	for i := range c.labels {
+		if c.labels[i].Name == label {
+			c.labels = append(c.labels[:i:i], c.labels[i+1:]...)
+
+			break
+		}
+	}
+
+	return nil
+}
+
+func (c *fakeClient) IsCollaborator(ctx context.Context, owner, repo, login string) (bool, error) {
+	for _, v := range c.collaborators {
+		if v == login {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (c *fakeClient) CreatePRComment(ctx context.Context, org, repo string, number int32, comment string) error {
+	_, err := c.CreatePRCommentWithID(ctx, org, repo, number, comment)
+
+	return err
+}
+
+func (c *fakeClient) ListPRCommentsByPage(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	page, perPage int,
+) ([]sdk.PullRequestComments, error) {
+	c.listedPages++
+
+	start := (page - 1) * perPage
+	if start >= len(c.comments) {
+		return nil, nil
+	}
+
+	end := start + perPage
+	if end > len(c.comments) {
+		end = len(c.comments)
+	}
+
+	return c.comments[start:end], nil
+}
+
+func (c *fakeClient) GetPRCommits(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+) ([]sdk.PullRequestCommits, error) {
+	c.commitCalls++
+
+	if n := len(c.commitsByCall); n > 0 {
+		if c.commitCalls < n {
+			return c.commitsByCall[c.commitCalls-1], nil
+		}
+
+		return c.commitsByCall[n-1], nil
+	}
+
+	return c.commits, nil
+}
+
+func (c *fakeClient) GetRepos(ctx context.Context, org string) ([]sdk.Project, error) {
+	return c.repos, nil
+}
+
+func (c *fakeClient) GetGiteePullRequest(ctx context.Context, org, repo string, number int32) (sdk.PullRequest, error) {
+	return c.pr, c.prErr
+}
+
+func (c *fakeClient) CreatePRCommentWithID(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	comment string,
+) (int32, error) {
+	c.record(fmt.Sprintf("create %d", c.newID), c.newID, comment)
+
+	return c.newID, nil
+}
+
+func (c *fakeClient) UpdatePRComment(ctx context.Context, org, repo string, commentID int32, comment string) error {
+	c.record(fmt.Sprintf("update %d", commentID), commentID, comment)
+
+	return nil
+}
+
+func (c *fakeClient) DeletePRComment(ctx context.Context, org, repo string, ID int32) error {
+	c.ops = append(c.ops, fmt.Sprintf("delete %d", ID))
+
+	for i := range c.comments {
+		if c.comments[i].Id == ID {
+			c.comments = append(c.comments[:i:i], c.comments[i+1:]...)
+
+			break
+		}
+	}
+
+	return nil
+}
+
+func (c *fakeClient) record(op string, id int32, body string) {
+	if c.bodies == nil {
+		c.bodies = map[int32]string{}
+	}
+
+	c.ops = append(c.ops, op)
+	c.bodies[id] = body
+}
+
+// fakeChecker serves the CLA service, which fails for the emails starting with
+// "broken" and finds the ones starting with "signed" signed.
+func fakeChecker() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		email := r.URL.Query().Get("email")
+
+		switch {
+		case strings.HasPrefix(email, "broken"):
+			w.WriteHeader(http.StatusInternalServerError)
+		case strings.HasPrefix(email, "signed"):
+			w.Write([]byte(`{"data": {"signed": true}}`))
+		default:
+			w.Write([]byte(`{"data": {"signed": false}}`))
+		}
+	}))
+}
+
+func commitsOf(emails ...string) []sdk.PullRequestCommits {
+	r := make([]sdk.PullRequestCommits, len(emails))
+	for i, email := range emails {
+		r[i] = sdk.PullRequestCommits{
+			Sha:    fmt.Sprintf("%08d", i),
+			Commit: &sdk.GitCommit{Author: &sdk.GitUser{Email: email}},
+		}
+	}
+
+	return r
+}
+
+func TestGetPRCommitsAbout(t *testing.T) {
+	s := fakeChecker()
+	defer s.Close()
+
+	cases := []struct {
+		name         string
+		emails       []string
+		wantSigned   int
+		wantUnsigned int
+		wantUnknown  int
+	}{
+		{name: "all signed", emails: []string{"signed1@a.com", "signed2@a.com"}, wantSigned: 2},
+		{name: "signed and unsigned", emails: []string{"signed@a.com", "alice@a.com"}, wantSigned: 1, wantUnsigned: 1},
+		{name: "signed and unknown", emails: []string{"signed@a.com", "broken@a.com"}, wantSigned: 1, wantUnknown: 1},
+		{name: "unsigned and unknown", emails: []string{"alice@a.com", "broken@a.com"}, wantUnsigned: 1, wantUnknown: 1},
+		{
+			name:         "all the buckets",
+			emails:       []string{"signed@a.com", "alice@a.com", "broken@a.com", "broken@a.com", "invalid"},
+			wantSigned:   1,
+			wantUnsigned: 2,
+			wantUnknown:  2,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			bot := newRobot(&fakeClient{commits: commitsOf(tc.emails...)}, "bot", nil, nil, 0, 0)
+			cfg := &botConfig{CheckURL: s.URL}
+
+			results, err := bot.getPRCommitsAbout(
+				context.Background(), "org", "repo", 1, cfg, []agreementConfig{{CheckURL: s.URL}},
+			)
+			if err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+
+			r := &results[0]
+			if len(r.signed) != tc.wantSigned || len(r.unsigned) != tc.wantUnsigned || len(r.unknown) != tc.wantUnknown {
+				t.Fatalf(
+					"expect %d signed, %d unsigned and %d unknown, got %d, %d and %d",
+					tc.wantSigned, tc.wantUnsigned, tc.wantUnknown, len(r.signed), len(r.unsigned), len(r.unknown),
+				)
+			}
+
+			// The PR is labeled cla/no once any commit is unsigned, and the
+			// unknown ones matter only if there is no unsigned commit.
+			if got := len(filterUnsigned(results)) > 0; got != (tc.wantUnsigned > 0) {
+				t.Fatalf("expect unsigned: %v, got %v", tc.wantUnsigned > 0, got)
+			}
+
+			if got := len(filterUnknown(results)) > 0; got != (tc.wantUnknown > 0) {
+				t.Fatalf("expect unknown: %v, got %v", tc.wantUnknown > 0, got)
+			}
+		})
+	}
+}
+
+// labelsOf returns the labels of the names.
+func labelsOf(names ...string) []sdk.Label {
+	r := make([]sdk.Label, len(names))
+	for i, name := range names {
+		r[i] = sdk.Label{Name: name}
+	}
+
+	return r
+}
+
+// newTestConfig returns the config checking the CLA by the service at checkURL.
+func newTestConfig(checkURL string) *botConfig {
+	cfg := &botConfig{
+		CLALabelYes: "cla/yes",
+		CLALabelNo:  "cla/no",
+		CheckURL:    checkURL,
+		SignURL:     "https://example.com/sign",
+		FAQURL:      "https://example.com/faq",
+	}
+	cfg.setDefault()
+
+	return cfg
+}
+
+// openPR returns the open PR whose head is sha, as the API and the webhook see it.
+func openPR(number int32, sha string) (sdk.PullRequest, *sdk.PullRequestHook) {
+	pr := sdk.PullRequest{
+		Number: number,
+		State:  "open",
+		Head:   &sdk.BranchBasic{Sha: sha, Ref: "feature"},
+		Base:   &sdk.BranchBasic{Ref: "master"},
+		User:   &sdk.UserBasic{Login: "alice"},
+	}
+
+	return pr, toPullRequestHook(&pr)
+}
+
+func testLog() *logrus.Entry {
+	return logrus.WithField("test", true)
+}
+
+func TestHandleUsesLiveLabels(t *testing.T) {
+	s := fakeChecker()
+	defer s.Close()
+
+	cases := []struct {
+		name       string
+		payload    []string
+		live       []string
+		emails     []string
+		wantOps    []string
+		wantLabels []string
+	}{
+		{
+			name:       "signed but the payload is unlabeled",
+			payload:    nil,
+			live:       []string{"cla/yes"},
+			emails:     []string{"signed@a.com"},
+			wantLabels: []string{"cla/yes"},
+		},
+		{
+			name:       "signed but the payload is stale",
+			payload:    []string{"cla/yes"},
+			live:       []string{"cla/no"},
+			emails:     []string{"signed@a.com"},
+			wantOps:    []string{"remove cla/no", "add cla/yes"},
+			wantLabels: []string{"cla/yes"},
+		},
+		{
+			name:       "unsigned but the payload is stale",
+			payload:    []string{"cla/no"},
+			live:       []string{"cla/yes"},
+			emails:     []string{"alice@a.com"},
+			wantOps:    []string{"remove cla/yes", "add cla/no"},
+			wantLabels: []string{"cla/no"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pr, hook := openPR(1, "sha")
+			for _, l := range tc.payload {
+				hook.Labels = append(hook.Labels, sdk.LabelHook{Name: l})
+			}
+
+			cli := &fakeClient{pr: pr, labels: labelsOf(tc.live...), commits: commitsOf(tc.emails...)}
+			bot := newRobot(cli, "bot", nil, nil, 0, 0)
+
+			if err := bot.handle(context.Background(), "org", "repo", hook, newTestConfig(s.URL), nil, testLog()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(cli.labelOps, tc.wantOps) {
+				t.Fatalf("expect label ops %v, got %v", tc.wantOps, cli.labelOps)
+			}
+
+			if got := labelNames(cli.labels); !reflect.DeepEqual(got, tc.wantLabels) {
+				t.Fatalf("expect labels %v, got %v", tc.wantLabels, got)
+			}
+		})
+	}
+}
+
+func labelNames(labels []sdk.Label) []string {
+	r := make([]string, len(labels))
+	for i := range labels {
+		r[i] = labels[i].Name
+	}
+
+	return r
+}
+
+func TestHandleConvergesDualLabels(t *testing.T) {
+	s := fakeChecker()
+	defer s.Close()
+
+	cases := []struct {
+		name           string
+		emails         []string
+		removeFailures int
+		wantErr        bool
+		wantOps        []string
+		wantLabels     []string
+	}{
+		{
+			name:       "signed",
+			emails:     []string{"signed@a.com"},
+			wantOps:    []string{"remove cla/no"},
+			wantLabels: []string{"cla/yes"},
+		},
+		{
+			name:       "unsigned",
+			emails:     []string{"alice@a.com"},
+			wantOps:    []string{"remove cla/yes"},
+			wantLabels: []string{"cla/no"},
+		},
+		{
+			name:           "signed and removal retried",
+			emails:         []string{"signed@a.com"},
+			removeFailures: 1,
+			wantOps:        []string{"remove cla/no", "remove cla/no"},
+			wantLabels:     []string{"cla/yes"},
+		},
+		{
+			name:           "unsigned and removal retried",
+			emails:         []string{"alice@a.com"},
+			removeFailures: 1,
+			wantOps:        []string{"remove cla/yes", "remove cla/yes"},
+			wantLabels:     []string{"cla/no"},
+		},
+		{
+			name:           "removal failed after retry",
+			emails:         []string{"signed@a.com"},
+			removeFailures: 2,
+			wantErr:        true,
+			wantOps:        []string{"remove cla/no", "remove cla/no"},
+			wantLabels:     []string{"cla/yes", "cla/no"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pr, hook := openPR(1, "sha")
+			cli := &fakeClient{
+				pr:             pr,
+				labels:         labelsOf("cla/yes", "cla/no"),
+				commits:        commitsOf(tc.emails...),
+				removeFailures: tc.removeFailures,
+			}
+			bot := newRobot(cli, "bot", nil, nil, 0, 0)
+
+			var buf bytes.Buffer
+			logger := logrus.New()
+			logger.SetOutput(&buf)
+			logger.SetFormatter(&logrus.JSONFormatter{})
+
+			err := bot.handle(context.Background(), "org", "repo", hook, newTestConfig(s.URL), nil, logrus.NewEntry(logger))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expect error: %v, got %v", tc.wantErr, err)
+			}
+
+			if !reflect.DeepEqual(cli.labelOps, tc.wantOps) {
+				t.Fatalf("expect label ops %v, got %v", tc.wantOps, cli.labelOps)
+			}
+
+			if got := labelNames(cli.labels); !reflect.DeepEqual(got, tc.wantLabels) {
+				t.Fatalf("expect labels %v, got %v", tc.wantLabels, got)
+			}
+
+			if !strings.Contains(buf.String(), `"conflict":"dual-labels"`) {
+				t.Fatalf("expect the conflict logged, got %s", buf.String())
+			}
+		})
+	}
+}
+
+func TestHandleRetargetedPR(t *testing.T) {
+	lenient := fakeChecker()
+	defer lenient.Close()
+
+	// The agreement of release branches is signed by nobody.
+	strict := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"signed": false}}`))
+	}))
+	defer strict.Close()
+
+	cfg := newTestConfig(lenient.URL)
+	cfg.Repos = []string{"org/repo"}
+	cfg.Branches = []branchConfig{{Branch: "release-*", CheckURL: strict.URL}}
+
+	cases := []struct {
+		name       string
+		base       string
+		wantOps    []string
+		wantLabels []string
+	}{
+		{
+			name:       "retarget to the branch of same agreement",
+			base:       "develop",
+			wantLabels: []string{"cla/yes"},
+		},
+		{
+			name:       "retarget to the branch of other agreement",
+			base:       "release-1.0",
+			wantOps:    []string{"remove cla/yes", "add cla/no"},
+			wantLabels: []string{"cla/no"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pr, hook := openPR(1, "sha")
+			pr.Base.Ref = tc.base
+			hook.Base.Ref = tc.base
+
+			cli := &fakeClient{pr: pr, labels: labelsOf("cla/yes"), commits: commitsOf("signed@a.com")}
+			bot := newRobot(cli, "bot", nil, nil, 0, 0)
+
+			action, desc := "update", "target_branch_changed"
+			e := &sdk.PullRequestEvent{
+				Action:      &action,
+				ActionDesc:  &desc,
+				PullRequest: hook,
+				Repository:  &sdk.ProjectHook{Namespace: "org", Path: "repo"},
+			}
+
+			c := &configuration{ConfigItems: []botConfig{*cfg}}
+			if err := bot.handlePRAction(context.Background(), e, c, testLog()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(cli.labelOps, tc.wantOps) {
+				t.Fatalf("expect label ops %v, got %v", tc.wantOps, cli.labelOps)
+			}
+
+			if got := labelNames(cli.labels); !reflect.DeepEqual(got, tc.wantLabels) {
+				t.Fatalf("expect labels %v, got %v", tc.wantLabels, got)
+			}
+		})
+	}
+}
+
+func TestHandleCheckEmailCommand(t *testing.T) {
+	s := fakeChecker()
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "check-email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	exemptions, err := newExemptionStore(filepath.Join(dir, "exemptions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := exemptions.add("org", "repo", emailExemption{Email: "exempt@a.com", Reason: "bot"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, pr := openPR(1, "sha")
+
+	cases := []struct {
+		name      string
+		roles     []string
+		commenter string
+		email     string
+		want      string
+	}{
+		{name: "signed", commenter: "bob", email: "signed@a.com", want: "has signed the CLA"},
+		{name: "unsigned", commenter: "bob", email: "carol@a.com", want: "has not signed the CLA"},
+		{name: "exempted", commenter: "bob", email: "exempt@a.com", want: "is exempted from signing the CLA"},
+		{name: "check failed", commenter: "bob", email: "broken@a.com", want: "can't be checked at the moment"},
+		{
+			name: "collaborator", roles: []string{checkRoleCollaborator},
+			commenter: "bob", email: "signed@a.com", want: "has signed the CLA",
+		},
+		{
+			name: "author", roles: []string{checkRoleCollaborator},
+			commenter: "alice", email: "signed@a.com", want: "has signed the CLA",
+		},
+		{
+			// The same roles as "/check-cla" are required.
+			name: "refused", roles: []string{checkRoleCollaborator},
+			commenter: "dave", email: "signed@a.com", want: "can trigger the CLA check",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := &fakeClient{collaborators: []string{"bob"}}
+			bot := newRobot(cli, "bot", exemptions, nil, 0, 0)
+
+			cfg := newTestConfig(s.URL)
+			if tc.roles != nil {
+				cfg.AllowedCheckRoles = tc.roles
+			}
+
+			if err := bot.handleCheckEmailCommand(
+				context.Background(), "org", "repo", pr, cfg, tc.commenter, tc.email, testLog(),
+			); err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+
+			if got := cli.bodies[cli.newID]; !strings.Contains(got, tc.want) {
+				t.Fatalf("expect the reply to contain %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+// noteEvent returns the event of comment on the PR.
+func noteEvent(login, userType, body string, pr *sdk.PullRequestHook) *sdk.NoteEvent {
+	action, kind := "comment", "PullRequest"
+
+	return &sdk.NoteEvent{
+		Action:       &action,
+		NoteableType: &kind,
+		Comment:      &sdk.NoteHook{Body: body, User: &sdk.UserHook{Login: login, Type_: userType}},
+		PullRequest:  pr,
+		Repository:   &sdk.ProjectHook{Namespace: "org", Path: "repo"},
+	}
+}
+
+func TestHandleNoteEventIgnoresBots(t *testing.T) {
+	s := fakeChecker()
+	defer s.Close()
+
+	cfg := newTestConfig(s.URL)
+	cfg.Repos = []string{"org/repo"}
+	cfg.IgnoredCommenters = []string{"ci-bot"}
+
+	quoted := "To trigger the check, comment:\n/check-cla"
+
+	cases := []struct {
+		name      string
+		login     string
+		userType  string
+		wantCheck bool
+	}{
+		{name: "robot itself", login: "bot"},
+		{name: "ignored commenter", login: "ci-bot"},
+		{name: "marked as bot by gitee", login: "other-bot", userType: "Bot"},
+		{name: "human", login: "alice", wantCheck: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pr, hook := openPR(1, "sha")
+			cli := &fakeClient{pr: pr, commits: commitsOf("signed@a.com")}
+			bot := newRobot(cli, "bot", nil, nil, 0, 0)
+
+			e := noteEvent(tc.login, tc.userType, quoted, hook)
+			c := &configuration{ConfigItems: []botConfig{*cfg}}
+
+			if err := bot.handleNoteEvent(e, c, testLog()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if checked := len(cli.labelOps) > 0; checked != tc.wantCheck {
+				t.Fatalf("expect checked: %v, got label ops %v", tc.wantCheck, cli.labelOps)
+			}
+
+			if !tc.wantCheck && len(cli.ops) > 0 {
+				t.Fatalf("expect no comment, got %v", cli.ops)
+			}
+		})
+	}
+}
+
+func TestHandleAggregatesMutationErrors(t *testing.T) {
+	s := fakeChecker()
+	defer s.Close()
+
+	cases := []struct {
+		name        string
+		emails      []string
+		labels      []string
+		wantErr     []string
+		wantComment string
+	}{
+		{
+			name:        "unsigned",
+			emails:      []string{"alice@a.com"},
+			labels:      []string{"cla/yes"},
+			wantErr:     []string{"add label cla/no"},
+			wantComment: markerSignGuide,
+		},
+		{
+			name:        "signed",
+			emails:      []string{"signed@a.com"},
+			wantErr:     []string{"add label cla/yes"},
+			wantComment: markerAlreadySigned,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pr, hook := openPR(1, "sha")
+			cli := &fakeClient{
+				pr:      pr,
+				labels:  labelsOf(tc.labels...),
+				commits: commitsOf(tc.emails...),
+				addErr:  giteeStatusError(http.StatusBadGateway),
+			}
+			bot := newRobot(cli, "bot", nil, nil, 0, 0)
+
+			err := bot.handle(context.Background(), "org", "repo", hook, newTestConfig(s.URL), nil, testLog())
+			if err == nil {
+				t.Fatal("expect the failure of adding label returned")
+			}
+
+			for _, v := range tc.wantErr {
+				if !strings.Contains(err.Error(), v) {
+					t.Fatalf("expect %q in the error, got %v", v, err)
+				}
+			}
+
+			// The remaining steps are attempted regardless of the failure.
+			posted := false
+			for _, body := range cli.bodies {
+				if hasMarker(body, tc.wantComment) {
+					posted = true
+				}
+			}
+
+			if !posted {
+				t.Fatalf("expect the %s comment posted, got %v", tc.wantComment, cli.ops)
+			}
+		})
+	}
+}
+
+func TestMutationErrorsJoin(t *testing.T) {
+	e1, e2, e3 := errors.New("add label"), errors.New("remove label"), errors.New("comment")
+
+	cases := []struct {
+		name string
+		errs []error
+		last error
+		want []error
+	}{
+		{name: "none"},
+		{name: "only last", last: e3, want: []error{e3}},
+		{name: "mutations", errs: []error{e1, nil, e2}, want: []error{e1, e2}},
+		{name: "mutations and last", errs: []error{e1, e2}, last: e3, want: []error{e1, e2, e3}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var m mutationErrors
+			for _, err := range tc.errs {
+				m.add(err)
+			}
+
+			err := m.join(tc.last)
+			if len(tc.want) == 0 {
+				if err != nil {
+					t.Fatalf("expect no error, got %v", err)
+				}
+
+				return
+			}
+
+			for _, v := range tc.want {
+				if !errors.Is(err, v) {
+					t.Fatalf("expect %v in %v", v, err)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleEmptyCommits(t *testing.T) {
+	s := fakeChecker()
+	defer s.Close()
+
+	defer func(v time.Duration) { emptyCommitsRetryDelay = v }(emptyCommitsRetryDelay)
+	emptyCommitsRetryDelay = time.Millisecond
+
+	cases := []struct {
+		name        string
+		calls       [][]sdk.PullRequestCommits
+		wantCalls   int
+		wantOps     []string
+		wantComment string
+	}{
+		{
+			name:        "populated at once",
+			calls:       [][]sdk.PullRequestCommits{commitsOf("signed@a.com")},
+			wantCalls:   1,
+			wantOps:     []string{"remove cla/no", "add cla/yes"},
+			wantComment: markerAlreadySigned,
+		},
+		{
+			name:        "empty then populated",
+			calls:       [][]sdk.PullRequestCommits{nil, commitsOf("signed@a.com")},
+			wantCalls:   2,
+			wantOps:     []string{"remove cla/no", "add cla/yes"},
+			wantComment: markerAlreadySigned,
+		},
+		{
+			name:        "still empty and the labels untouched",
+			calls:       [][]sdk.PullRequestCommits{nil, nil, commitsOf("signed@a.com")},
+			wantCalls:   2,
+			wantComment: markerCheckFailed,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pr, hook := openPR(1, "sha")
+			cli := &fakeClient{pr: pr, labels: labelsOf("cla/no"), commitsByCall: tc.calls}
+			bot := newRobot(cli, "bot", nil, nil, 0, 0)
+
+			if err := bot.handle(context.Background(), "org", "repo", hook, newTestConfig(s.URL), nil, testLog()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if cli.commitCalls != tc.wantCalls {
+				t.Fatalf("expect %d fetches of commits, got %d", tc.wantCalls, cli.commitCalls)
+			}
+
+			if !reflect.DeepEqual(cli.labelOps, tc.wantOps) {
+				t.Fatalf("expect label ops %v, got %v", tc.wantOps, cli.labelOps)
+			}
+
+			posted := false
+			for _, body := range cli.bodies {
+				posted = posted || hasMarker(body, tc.wantComment)
+			}
+
+			if !posted {
+				t.Fatalf("expect the %s comment posted, got %v", tc.wantComment, cli.bodies)
+			}
+		})
+	}
+}
+
+func TestHandleNoteEventWithoutPR(t *testing.T) {
+	s := fakeChecker()
+	defer s.Close()
+
+	cfg := newTestConfig(s.URL)
+	cfg.Repos = []string{"org/repo"}
+
+	cases := []struct {
+		name    string
+		pr      *sdk.PullRequestHook
+		url     string
+		prErr   error
+		wantErr bool
+	}{
+		{name: "nil pr", url: "https://gitee.com/org/repo/pulls/1#note_2"},
+		{name: "pr lacking the branches", pr: &sdk.PullRequestHook{Number: 1}},
+		{name: "nil pr and no number", wantErr: true},
+		{
+			name:    "nil pr and fetching failed",
+			url:     "https://gitee.com/org/repo/pulls/1#note_2",
+			prErr:   giteeStatusError(http.StatusNotFound),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pr, _ := openPR(1, "sha")
+			cli := &fakeClient{pr: pr, prErr: tc.prErr, commits: commitsOf("signed@a.com")}
+			bot := newRobot(cli, "bot", nil, nil, 0, 0)
+
+			e := noteEvent("alice", "", "/check-cla", tc.pr)
+			e.Comment.HtmlUrl = tc.url
+			c := &configuration{ConfigItems: []botConfig{*cfg}}
+
+			err := bot.handleNoteEvent(e, c, testLog())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expect error: %v, got %v", tc.wantErr, err)
+			}
+
+			wantOps := []string{"add cla/yes"}
+			if tc.wantErr {
+				wantOps = nil
+			}
+
+			if !reflect.DeepEqual(cli.labelOps, wantOps) {
+				t.Fatalf("expect label ops %v, got %v", wantOps, cli.labelOps)
+			}
+		})
+	}
+}
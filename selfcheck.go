@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// selfCheckProbePace is the min interval between two probes of CLA
+// services, so that a large config will not hammer them.
+const selfCheckProbePace = 500 * time.Millisecond
+
+// selfCheckResult is the result of an item of the self-check.
+type selfCheckResult struct {
+	target string
+	check  string
+	err    error
+}
+
+// selfCheck verifies that the token can read every repo in the config, the
+// cla labels exist or can be created, and the CLA services answer. The
+// probes of CLA services are cached per host by the readiness.
+func (bot *robot) selfCheck(ctx context.Context, c *configuration, ready *readiness) []selfCheckResult {
+	log := logrus.WithField("self_check", true)
+
+	var r []selfCheckResult
+	seen := sets.NewString()
+
+	for i := range c.ConfigItems {
+		item := &c.ConfigItems[i]
+
+		for _, orgRepo := range bot.sweptRepos(ctx, item, log) {
+			org, repo := orgRepo[0], orgRepo[1]
+
+			// The repo is checked against the first config item covering it.
+			if seen.Has(org+"/"+repo) || c.configFor(org, repo) != item {
+				continue
+			}
+			seen.Insert(org + "/" + repo)
+
+			r = append(r, bot.selfCheckRepo(ctx, org, repo, item)...)
+		}
+	}
+
+	urls := checkerURLs(c)
+
+	hosts := make([]string, 0, len(urls))
+	for host := range urls {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	pace := time.NewTicker(selfCheckProbePace)
+	defer pace.Stop()
+
+	for i, host := range hosts {
+		if i > 0 {
+			<-pace.C
+		}
+
+		r = append(r, selfCheckResult{
+			target: host,
+			check:  "probe cla service",
+			err:    ready.probe(host, urls[host]),
+		})
+	}
+
+	return r
+}
+
+func (bot *robot) selfCheckRepo(ctx context.Context, org, repo string, cfg *botConfig) []selfCheckResult {
+	target := org + "/" + repo
+
+	if _, err := bot.cli.ListOpenPRsByPage(ctx, org, repo, 1, 1); err != nil {
+		return []selfCheckResult{{target: target, check: "read repo", err: err}}
+	}
+
+	r := []selfCheckResult{{target: target, check: "read repo"}}
+
+	if !cfg.labelEnabled() {
+		return r
+	}
+
+	labels, err := bot.cli.GetRepoLabels(ctx, org, repo)
+	if err != nil {
+		return append(r, selfCheckResult{target: target, check: "list labels", err: err})
+	}
+
+	existing := sets.NewString()
+	for i := range labels {
+		existing.Insert(labels[i].Name)
+	}
+
+	missing := cfgLabels(cfg).Difference(existing)
+	if missing.Len() == 0 {
+		return append(r, selfCheckResult{target: target, check: "cla labels exist"})
+	}
+
+	check := fmt.Sprintf("create missing labels %v", missing.List())
+
+	v, err := bot.cli.GetUserPermissionsOfRepo(ctx, org, repo, bot.botLogin)
+	if err == nil && !canCreateLabel(v.Permission) {
+		err = fmt.Errorf("the permission of robot is %q", v.Permission)
+	}
+
+	return append(r, selfCheckResult{target: target, check: check, err: err})
+}
+
+// cfgLabels returns the cla labels of the config and its branch overrides.
+func cfgLabels(cfg *botConfig) sets.String {
+	r := sets.NewString()
+	add := func(c *botConfig) {
+		for _, l := range []string{c.CLALabelYes, c.CLALabelNo, c.CLALabelError, c.CLALabelChecking} {
+			if l != "" {
+				r.Insert(l)
+			}
+		}
+	}
+
+	add(cfg)
+	for i := range cfg.Branches {
+		add(cfg.configForBranch(cfg.Branches[i].Branch))
+	}
+
+	return r
+}
+
+func canCreateLabel(permission string) bool {
+	return isMaintainerPermission(permission) || permission == "write"
+}
+
+// runSelfCheck runs the self-check and logs the results as a table. It
+// returns the number of failures.
+func (bot *robot) runSelfCheck(ctx context.Context, ready *readiness) int {
+	c, ok := bot.latestConfig.Load().(*configuration)
+	if !ok {
+		logrus.WithError(errConfigNotLoaded).Error("Could not run the self-check.")
+
+		return 1
+	}
+
+	results := bot.selfCheck(ctx, c, ready)
+
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tCHECK\tRESULT")
+
+	failed := 0
+	for _, item := range results {
+		result := "ok"
+		if item.err != nil {
+			result = "FAILED: " + item.err.Error()
+			failed++
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\n", item.target, item.check, result)
+	}
+	w.Flush()
+
+	log := logrus.WithFields(logrus.Fields{
+		"checked": len(results),
+		"failed":  failed,
+	})
+	if failed > 0 {
+		log.Warning("Self-check found problems:\n" + b.String())
+	} else {
+		log.Info("Self-check passed:\n" + b.String())
+	}
+
+	return failed
+}
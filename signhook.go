@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opensourceways/community-robot-lib/utils"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// signatureHeader carries the HMAC-SHA256 of body signed by the shared
+	// secret, in the format of "sha256=<hex>".
+	signatureHeader = "X-Signature"
+
+	// maxSigningScanPRs bounds the PRs re-checked by the scan when the
+	// signed email is not in the index.
+	maxSigningScanPRs = 200
+
+	maxSigningBodyBytes = 1 << 16
+)
+
+// prRef refers to a PR.
+type prRef struct {
+	Org    string `json:"org"`
+	Repo   string `json:"repo"`
+	Number int32  `json:"number"`
+}
+
+// emailPRIndex records the open PRs which have commits unsigned by the email,
+// so that they can be re-checked once the email signs.
+type emailPRIndex struct {
+	mu sync.Mutex
+	// items maps the lower-cased email to []prRef.
+	items *ttlCache
+}
+
+func newEmailPRIndex() *emailPRIndex {
+	return &emailPRIndex{items: newTTLCache(claStateTTL)}
+}
+
+func (x *emailPRIndex) add(email string, pr prRef) {
+	key := strings.ToLower(email)
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	var prs []prRef
+	if v, ok := x.items.get(key); ok {
+		prs = v.([]prRef)
+	}
+
+	for _, item := range prs {
+		if item == pr {
+			return
+		}
+	}
+
+	x.items.set(key, append(append([]prRef(nil), prs...), pr))
+}
+
+// take removes the PRs of email from the index and returns them.
+func (x *emailPRIndex) take(email string) []prRef {
+	key := strings.ToLower(email)
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	v, ok := x.items.get(key)
+	if !ok {
+		return nil
+	}
+
+	x.items.remove(key)
+
+	return v.([]prRef)
+}
+
+// indexUnsigned records the PR to the index of each email which has not signed.
+func (bot *robot) indexUnsigned(org, repo string, number int32, results []agreementResult) {
+	for i := range results {
+		for _, c := range results[i].unsigned {
+			if utils.IsValidEmail(c.email) {
+				bot.unsignedPRs.add(c.email, prRef{Org: org, Repo: repo, Number: number})
+			}
+		}
+	}
+}
+
+// signingHook receives the notifications from the CLA platform once someone
+// signs, and re-checks the PRs involving the email.
+type signingHook struct {
+	bot       *robot
+	getSecret func() []byte
+}
+
+type signingNotification struct {
+	Email string `json:"email"`
+}
+
+func (h *signingHook) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/signed", h.signed)
+
+	return mux
+}
+
+func (h *signingHook) signed(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "only POST is allowed")
+
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxSigningBodyBytes))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "can't read the body")
+
+		return
+	}
+
+	if !h.verify(req.Header.Get(signatureHeader), body) {
+		logrus.WithField("remote", req.RemoteAddr).Warning("Rejected the signing notification with invalid signature.")
+		writeJSONError(w, http.StatusUnauthorized, "invalid signature")
+
+		return
+	}
+
+	var v signingNotification
+	if err := json.Unmarshal(body, &v); err != nil || !utils.IsValidEmail(v.Email) {
+		writeJSONError(w, http.StatusBadRequest, "a valid email is required")
+
+		return
+	}
+
+	if !h.bot.handlers.accept() {
+		writeJSONError(w, http.StatusServiceUnavailable, errShuttingDown.Error())
+
+		return
+	}
+
+	go func() {
+		defer h.bot.handlers.done()
+
+		h.bot.handleSigned(context.Background(), v.Email)
+	}()
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"email": maskEmail(v.Email)})
+}
+
+func (h *signingHook) verify(signature string, body []byte) bool {
+	v, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || len(v) == 0 {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(strings.TrimSpace(string(h.getSecret()))))
+	mac.Write(body)
+
+	return hmac.Equal(v, mac.Sum(nil))
+}
+
+// handleSigned drops the caches of email and re-checks the PRs involving it.
+// The PRs are found by the index, or by a bounded scan of the unsigned PRs
+// in the config if the email is not indexed, such as after a restart.
+func (bot *robot) handleSigned(ctx context.Context, email string) {
+	log := logrus.WithField("signed_email", maskEmail(email))
+
+	key := strings.ToLower(email)
+	bot.emailLogins.removeIf(func(k string) bool { return strings.ToLower(k) == key })
+	bot.signedEmails.removeIf(func(k string) bool { return strings.HasSuffix(k, " "+key) })
+	bot.lastKnown.removeIf(func(k string) bool { return strings.HasSuffix(k, " "+key) })
+
+	prs := bot.unsignedPRs.take(email)
+	if len(prs) == 0 {
+		prs = bot.scanUnsignedPRs(ctx, log)
+		log.Infof("The email is not indexed, re-check %d unsigned prs found by scan.", len(prs))
+	}
+
+	ticker := time.NewTicker(recheckInterval)
+	defer ticker.Stop()
+
+	flipped := 0
+	for _, pr := range prs {
+		<-ticker.C
+
+		l := log.WithFields(logrus.Fields{"org": pr.Org, "repo": pr.Repo, "number": pr.Number})
+
+		r, err := bot.recheckPRByNumber(ctx, pr.Org, pr.Repo, pr.Number, auditTriggerSigning, l)
+		if err != nil {
+			l.WithError(err).Info("Skip re-checking the pr.")
+
+			continue
+		}
+
+		if r.Verdict == outcomeSigned {
+			flipped++
+		}
+	}
+
+	log.WithFields(logrus.Fields{
+		"rechecked": len(prs),
+		"signed":    flipped,
+	}).Info("Re-checked the prs on the signing notification.")
+}
+
+// scanUnsignedPRs lists the open PRs labeled as unsigned in the config, at
+// most maxSigningScanPRs.
+func (bot *robot) scanUnsignedPRs(ctx context.Context, log *logrus.Entry) []prRef {
+	c, ok := bot.latestConfig.Load().(*configuration)
+	if !ok {
+		return nil
+	}
+
+	var r []prRef
+	seen := sets.NewString()
+
+	for i := range c.ConfigItems {
+		item := &c.ConfigItems[i]
+
+		for _, orgRepo := range bot.sweptRepos(ctx, item, log) {
+			org, repo := orgRepo[0], orgRepo[1]
+			if seen.Has(org + "/" + repo) {
+				continue
+			}
+			seen.Insert(org + "/" + repo)
+
+			prs, err := listAllOpenPRs(ctx, org, repo, bot.cli)
+			if err != nil {
+				log.WithError(err).Warningf("Could not list the open prs of %s/%s.", org, repo)
+
+				continue
+			}
+
+			for j := range prs {
+				for _, l := range prs[j].Labels {
+					if l.Name == item.CLALabelNo {
+						r = append(r, prRef{Org: org, Repo: repo, Number: prs[j].Number})
+
+						break
+					}
+				}
+
+				if len(r) >= maxSigningScanPRs {
+					return r
+				}
+			}
+		}
+	}
+
+	return r
+}
+
+// runSigningHookServer serves the webhook of CLA platform in the background.
+func runSigningHookServer(address string, h *signingHook) *http.Server {
+	s := &http.Server{Addr: address, Handler: h.handler()}
+
+	go func() {
+		if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.WithError(err).Error("Error serving the webhook of CLA platform.")
+		}
+	}()
+
+	return s
+}
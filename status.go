@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	statusSuccess = "success"
+	statusFailure = "failure"
+	statusError   = "error"
+)
+
+// commitStatus is the status of a commit reported to Gitee.
+type commitStatus struct {
+	State       string `json:"state"`
+	Context     string `json:"context"`
+	Description string `json:"description"`
+	TargetURL   string `json:"target_url,omitempty"`
+}
+
+func newCommitStatus(state, desc string) commitStatus {
+	return commitStatus{State: state, Description: desc}
+}
+
+// setCommitStatus sets the CLA status on the commit. The force pushes move the
+// head commit of PR, so the status follows the PR naturally.
+func (bot *robot) setCommitStatus(
+	ctx context.Context,
+	org, repo, sha string,
+	cfg *botConfig,
+	status commitStatus,
+	log *logrus.Entry,
+) {
+	if status.State == "" || sha == "" {
+		return
+	}
+
+	status.Context = cfg.CommitStatusContext
+	status.TargetURL = cfg.SignURL
+
+	if err := bot.cli.CreateCommitStatus(ctx, org, repo, sha, status); err != nil {
+		log.WithError(err).Warningf("Could not set the commit status on %s.", sha)
+	}
+}
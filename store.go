@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// emailExemption is the record of an email exempted from the CLA check in a repo.
+type emailExemption struct {
+	Email     string    `json:"email"`
+	Reason    string    `json:"reason"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// exemptionStore persists the emails exempted from the CLA check per repo
+// in a JSON file, so that they survive the restarts.
+type exemptionStore struct {
+	mu   sync.RWMutex
+	path string
+
+	// items maps org/repo to the exemptions of that repo keyed by the lower-cased email.
+	items map[string]map[string]emailExemption
+}
+
+// newExemptionStore loads the store from the file. The file will be
+// created on the first change if it doesn't exist.
+func newExemptionStore(path string) (*exemptionStore, error) {
+	items, err := readExemptions(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &exemptionStore{path: path, items: items}, nil
+}
+
+func readExemptions(path string) (map[string]map[string]emailExemption, error) {
+	items := map[string]map[string]emailExemption{}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return items, nil
+		}
+
+		return nil, err
+	}
+
+	if len(b) == 0 {
+		return items, nil
+	}
+
+	if err := json.Unmarshal(b, &items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// reload re-reads the file, such as after it is edited by hand, and returns
+// the number of repos which have exemptions.
+func (s *exemptionStore) reload() (int, error) {
+	items, err := readExemptions(s.path)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	s.items = items
+	s.mu.Unlock()
+
+	return len(items), nil
+}
+
+func (s *exemptionStore) has(org, repo, email string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.items[org+"/"+repo][strings.ToLower(email)]
+
+	return ok
+}
+
+func (s *exemptionStore) list(org, repo string) []emailExemption {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m := s.items[org+"/"+repo]
+
+	r := make([]emailExemption, 0, len(m))
+	for _, v := range m {
+		r = append(r, v)
+	}
+
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].Email < r[j].Email
+	})
+
+	return r
+}
+
+func (s *exemptionStore) add(org, repo string, item emailExemption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := org + "/" + repo
+
+	m, ok := s.items[key]
+	if !ok {
+		m = map[string]emailExemption{}
+		s.items[key] = m
+	}
+
+	email := strings.ToLower(item.Email)
+	old, existed := m[email]
+	m[email] = item
+
+	if err := s.save(); err != nil {
+		if existed {
+			m[email] = old
+		} else {
+			delete(m, email)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// remove removes the exemption and returns false if it doesn't exist.
+func (s *exemptionStore) remove(org, repo, email string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := org + "/" + repo
+	email = strings.ToLower(email)
+
+	old, ok := s.items[key][email]
+	if !ok {
+		return false, nil
+	}
+
+	delete(s.items[key], email)
+
+	if err := s.save(); err != nil {
+		s.items[key][email] = old
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// save writes the store to a temporary file and renames it, so that the
+// file will not be corrupted if the process crashes in the middle.
+func (s *exemptionStore) save() error {
+	b, err := json.MarshalIndent(s.items, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), ".exemptions-")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
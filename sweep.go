@@ -0,0 +1,470 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/opensourceways/community-robot-lib/config"
+	sdk "github.com/opensourceways/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	day = 24 * time.Hour
+
+	// orphanedCheckingAge is the age after which the checking label is
+	// regarded as leaked by a check which crashed midway.
+	orphanedCheckingAge = 10 * time.Minute
+)
+
+// recordConfig keeps the latest config delivered with the events, which the
+// periodic sweep works on because it is not driven by any event.
+func (bot *robot) recordConfig(c config.Config) {
+	if v, ok := c.(*configuration); ok {
+		bot.latestConfig.Store(v)
+	}
+}
+
+// runSweeper sweeps the open PRs periodically until stop is closed.
+func (bot *robot) runSweeper(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !bot.handlers.accept() {
+				return
+			}
+
+			bot.sweep(context.Background())
+			bot.handlers.done()
+		}
+	}
+}
+
+// sweep walks the open PRs of the repos whose config needs the periodic sweep.
+func (bot *robot) sweep(ctx context.Context) {
+	c, ok := bot.latestConfig.Load().(*configuration)
+	if !ok {
+		return
+	}
+
+	log := logrus.WithField("sweep", true)
+
+	for i := range c.ConfigItems {
+		item := &c.ConfigItems[i]
+		if !item.needSweep() {
+			continue
+		}
+
+		for _, orgRepo := range bot.sweptRepos(ctx, item, log) {
+			org, repo := orgRepo[0], orgRepo[1]
+
+			prs, err := listAllOpenPRs(ctx, org, repo, bot.cli)
+			if err != nil {
+				log.WithError(err).Warningf("Could not list the open prs of %s/%s.", org, repo)
+
+				continue
+			}
+
+			for j := range prs {
+				l := log.WithFields(logrus.Fields{"org": org, "repo": repo, "number": prs[j].Number})
+
+				if err := bot.sweepPR(ctx, org, repo, toPullRequestHook(&prs[j]), item, l); err != nil {
+					l.WithError(err).Warning("Could not sweep the pr.")
+				}
+			}
+		}
+	}
+}
+
+// sweptRepos returns the repos covered by the config. The repos of org are listed
+// if the config applies to the whole org.
+func (bot *robot) sweptRepos(ctx context.Context, cfg *botConfig, log *logrus.Entry) [][2]string {
+	var r [][2]string
+
+	for _, v := range cfg.Repos {
+		if parts := strings.Split(v, "/"); len(parts) == 2 {
+			r = append(r, [2]string{parts[0], parts[1]})
+
+			continue
+		}
+
+		repos, err := bot.cli.GetRepos(ctx, v)
+		if err != nil {
+			log.WithError(err).Warningf("Could not list the repos of %s.", v)
+
+			continue
+		}
+
+		for i := range repos {
+			// The excluded repos of org are not applied.
+			name := repos[i].Path
+			if applied, _ := cfg.CanApply(v, v+"/"+name); applied {
+				r = append(r, [2]string{v, name})
+			}
+		}
+	}
+
+	return r
+}
+
+func (bot *robot) sweepPR(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	c *botConfig,
+	log *logrus.Entry,
+) error {
+	cfg := c.configForBranch(pr.GetBase().GetRef())
+	if !cfg.TargetBranches.match(pr.GetBase().GetRef()) {
+		return nil
+	}
+
+	defer bot.prLocks.lock(prKey(org, repo, pr.GetNumber()))()
+
+	labels, err := bot.getPRLabels(ctx, org, repo, pr.GetNumber())
+	if err != nil {
+		return err
+	}
+
+	if l := cfg.CLALabelChecking; l != "" && labels.Has(l) {
+		bot.clearOrphanedCheckingLabel(ctx, org, repo, pr.GetNumber(), cfg, labels, log)
+	}
+
+	// The reminder is suppressed when the PR is signed or the check is overridden.
+	if !labels.Has(cfg.CLALabelNo) || labels.Has(cfg.CLALabelYes) ||
+		(cfg.ManualOverrideLabel != "" && labels.Has(cfg.ManualOverrideLabel)) {
+		return nil
+	}
+
+	if exempted, err := bot.isExempted(ctx, org, repo, pr.GetNumber()); err != nil || exempted {
+		return err
+	}
+
+	comments, err := listAllPRComments(ctx, org, repo, pr.GetNumber(), bot.cli)
+	if err != nil {
+		return err
+	}
+
+	if done, err := bot.closeUnsigned(ctx, org, repo, pr, cfg, labels, comments, log); err != nil || done {
+		return err
+	}
+
+	var errs mutationErrors
+
+	errs.add(bot.escalateUnsigned(ctx, org, repo, pr, cfg, comments, log))
+	errs.add(bot.remindUnsigned(ctx, org, repo, pr, cfg, comments, log))
+
+	return errs.join(nil)
+}
+
+// closeUnsigned warns and then closes the PR left unsigned for close_unsigned_after_days.
+// The clock restarts when the PR closed by the robot is reopened. It returns
+// true if the PR is warned or closed, then the reminder is not needed.
+func (bot *robot) closeUnsigned(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	cfg *botConfig,
+	labels sets.String,
+	comments []sdk.PullRequestComments,
+	log *logrus.Entry,
+) (bool, error) {
+	if cfg.CloseUnsignedAfterDays <= 0 {
+		return false, nil
+	}
+
+	start := pr.CreatedAt
+	if t := newestBotCommentTime(comments, markerAutoClosed); t.After(start) {
+		start = t
+	}
+
+	if start.IsZero() {
+		return false, nil
+	}
+
+	closeAt := time.Duration(cfg.CloseUnsignedAfterDays) * day
+	warnBefore := time.Duration(cfg.CloseWarningDays) * day
+
+	age := time.Since(start)
+	if age < closeAt-warnBefore {
+		return false, nil
+	}
+
+	prNumber := pr.GetNumber()
+
+	warnedAt := newestBotCommentTime(comments, markerCloseWarning)
+	if !warnedAt.After(start) {
+		log.Info("Warn the author that the pr left unsigned will be closed.")
+
+		return true, replaceBotComment(
+			ctx, org, repo, prNumber, markerCloseWarning,
+			closeWarning(cfg.CommentLanguage, pr.GetUser().GetLogin(), cfg.CloseWarningDays, cfg.SignURL), bot.cli,
+		)
+	}
+
+	if age < closeAt || time.Since(warnedAt) < warnBefore {
+		return true, nil
+	}
+
+	log.Info("Close the pr left unsigned.")
+
+	var errs mutationErrors
+
+	errs.add(bot.addLabel(ctx, org, repo, prNumber, cfg, labels, cfg.StaleLabel, log))
+
+	errs.add(bot.closePRWithComment(
+		ctx, org, repo, prNumber,
+		withMarker(markerAutoClosed, autoClosedNotice(cfg.CommentLanguage, pr.GetUser().GetLogin(), cfg.CloseUnsignedAfterDays)),
+	))
+
+	return true, errs.join(nil)
+}
+
+// closePRWithComment posts the comment and then closes the PR.
+func (bot *robot) closePRWithComment(ctx context.Context, org, repo string, number int32, comment string) error {
+	if err := bot.cli.CreatePRComment(ctx, org, repo, number, comment); err != nil {
+		return err
+	}
+
+	return bot.cli.ClosePR(ctx, org, repo, number)
+}
+
+// resetCloseClock removes the stale label and the warnings of closing when
+// the PR is reopened, so that the clock of closing restarts.
+func (bot *robot) resetCloseClock(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	cfg *botConfig,
+	log *logrus.Entry,
+) {
+	if cfg.CloseUnsignedAfterDays <= 0 {
+		return
+	}
+
+	if cfg.StaleLabel != "" {
+		labels, err := bot.getPRLabels(ctx, org, repo, number)
+		if err != nil {
+			log.WithError(err).Warning("Could not get the labels of pr.")
+		} else if err := bot.removeLabel(ctx, org, repo, number, cfg, labels, cfg.StaleLabel, log); err != nil {
+			log.WithError(err).Warning("Could not remove the stale label.")
+		}
+	}
+
+	comments, err := listAllPRComments(ctx, org, repo, number, bot.cli)
+	if err != nil {
+		log.WithError(err).Warning("Could not list the comments of pr.")
+
+		return
+	}
+
+	deleteBotComments(ctx, org, repo, comments, markerCloseWarning, bot.cli)
+}
+
+// clearOrphanedCheckingLabel removes the checking label leaked by a crash. The
+// label applied recently is kept, because the check may still be running on
+// another instance.
+func (bot *robot) clearOrphanedCheckingLabel(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	cfg *botConfig,
+	labels sets.String,
+	log *logrus.Entry,
+) {
+	t, err := bot.labelAppliedAt(ctx, org, repo, number, cfg.CLALabelChecking)
+	if err != nil {
+		log.WithError(err).Warning("Could not get when the checking label was applied.")
+
+		return
+	}
+
+	if !t.IsZero() && time.Since(t) < orphanedCheckingAge {
+		return
+	}
+
+	log.Info("Remove the orphaned checking label.")
+
+	if err := bot.removeLabel(ctx, org, repo, number, cfg, labels, cfg.CLALabelChecking, log); err != nil {
+		log.WithError(err).Warning("Could not remove the orphaned checking label.")
+	}
+}
+
+// labelAppliedAt returns when the label was changed last time according to the
+// operation logs of PR, or the zero time if it is unknown.
+func (bot *robot) labelAppliedAt(ctx context.Context, org, repo string, number int32, label string) (time.Time, error) {
+	logs, err := bot.cli.ListPROperationLogs(ctx, org, repo, number)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var r *sdk.OperateLog
+	for i := range logs {
+		if item := &logs[i]; strings.Contains(item.Content, label) && (r == nil || item.Id > r.Id) {
+			r = item
+		}
+	}
+
+	if r == nil {
+		return time.Time{}, nil
+	}
+
+	t, _ := time.Parse(time.RFC3339, r.CreatedAt)
+
+	return t, nil
+}
+
+// newestBotCommentTime returns the time when the newest comment of kind was created.
+func newestBotCommentTime(comments []sdk.PullRequestComments, kind string) time.Time {
+	items := findBotComments(comments, kind)
+	if len(items) == 0 {
+		return time.Time{}
+	}
+
+	t, _ := time.Parse(time.RFC3339, items[len(items)-1].CreatedAt)
+
+	return t
+}
+
+// remindUnsigned posts a reminder to the author of PR left unsigned for
+// remind_after_days, at most once per remind_interval_days.
+func (bot *robot) remindUnsigned(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	cfg *botConfig,
+	comments []sdk.PullRequestComments,
+	log *logrus.Entry,
+) error {
+	if cfg.RemindAfterDays <= 0 {
+		return nil
+	}
+
+	if pr.CreatedAt.IsZero() || time.Since(pr.CreatedAt) < time.Duration(cfg.RemindAfterDays)*day {
+		return nil
+	}
+
+	t := newestBotCommentTime(comments, markerReminder)
+	if !t.IsZero() && time.Since(t) < time.Duration(cfg.RemindIntervalDays)*day {
+		return nil
+	}
+
+	log.Info("Remind the author of the pr left unsigned.")
+
+	return replaceBotComment(
+		ctx, org, repo, pr.GetNumber(), markerReminder,
+		unsignedReminder(cfg.CommentLanguage, pr.GetUser().GetLogin(), cfg.RemindAfterDays, cfg.SignURL), bot.cli,
+	)
+}
+
+// escalateUnsigned mentions the maintainers on the PR left unsigned for
+// escalate_after_days since the sign guide was posted. It is done at most once
+// per PR, and the drafts are skipped.
+func (bot *robot) escalateUnsigned(
+	ctx context.Context,
+	org, repo string,
+	pr *sdk.PullRequestHook,
+	cfg *botConfig,
+	comments []sdk.PullRequestComments,
+	log *logrus.Entry,
+) error {
+	if cfg.EscalateAfterDays <= 0 || len(findBotComments(comments, markerEscalation)) > 0 {
+		return nil
+	}
+
+	// The PR created in the mode without comment has no sign guide.
+	start := newestBotCommentTime(comments, markerSignGuide)
+	if start.IsZero() {
+		start = pr.CreatedAt
+	}
+
+	if start.IsZero() || time.Since(start) < time.Duration(cfg.EscalateAfterDays)*day {
+		return nil
+	}
+
+	prNumber := pr.GetNumber()
+
+	if draft, err := bot.isDraftPR(ctx, org, repo, prNumber); err != nil || draft {
+		return err
+	}
+
+	emails, err := bot.unsignedEmails(ctx, org, repo, prNumber, cfg)
+	if err != nil || len(emails) == 0 {
+		return err
+	}
+
+	log.Info("Escalate the pr left unsigned to the maintainers.")
+
+	return bot.cli.CreatePRComment(
+		ctx, org, repo, prNumber,
+		withMarker(markerEscalation, escalationNotice(cfg, emails)),
+	)
+}
+
+// unsignedEmails checks the PR again and returns the unique emails unsigned.
+func (bot *robot) unsignedEmails(ctx context.Context, org, repo string, number int32, cfg *botConfig) ([]string, error) {
+	agreements, err := bot.getApplicableAgreements(ctx, org, repo, number, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := bot.withEventTimeout(ctx)
+	defer cancel()
+
+	results, err := bot.getPRCommitsAbout(ctx, org, repo, number, cfg, agreements)
+	if err != nil {
+		return nil, err
+	}
+
+	emails := sets.NewString()
+	r := []string{}
+	for i := range results {
+		for _, item := range results[i].unsigned {
+			if !emails.Has(item.email) {
+				emails.Insert(item.email)
+				r = append(r, item.email)
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// escalationNotice mentions the maintainers with the emails unsigned, which
+// are masked if configured.
+func escalationNotice(cfg *botConfig, emails []string) string {
+	users := make([]string, 0, len(cfg.EscalationMentions))
+	for _, v := range cfg.EscalationMentions {
+		users = append(users, "@"+strings.TrimPrefix(v, "@"))
+	}
+
+	shown := make([]string, len(emails))
+	for i, v := range emails {
+		shown[i] = cfg.displayEmail(v)
+	}
+
+	return fmt.Sprintf(
+		message(cfg.CommentLanguage, msgEscalation),
+		strings.Join(users, " "), cfg.EscalateAfterDays, strings.Join(shown, "\n- "),
+	)
+}
+
+func unsignedReminder(lang, author string, days int, signURL string) string {
+	return fmt.Sprintf(message(lang, msgUnsignedReminder), author, days, signURL)
+}
+
+func closeWarning(lang, author string, days int, signURL string) string {
+	return fmt.Sprintf(message(lang, msgCloseWarning), author, days, signURL)
+}
+
+func autoClosedNotice(lang, author string, days int) string {
+	return fmt.Sprintf(message(lang, msgAutoClosed), author, days)
+}
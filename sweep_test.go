@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opensourceways/community-robot-lib/config"
+	sdk "github.com/opensourceways/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestSweptRepos(t *testing.T) {
+	repos := []sdk.Project{{Path: "repo1"}, {Path: "repo2"}}
+
+	cases := []struct {
+		name   string
+		filter config.RepoFilter
+		want   [][2]string
+	}{
+		{
+			name:   "repo",
+			filter: config.RepoFilter{Repos: []string{"org/repo3"}},
+			want:   [][2]string{{"org", "repo3"}},
+		},
+		{
+			name:   "org",
+			filter: config.RepoFilter{Repos: []string{"org"}},
+			want:   [][2]string{{"org", "repo1"}, {"org", "repo2"}},
+		},
+		{
+			name:   "org with excluded repo",
+			filter: config.RepoFilter{Repos: []string{"org"}, ExcludedRepos: []string{"org/repo1"}},
+			want:   [][2]string{{"org", "repo2"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			bot := newRobot(&fakeClient{repos: repos}, "robot", nil, nil, time.Minute, 0)
+			cfg := &botConfig{RepoFilter: tc.filter}
+
+			got := bot.sweptRepos(context.Background(), cfg, logrus.NewEntry(logrus.New()))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expect %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCloseUnsigned(t *testing.T) {
+	cases := []struct {
+		name         string
+		behavior     string
+		missing      bool
+		wantLabelOps []string
+	}{
+		{name: "stale label exists", wantLabelOps: []string{"add cla/stale"}},
+		{name: "stale label is missing", missing: true, wantLabelOps: []string{"add cla/stale", "create cla/stale", "add cla/stale"}},
+		{name: "labels are disabled", behavior: behaviorCommentOnly},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := &fakeClient{bodies: map[int32]string{}}
+			if tc.missing {
+				cli.missingLabels = map[string]bool{"cla/stale": true}
+			}
+
+			bot := newRobot(cli, "robot", nil, nil, time.Minute, 0)
+
+			cfg := newTestConfig("https://example.com/check")
+			cfg.CloseUnsignedAfterDays = 30
+			cfg.UnsignedBehavior = tc.behavior
+
+			_, pr := openPR(1, "sha")
+			pr.CreatedAt = time.Now().Add(-40 * day)
+
+			// The author has been warned long enough before.
+			comments := []sdk.PullRequestComments{{
+				Id:        1,
+				Body:      withMarker(markerCloseWarning, "warning"),
+				CreatedAt: time.Now().Add(-8 * day).Format(time.RFC3339),
+			}}
+
+			done, err := bot.closeUnsigned(
+				context.Background(), "org", "repo", pr, cfg, sets.NewString(), comments, logrus.NewEntry(logrus.New()),
+			)
+			if err != nil || !done {
+				t.Fatalf("expect the pr to be closed, got %v, %v", done, err)
+			}
+
+			if !cli.closed {
+				t.Fatal("expect the pr to be closed")
+			}
+
+			if !reflect.DeepEqual(cli.labelOps, tc.wantLabelOps) {
+				t.Fatalf("expect %v, got %v", tc.wantLabelOps, cli.labelOps)
+			}
+		})
+	}
+}
+
+func TestUnsignedEmails(t *testing.T) {
+	s := fakeChecker()
+	defer s.Close()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		want    []string
+		wantErr bool
+	}{
+		{name: "unique unsigned emails", ctx: context.Background(), want: []string{"alice@a.com", "bob@a.com"}},
+		{name: "sweep is canceled", ctx: canceled, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := &fakeClient{commits: commitsOf("alice@a.com", "signed@a.com", "bob@a.com", "alice@a.com")}
+			bot := newRobot(cli, "robot", nil, nil, time.Minute, 0)
+
+			got, err := bot.unsignedEmails(tc.ctx, "org", "repo", 1, newTestConfig(s.URL))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expect error: %v, got %v", tc.wantErr, err)
+			}
+
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expect %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestEscalationNotice(t *testing.T) {
+	emails := []string{"alice@example.com", "bob@example.org"}
+
+	cases := []struct {
+		name     string
+		cfg      botConfig
+		want     []string
+		wantHide []string
+	}{
+		{
+			name: "emails are shown",
+			cfg:  botConfig{EscalationMentions: []string{"@maintainers", "bob"}, EscalateAfterDays: 14},
+			want: []string{"@maintainers @bob", "more than 14 days", "- alice@example.com\n- bob@example.org"},
+		},
+		{
+			name:     "emails are masked",
+			cfg:      botConfig{EscalationMentions: []string{"maintainers"}, EscalateAfterDays: 14, MaskEmails: true},
+			want:     []string{"- a***@example.com\n- b***@example.org"},
+			wantHide: []string{"alice@", "bob@"},
+		},
+		{
+			name: "zh_CN",
+			cfg:  botConfig{EscalationMentions: []string{"maintainers"}, EscalateAfterDays: 14, CommentLanguage: langZhCN},
+			want: []string{"@maintainers，", "超过 14 天", "- alice@example.com"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := escalationNotice(&tc.cfg, emails)
+
+			for _, v := range tc.want {
+				if !strings.Contains(got, v) {
+					t.Fatalf("expect %q in %q", v, got)
+				}
+			}
+
+			for _, v := range tc.wantHide {
+				if strings.Contains(got, v) {
+					t.Fatalf("expect %q to be hidden in %q", v, got)
+				}
+			}
+		})
+	}
+}
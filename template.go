@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"text/template"
+)
+
+// The kinds of messages which can be overridden by templates.
+const (
+	tmplSignGuide    = "sign_guide"
+	tmplConfirmation = "confirmation"
+	tmplErrorNotice  = "error_notice"
+	tmplHelp         = "help"
+)
+
+// templateSource is a Go text/template specified inline or by the path of file.
+type templateSource struct {
+	Inline string `json:"inline,omitempty"`
+	File   string `json:"file,omitempty"`
+}
+
+// messageTemplates are the overrides of messages. The templates are rendered
+// with templateData, such as "@{{.Author}}, please sign the CLA at {{.SignURL}}".
+type messageTemplates struct {
+	SignGuide    *templateSource `json:"sign_guide,omitempty"`
+	Confirmation *templateSource `json:"confirmation,omitempty"`
+	ErrorNotice  *templateSource `json:"error_notice,omitempty"`
+	Help         *templateSource `json:"help,omitempty"`
+}
+
+// templateData is the data which the templates can refer to.
+type templateData struct {
+	// Author is the login of PR author.
+	Author string
+
+	// UnsignedTable lists the unsigned commits, one per line.
+	UnsignedTable string
+
+	// UnknownTable lists the commits whose authors can't be verified, one per line.
+	UnknownTable string
+
+	SignURL string
+	FAQURL  string
+
+	UnsignedCommits int
+	UnsignedAuthors int
+
+	// VerifiedEmails are the emails which signed the CLA, masked if configured.
+	VerifiedEmails []string
+}
+
+func (t *messageTemplates) sources() map[string]*templateSource {
+	return map[string]*templateSource{
+		tmplSignGuide:    t.SignGuide,
+		tmplConfirmation: t.Confirmation,
+		tmplErrorNotice:  t.ErrorNotice,
+		tmplHelp:         t.Help,
+	}
+}
+
+// parse parses the templates and renders them with the sample data, so that
+// a typo fails loading the config rather than handling the first PR.
+func (t *messageTemplates) parse() (map[string]*template.Template, error) {
+	sample := templateData{
+		Author:          "alice",
+		UnsignedTable:   "**1234abcd** | fix typo | (author: alice@example.com)",
+		UnknownTable:    "**1234abcd** | alice@example.com | timeout",
+		SignURL:         "https://example.com/sign",
+		FAQURL:          "https://example.com/faq",
+		UnsignedCommits: 1,
+		UnsignedAuthors: 1,
+		VerifiedEmails:  []string{"alice@example.com"},
+	}
+
+	r := map[string]*template.Template{}
+
+	for kind, src := range t.sources() {
+		if src == nil {
+			continue
+		}
+
+		text := src.Inline
+		if src.File != "" {
+			b, err := ioutil.ReadFile(src.File)
+			if err != nil {
+				return nil, fmt.Errorf("read the template of %s: %w", kind, err)
+			}
+
+			text = string(b)
+		}
+
+		if text == "" {
+			return nil, fmt.Errorf("empty template of %s", kind)
+		}
+
+		tmpl, err := template.New(kind).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("parse the template of %s: %w", kind, err)
+		}
+
+		if err := tmpl.Execute(new(bytes.Buffer), sample); err != nil {
+			return nil, fmt.Errorf("render the template of %s: %w", kind, err)
+		}
+
+		r[kind] = tmpl
+	}
+
+	return r, nil
+}
+
+// render renders the template of kind if it is overridden, otherwise returns fallback.
+func (c *botConfig) render(kind string, data templateData, fallback string) string {
+	tmpl, ok := c.templates[kind]
+	if !ok {
+		return fallback
+	}
+
+	data.SignURL = c.SignURL
+	data.FAQURL = c.FAQURL
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return fallback
+	}
+
+	return buf.String()
+}
+
+func unsignedTemplateData(cfg *botConfig, author string, results []agreementResult) templateData {
+	var commits []unsignedCommit
+	for i := range results {
+		commits = append(commits, results[i].unsigned...)
+	}
+
+	return templateData{
+		Author:          author,
+		UnsignedTable:   generateUnSignComment(cfg, commits, len(commits)),
+		UnsignedCommits: len(commits),
+		UnsignedAuthors: countUnsignedAuthors(results),
+	}
+}
+
+// checkCLAErrorNotice returns the notice of checking CLA failed without the commits listed.
+func (c *botConfig) checkCLAErrorNotice() string {
+	return c.render(tmplErrorNotice, templateData{}, checkCLAErrorNotice(c.CommentLanguage, ""))
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	sdk "github.com/opensourceways/go-gitee/gitee"
+)
+
+func TestUnsignedTemplateData(t *testing.T) {
+	results := []agreementResult{{
+		unsigned: []unsignedCommit{
+			{commit: &sdk.PullRequestCommits{Sha: "1111aaaa"}, email: "alice@example.com", role: "author"},
+			{commit: &sdk.PullRequestCommits{Sha: "2222bbbb"}, email: "alice@example.com", role: "committer"},
+		},
+	}}
+
+	cases := []struct {
+		name string
+		lang string
+	}{
+		{name: "english", lang: langEN},
+		{name: "chinese", lang: langZhCN},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &botConfig{CommentLanguage: tc.lang, MaskEmails: true}
+
+			got := unsignedTemplateData(cfg, "alice", results)
+
+			want := generateUnSignComment(cfg, results[0].unsigned, 2)
+			if got.UnsignedTable != want {
+				t.Fatalf("expect table %q, got %q", want, got.UnsignedTable)
+			}
+
+			if strings.Contains(got.UnsignedTable, "alice@") {
+				t.Fatalf("expect the email masked in %q", got.UnsignedTable)
+			}
+
+			if got.Author != "alice" || got.UnsignedCommits != 2 || got.UnsignedAuthors != 1 {
+				t.Fatalf("unexpected data: %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sdk "github.com/opensourceways/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// revokedTokenCooldown is how long the token rejected by 401 is skipped.
+	revokedTokenCooldown = 10 * time.Minute
+
+	// limitedTokenCooldown is how long the token hitting the rate limit or
+	// 403 is skipped.
+	limitedTokenCooldown = time.Minute
+)
+
+// tokenPoolClient rotates the requests across the tokens of the same robot
+// account, and fails over to the next token when one is revoked or limited.
+// The token is logged by its index, never the value.
+type tokenPoolClient struct {
+	clients []*giteeClient
+
+	next uint32
+
+	mu sync.Mutex
+	// disabledUntil is when each token can be used again.
+	disabledUntil []time.Time
+}
+
+func newTokenPoolClient(getTokens []func() []byte) *tokenPoolClient {
+	p := &tokenPoolClient{
+		clients:       make([]*giteeClient, len(getTokens)),
+		disabledUntil: make([]time.Time, len(getTokens)),
+	}
+
+	for i, f := range getTokens {
+		p.clients[i] = newGiteeClient(f)
+	}
+
+	return p
+}
+
+// tokenCooldown returns how long the token failing with err is skipped. It is
+// 0 if the failure is not caused by the token.
+func tokenCooldown(err error) time.Duration {
+	switch statusCode(err) {
+	case http.StatusUnauthorized:
+		return revokedTokenCooldown
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		return limitedTokenCooldown
+	}
+
+	return 0
+}
+
+// order returns the indexes of the available tokens starting from the next
+// one. All the tokens are returned if none is available, as the last resort.
+func (p *tokenPoolClient) order() []int {
+	n := len(p.clients)
+	start := int(atomic.AddUint32(&p.next, 1) % uint32(n))
+
+	now := time.Now()
+	available := make([]int, 0, n)
+	all := make([]int, 0, n)
+
+	p.mu.Lock()
+	for i := 0; i < n; i++ {
+		j := (start + i) % n
+		all = append(all, j)
+
+		if now.After(p.disabledUntil[j]) {
+			available = append(available, j)
+		}
+	}
+	p.mu.Unlock()
+
+	if len(available) == 0 {
+		return all
+	}
+
+	return available
+}
+
+func (p *tokenPoolClient) disable(i int, d time.Duration, err error) {
+	p.mu.Lock()
+	p.disabledUntil[i] = time.Now().Add(d)
+	p.mu.Unlock()
+
+	logrus.WithError(err).WithField("token", i).Warningf("The token failed, skip it for %s.", d)
+}
+
+// do runs f with the tokens in turn until it succeeds or fails for the
+// reason other than the token.
+func (p *tokenPoolClient) do(f func(*giteeClient) error) error {
+	if len(p.clients) == 1 {
+		return f(p.clients[0])
+	}
+
+	var err error
+	for _, i := range p.order() {
+		logrus.WithField("token", i).Debug("Request gitee.")
+
+		if err = f(p.clients[i]); err == nil {
+			return nil
+		}
+
+		d := tokenCooldown(err)
+		if d == 0 {
+			return err
+		}
+
+		p.disable(i, d, err)
+	}
+
+	return err
+}
+
+// GetBot returns the robot account, and makes sure that all the tokens
+// belong to it, so that the comments are recognized as the robot's.
+func (p *tokenPoolClient) GetBot(ctx context.Context) (sdk.User, error) {
+	var bot sdk.User
+
+	for i, cli := range p.clients {
+		v, err := cli.GetBot(ctx)
+		if err != nil {
+			return bot, fmt.Errorf("token %d: %w", i, err)
+		}
+
+		if i == 0 {
+			bot = v
+		} else if v.Login != bot.Login {
+			return bot, fmt.Errorf("token %d belongs to %s instead of %s", i, v.Login, bot.Login)
+		}
+	}
+
+	return bot, nil
+}
+
+func (p *tokenPoolClient) AddPRLabel(ctx context.Context, org, repo string, number int32, label string) error {
+	return p.do(func(cli *giteeClient) error {
+		return cli.AddPRLabel(ctx, org, repo, number, label)
+	})
+}
+
+func (p *tokenPoolClient) RemovePRLabel(ctx context.Context, org, repo string, number int32, label string) error {
+	return p.do(func(cli *giteeClient) error {
+		return cli.RemovePRLabel(ctx, org, repo, number, label)
+	})
+}
+
+func (p *tokenPoolClient) CreatePRComment(ctx context.Context, org, repo string, number int32, comment string) error {
+	return p.do(func(cli *giteeClient) error {
+		return cli.CreatePRComment(ctx, org, repo, number, comment)
+	})
+}
+
+func (p *tokenPoolClient) CreatePRCommentWithID(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	comment string,
+) (v int32, err error) {
+	err = p.do(func(cli *giteeClient) (err error) {
+		v, err = cli.CreatePRCommentWithID(ctx, org, repo, number, comment)
+		return
+	})
+
+	return
+}
+
+func (p *tokenPoolClient) UpdatePRComment(ctx context.Context, org, repo string, commentID int32, comment string) error {
+	return p.do(func(cli *giteeClient) error {
+		return cli.UpdatePRComment(ctx, org, repo, commentID, comment)
+	})
+}
+
+func (p *tokenPoolClient) DeletePRComment(ctx context.Context, org, repo string, ID int32) error {
+	return p.do(func(cli *giteeClient) error {
+		return cli.DeletePRComment(ctx, org, repo, ID)
+	})
+}
+
+func (p *tokenPoolClient) GetPRCommits(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+) (v []sdk.PullRequestCommits, err error) {
+	err = p.do(func(cli *giteeClient) (err error) {
+		v, err = cli.GetPRCommits(ctx, org, repo, number)
+		return
+	})
+
+	return
+}
+
+func (p *tokenPoolClient) GetPullRequestChanges(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+) (v []sdk.PullRequestFiles, err error) {
+	err = p.do(func(cli *giteeClient) (err error) {
+		v, err = cli.GetPullRequestChanges(ctx, org, repo, number)
+		return
+	})
+
+	return
+}
+
+func (p *tokenPoolClient) GetGiteePullRequest(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+) (v sdk.PullRequest, err error) {
+	err = p.do(func(cli *giteeClient) (err error) {
+		v, err = cli.GetGiteePullRequest(ctx, org, repo, number)
+		return
+	})
+
+	return
+}
+
+func (p *tokenPoolClient) GetPRLabels(ctx context.Context, org, repo string, number int32) (v []sdk.Label, err error) {
+	err = p.do(func(cli *giteeClient) (err error) {
+		v, err = cli.GetPRLabels(ctx, org, repo, number)
+		return
+	})
+
+	return
+}
+
+func (p *tokenPoolClient) HasMergedPR(ctx context.Context, org, repo, author string) (v bool, err error) {
+	err = p.do(func(cli *giteeClient) (err error) {
+		v, err = cli.HasMergedPR(ctx, org, repo, author)
+		return
+	})
+
+	return
+}
+
+func (p *tokenPoolClient) FindUserByEmail(ctx context.Context, email string) (v string, err error) {
+	err = p.do(func(cli *giteeClient) (err error) {
+		v, err = cli.FindUserByEmail(ctx, email)
+		return
+	})
+
+	return
+}
+
+func (p *tokenPoolClient) CreateCommitStatus(ctx context.Context, org, repo, sha string, status commitStatus) error {
+	return p.do(func(cli *giteeClient) error {
+		return cli.CreateCommitStatus(ctx, org, repo, sha, status)
+	})
+}
+
+func (p *tokenPoolClient) CreateRepoLabel(ctx context.Context, org, repo, label, color string) error {
+	return p.do(func(cli *giteeClient) error {
+		return cli.CreateRepoLabel(ctx, org, repo, label, color)
+	})
+}
+
+func (p *tokenPoolClient) GetUserPermissionsOfRepo(
+	ctx context.Context,
+	org, repo, login string,
+) (v sdk.ProjectMemberPermission, err error) {
+	err = p.do(func(cli *giteeClient) (err error) {
+		v, err = cli.GetUserPermissionsOfRepo(ctx, org, repo, login)
+		return
+	})
+
+	return
+}
+
+func (p *tokenPoolClient) IsCollaborator(ctx context.Context, owner, repo, login string) (v bool, err error) {
+	err = p.do(func(cli *giteeClient) (err error) {
+		v, err = cli.IsCollaborator(ctx, owner, repo, login)
+		return
+	})
+
+	return
+}
+
+func (p *tokenPoolClient) ListPRCommentsByPage(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+	page, perPage int,
+) (v []sdk.PullRequestComments, err error) {
+	err = p.do(func(cli *giteeClient) (err error) {
+		v, err = cli.ListPRCommentsByPage(ctx, org, repo, number, page, perPage)
+		return
+	})
+
+	return
+}
+
+func (p *tokenPoolClient) ListPROperationLogs(
+	ctx context.Context,
+	org, repo string,
+	number int32,
+) (v []sdk.OperateLog, err error) {
+	err = p.do(func(cli *giteeClient) (err error) {
+		v, err = cli.ListPROperationLogs(ctx, org, repo, number)
+		return
+	})
+
+	return
+}
+
+func (p *tokenPoolClient) ListOpenPRsByPage(
+	ctx context.Context,
+	org, repo string,
+	page, perPage int,
+) (v []sdk.PullRequest, err error) {
+	err = p.do(func(cli *giteeClient) (err error) {
+		v, err = cli.ListOpenPRsByPage(ctx, org, repo, page, perPage)
+		return
+	})
+
+	return
+}
+
+func (p *tokenPoolClient) GetRepos(ctx context.Context, org string) (v []sdk.Project, err error) {
+	err = p.do(func(cli *giteeClient) (err error) {
+		v, err = cli.GetRepos(ctx, org)
+		return
+	})
+
+	return
+}
+
+func (p *tokenPoolClient) GetRepoLabels(ctx context.Context, owner, repo string) (v []sdk.Label, err error) {
+	err = p.do(func(cli *giteeClient) (err error) {
+		v, err = cli.GetRepoLabels(ctx, owner, repo)
+		return
+	})
+
+	return
+}
+
+func (p *tokenPoolClient) ClosePR(ctx context.Context, org, repo string, number int32) error {
+	return p.do(func(cli *giteeClient) error {
+		return cli.ClosePR(ctx, org, repo, number)
+	})
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTokenCooldown(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want time.Duration
+	}{
+		{name: "revoked", err: giteeStatusError(http.StatusUnauthorized), want: revokedTokenCooldown},
+		{name: "forbidden", err: giteeStatusError(http.StatusForbidden), want: limitedTokenCooldown},
+		{name: "rate limited", err: giteeStatusError(http.StatusTooManyRequests), want: limitedTokenCooldown},
+		{name: "wrapped rate limited", err: fmt.Errorf("list: %w", giteeStatusError(http.StatusTooManyRequests)), want: limitedTokenCooldown},
+		{name: "not found", err: giteeStatusError(http.StatusNotFound), want: 0},
+		{name: "server error", err: giteeStatusError(http.StatusBadGateway), want: 0},
+		{name: "status in text only", err: errors.New("the comment 401 is deleted"), want: 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tokenCooldown(tc.err); got != tc.want {
+				t.Fatalf("expect %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	claStateSigned   = "signed"
+	claStateUnsigned = "unsigned"
+	claStateUnknown  = "unknown"
+
+	// claStateTTL is how long the last known state of PR is kept. The state
+	// is reconstructed from the labels when it expires or after a restart.
+	claStateTTL = 30 * day
+)
+
+// transitionTracker counts the transitions of the CLA state of PRs rather
+// than the raw events, by recording the last known state of each PR.
+type transitionTracker struct {
+	// states is the last known state of PR. The key is org/repo/number.
+	states *ttlCache
+
+	mu sync.Mutex
+	// counts is the transitions since the last summary. The key is org/repo
+	// and the value is the count of each transition.
+	counts map[string]map[string]int
+}
+
+func newTransitionTracker() *transitionTracker {
+	return &transitionTracker{
+		states: newTTLCache(claStateTTL),
+		counts: map[string]map[string]int{},
+	}
+}
+
+// claStateOfLabels reconstructs the state of PR from its cla labels. It is
+// empty if the PR has not been checked.
+func claStateOfLabels(cfg *botConfig, labels sets.String) string {
+	switch {
+	case labels.Has(cfg.CLALabelYes):
+		return claStateSigned
+	case labels.Has(cfg.CLALabelNo):
+		return claStateUnsigned
+	case cfg.CLALabelError != "" && labels.Has(cfg.CLALabelError):
+		return claStateUnknown
+	}
+
+	return ""
+}
+
+// observe records the state of PR found by the check. prev is the state
+// reconstructed from the labels, which is used if the PR is not recorded.
+func (t *transitionTracker) observe(org, repo string, number int32, prev, state string) {
+	key := prKey(org, repo, number)
+	if v, ok := t.states.get(key); ok {
+		prev = v.(string)
+	}
+
+	t.states.set(key, state)
+
+	if prev == "" || prev == state {
+		return
+	}
+
+	transition := prev + "_to_" + state
+	stateTransitions.WithLabelValues(org, repo, transition).Inc()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	repoKey := org + "/" + repo
+	if t.counts[repoKey] == nil {
+		t.counts[repoKey] = map[string]int{}
+	}
+	t.counts[repoKey][transition]++
+}
+
+// summarize logs the transitions of each repo since the last summary and resets them.
+func (t *transitionTracker) summarize() {
+	t.mu.Lock()
+	counts := t.counts
+	t.counts = map[string]map[string]int{}
+	t.mu.Unlock()
+
+	repos := make([]string, 0, len(counts))
+	for k := range counts {
+		repos = append(repos, k)
+	}
+	sort.Strings(repos)
+
+	for _, repo := range repos {
+		fields := logrus.Fields{"summary": "cla-transitions", "repo": repo}
+		for k, v := range counts[repo] {
+			fields[k] = v
+		}
+
+		logrus.WithFields(fields).Info("The transitions of CLA state in the last day.")
+	}
+}
+
+// runSummary logs the summary of transitions daily until stop is closed.
+func (t *transitionTracker) runSummary(stop <-chan struct{}) {
+	ticker := time.NewTicker(day)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			t.summarize()
+		}
+	}
+}
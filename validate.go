@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/opensourceways/community-robot-lib/utils"
+)
+
+// runValidateConfig validates the config file, prints a report of all the
+// problems found and returns the exit code.
+func runValidateConfig(path string) int {
+	problems := validateConfigFile(path)
+	if len(problems) == 0 {
+		fmt.Printf("%s: OK\n", path)
+
+		return 0
+	}
+
+	fmt.Printf("%s: %d problem(s) found\n", path, len(problems))
+	for _, v := range problems {
+		fmt.Printf("  - %s\n", v)
+	}
+
+	return 1
+}
+
+// validateConfigFile checks the config file as the robot does when loading it,
+// plus the checks of urls. Unlike Validate, it goes on after a config item
+// fails, so that all the problems are reported at once.
+func validateConfigFile(path string) []string {
+	if _, err := os.Stat(path); err != nil {
+		return []string{err.Error()}
+	}
+
+	c := new(configuration)
+	if err := utils.LoadFromYaml(path, c); err != nil {
+		return []string{fmt.Sprintf("load: %v", err)}
+	}
+
+	if len(c.ConfigItems) == 0 {
+		return []string{"no config_items"}
+	}
+
+	c.SetDefault()
+
+	var r []string
+	for i := range c.ConfigItems {
+		item := &c.ConfigItems[i]
+
+		name := fmt.Sprintf("config_items[%d] (%s)", i, strings.Join(item.Repos, ", "))
+
+		if err := item.validate(); err != nil {
+			r = append(r, fmt.Sprintf("%s: %v", name, err))
+		}
+
+		for _, err := range item.validateURLs() {
+			r = append(r, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+
+	return r
+}
+
+// validateURLs checks that all the urls of config are absolute http(s) urls.
+func (c *botConfig) validateURLs() []error {
+	var r []error
+	check := func(field, v string) {
+		if v == "" {
+			return
+		}
+
+		if err := validateURL(v); err != nil {
+			r = append(r, fmt.Errorf("invalid %s: %v", field, err))
+		}
+	}
+
+	check("check_url", c.CheckURL)
+	check("sign_url", c.SignURL)
+	check("faq_url", c.FAQURL)
+
+	keys := make([]string, 0, len(c.FAQURLs))
+	for k := range c.FAQURLs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		check("faq_urls."+k, c.FAQURLs[k])
+	}
+
+	for i := range c.Branches {
+		b := &c.Branches[i]
+
+		check(fmt.Sprintf("branches[%s].check_url", b.Branch), b.CheckURL)
+		check(fmt.Sprintf("branches[%s].sign_url", b.Branch), b.SignURL)
+		check(fmt.Sprintf("branches[%s].faq_url", b.Branch), b.FAQURL)
+	}
+
+	for i := range c.Agreements {
+		a := &c.Agreements[i]
+
+		check(fmt.Sprintf("agreements[%s].check_url", a.Name), a.CheckURL)
+		check(fmt.Sprintf("agreements[%s].sign_url", a.Name), a.SignURL)
+		check(fmt.Sprintf("agreements[%s].faq_url", a.Name), a.FAQURL)
+	}
+
+	return r
+}
+
+func validateURL(v string) error {
+	u, err := url.Parse(v)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%s is not a http(s) url", v)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("%s has no host", v)
+	}
+
+	return nil
+}
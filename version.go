@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The build info injected by -ldflags, such as
+// "-X main.version=v1.2.0 -X main.gitSHA=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)".
+// build.sh injects them by the x_defs of go_binary, or by -ldflags with go_build.
+var (
+	version   = "dev"
+	gitSHA    = "unknown"
+	buildDate = "unknown"
+)
+
+// buildInfo is the build of the running robot.
+type buildInfo struct {
+	Version   string `json:"version"`
+	GitSHA    string `json:"git_sha"`
+	BuildDate string `json:"build_date"`
+}
+
+func currentBuild() buildInfo {
+	return buildInfo{Version: version, GitSHA: gitSHA, BuildDate: buildDate}
+}
+
+// String is the version reported everywhere, such as the footer of comments.
+func (b buildInfo) String() string {
+	sha := b.GitSHA
+	if len(sha) > 12 {
+		sha = sha[:12]
+	}
+
+	return fmt.Sprintf("%s (%s, built at %s)", b.Version, sha, b.BuildDate)
+}
+
+func (b buildInfo) fields() logrus.Fields {
+	return logrus.Fields{
+		"version":    b.Version,
+		"git_sha":    b.GitSHA,
+		"build_date": b.BuildDate,
+	}
+}
+
+func serveVersion(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, currentBuild())
+}